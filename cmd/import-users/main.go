@@ -0,0 +1,75 @@
+// Command import-users bulk imports users exported from another identity
+// provider, the same way POST /users/import does, without the request size
+// limit of the HTTP endpoint.
+//
+//	go run ./cmd/import-users -file users.ndjson -dry-run
+package main
+
+import (
+	"auth-api-jwt/config"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	filename := flag.String("file", "", "NDJSON or CSV export to import")
+	format := flag.String("format", "", "ndjson or csv, guessed from the file extension when empty")
+	dryRun := flag.Bool("dry-run", false, "validate every row without saving anything")
+	signerKey := flag.String("firebase-signer-key", "", "base64 signer key of a Firebase export")
+	saltSeparator := flag.String("firebase-salt-separator", "", "base64 salt separator of a Firebase export")
+	rounds := flag.Int("firebase-rounds", 8, "rounds of a Firebase export")
+	memCost := flag.Int("firebase-mem-cost", 14, "mem cost of a Firebase export")
+	flag.Parse()
+
+	if *filename == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	file, err := os.Open(*filename)
+	if err != nil {
+		log.Fatal("Open import file fail:", err)
+	}
+	defer file.Close()
+
+	db := config.NewDB()
+	config.Migrate(db)
+
+	importService := service.NewUserImportService(repository.NewAuthRepository(db), db, validator.New())
+
+	report, err := importService.Import(context.Background(), web.UserImportRequest{
+		Format:                *format,
+		DryRun:                *dryRun,
+		Filename:              *filename,
+		Data:                  file,
+		FirebaseSignerKey:     *signerKey,
+		FirebaseSaltSeparator: *saltSeparator,
+		FirebaseRounds:        *rounds,
+		FirebaseMemCost:       *memCost,
+	})
+	if err != nil {
+		log.Fatal("Import users fail:", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"auth-api-jwt/models/domain"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+func Migrate(db *gorm.DB) {
+	err := db.AutoMigrate(
+		&domain.User{},
+		&domain.RefreshToken{},
+		&domain.RevokedToken{},
+		&domain.UserRevocation{},
+		&domain.SigningKey{},
+		&domain.UserToken{},
+		&domain.MfaRecoveryCode{},
+		&domain.WebAuthnCredential{},
+		&domain.LoginAttempt{},
+		&domain.PasswordHistory{},
+		&domain.Session{},
+		&domain.OAuthClient{},
+		&domain.OAuthAuthorizationCode{},
+		&domain.OAuthDeviceCode{},
+	)
+
+	if err != nil {
+		log.Fatal("Migration Fail:", err)
+	}
+}
@@ -5,4 +5,14 @@ import "github.com/gofiber/fiber/v2"
 type AuthController interface {
 	Register(c *fiber.Ctx) error
 	Login(c *fiber.Ctx) error
+	VerifyMfa(c *fiber.Ctx) error
+	Refresh(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
+	Reauthenticate(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	ResendVerification(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+	StartPasswordless(c *fiber.Ctx) error
+	VerifyPasswordless(c *fiber.Ctx) error
 }
@@ -2,7 +2,7 @@ package controller
 
 // Register godoc
 // @Summary Register new user
-// @Description Mendaftarkan user baru
+// @Description Mendaftarkan user baru. Respons selalu sama walaupun email sudah terdaftar; pemilik akun akan menerima email pemberitahuan
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -14,7 +14,7 @@ func (AuthControllerImpl) RegisterDocs() {}
 
 // Login godoc
 // @Summary Login user
-// @Description Mengembalikan JWT token
+// @Description Mengembalikan access token (JWT) dan refresh token, atau mfa_token jika user memakai MFA. Dengan remember_me, refresh token berlaku REMEMBER_ME_TTL dan terikat ke device_id yang dikembalikan
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -23,3 +23,132 @@ func (AuthControllerImpl) RegisterDocs() {}
 // @Failure 400 {object} web.WebResponse
 // @Router /auth/login [post]
 func (AuthControllerImpl) LoginDocs() {}
+
+// VerifyMfa godoc
+// @Summary Verify second factor
+// @Description Menukar mfa_token dari login dengan kode TOTP atau recovery code untuk mendapatkan access token dan refresh token
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthMfaVerifyRequest true "MFA verify payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Router /auth/mfa/verify [post]
+func (AuthControllerImpl) VerifyMfaDocs() {}
+
+// Refresh godoc
+// @Summary Refresh access token
+// @Description Menukar refresh token dengan access token dan refresh token baru (rotasi). Sesi remember_me wajib mengirim device_id yang sama
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthRefreshRequest true "Refresh payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Router /auth/refresh [post]
+func (AuthControllerImpl) RefreshDocs() {}
+
+// Logout godoc
+// @Summary Logout user
+// @Description Mencabut access token saat ini dan (opsional) keluarga refresh token
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthLogoutRequest false "Logout payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Router /auth/logout [post]
+func (AuthControllerImpl) LogoutDocs() {}
+
+// Reauthenticate godoc
+// @Summary Re-authenticate for sensitive actions
+// @Description Membuktikan ulang identitas user dengan password, kode TOTP/recovery code, atau keduanya. Mengembalikan access token baru dengan auth_time terbaru untuk endpoint yang dilindungi RequireRecentAuth (error insufficient_user_authentication).
+// @Tags Auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthReauthenticateRequest true "Password or second factor"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
+// @Router /auth/reauthenticate [post]
+func (AuthControllerImpl) ReauthenticateDocs() {}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Memverifikasi email memakai token sekali pakai yang dikirim lewat email
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthVerifyEmailRequest true "Verify email payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /auth/verify-email [post]
+func (AuthControllerImpl) VerifyEmailDocs() {}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Mengirim ulang email verifikasi (response selalu sama agar tidak membocorkan email terdaftar)
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthResendVerificationRequest true "Resend verification payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /auth/resend-verification [post]
+func (AuthControllerImpl) ResendVerificationDocs() {}
+
+// ForgotPassword godoc
+// @Summary Forgot password
+// @Description Mengirim link reset password (response selalu sama agar tidak membocorkan email terdaftar)
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthForgotPasswordRequest true "Forgot password payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /auth/forgot-password [post]
+func (AuthControllerImpl) ForgotPasswordDocs() {}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Mengganti password memakai token sekali pakai dan mencabut semua sesi user
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthResetPasswordRequest true "Reset password payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /auth/reset-password [post]
+func (AuthControllerImpl) ResetPasswordDocs() {}
+
+// StartPasswordless godoc
+// @Summary Start passwordless login
+// @Description Mengirim link login sekali pakai (method "link") atau kode 6 digit (method "code") ke email. Dibatasi satu email per menit dan PASSWORDLESS_MAX_PER_HOUR per jam (response selalu sama agar tidak membocorkan email terdaftar)
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthPasswordlessStartRequest true "Passwordless start payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /auth/passwordless/start [post]
+func (AuthControllerImpl) StartPasswordlessDocs() {}
+
+// VerifyPasswordless godoc
+// @Summary Verify passwordless login
+// @Description Menukar token dari link, atau email + kode 6 digit, dengan JWT seperti login biasa. Email yang belum terverifikasi otomatis ditandai terverifikasi
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthPasswordlessVerifyRequest true "Link token or email and code"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
+// @Router /auth/passwordless/verify [post]
+func (AuthControllerImpl) VerifyPasswordlessDocs() {}
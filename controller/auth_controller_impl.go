@@ -1,10 +1,14 @@
 package controller
 
 import (
+	"auth-api-jwt/exception"
 	"auth-api-jwt/helper"
 	"auth-api-jwt/models/web"
 	"auth-api-jwt/service"
 
+	"errors"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -24,14 +28,16 @@ func (controller *AuthControllerImpl) Register(c *fiber.Ctx) error {
 		return helper.BadRequest(c, err.Error())
 	}
 
-	user, err := controller.authService.Register(c.Context(), authRegisterRequest)
-	if err != nil {
+	if err := controller.authService.Register(c.Context(), authRegisterRequest); err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
 		return helper.BadRequest(c, err.Error())
 	}
 
-	response := helper.ToUserResponse(user)
-
-	return helper.ResponseSuccess(c, response)
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "check your email to finish signing up",
+	})
 }
 
 func (controller *AuthControllerImpl) Login(c *fiber.Ctx) error {
@@ -40,12 +46,198 @@ func (controller *AuthControllerImpl) Login(c *fiber.Ctx) error {
 		return helper.BadRequest(c, err.Error())
 	}
 
-	token, err := controller.authService.Login(c.Context(), authLoginRequest)
+	authLoginRequest.ClientIp = c.IP()
+	authLoginRequest.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	tokens, err := controller.authService.Login(c.Context(), authLoginRequest)
+	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, tokens)
+}
+
+func (controller *AuthControllerImpl) VerifyMfa(c *fiber.Ctx) error {
+	authMfaVerifyRequest := web.AuthMfaVerifyRequest{}
+	if err := helper.ReadFromRequestBody(c, &authMfaVerifyRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	authMfaVerifyRequest.ClientIp = c.IP()
+	authMfaVerifyRequest.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	tokens, err := controller.authService.VerifyMfa(c.Context(), authMfaVerifyRequest)
 	if err != nil {
+		return helper.Unauthorized(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, tokens)
+}
+
+func (controller *AuthControllerImpl) Refresh(c *fiber.Ctx) error {
+	authRefreshRequest := web.AuthRefreshRequest{}
+	if err := helper.ReadFromRequestBody(c, &authRefreshRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	tokens, err := controller.authService.Refresh(c.Context(), authRefreshRequest)
+	if err != nil {
+		return helper.Unauthorized(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, tokens)
+}
+
+func (controller *AuthControllerImpl) Logout(c *fiber.Ctx) error {
+	authLogoutRequest := web.AuthLogoutRequest{}
+	if len(c.Body()) > 0 {
+		if err := helper.ReadFromRequestBody(c, &authLogoutRequest); err != nil {
+			return helper.BadRequest(c, err.Error())
+		}
+	}
+
+	authLogoutRequest.UserId = c.Locals("userId").(string)
+	authLogoutRequest.Jti, _ = c.Locals("jti").(string)
+	authLogoutRequest.SessionId, _ = c.Locals("sessionId").(string)
+	authLogoutRequest.TokenExpiresAt, _ = c.Locals("tokenExpiresAt").(time.Time)
+
+	if err := controller.authService.Logout(c.Context(), authLogoutRequest); err != nil {
 		return helper.BadRequest(c, err.Error())
 	}
 
 	return helper.ResponseSuccess(c, fiber.Map{
-		"token": token,
+		"message": "logged out",
 	})
 }
+
+func (controller *AuthControllerImpl) Reauthenticate(c *fiber.Ctx) error {
+	request := web.AuthReauthenticateRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.UserId = c.Locals("userId").(string)
+	request.SessionId, _ = c.Locals("sessionId").(string)
+	request.ClientIp = c.IP()
+
+	tokens, err := controller.authService.Reauthenticate(c.Context(), request)
+	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.Unauthorized(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, tokens)
+}
+
+func (controller *AuthControllerImpl) VerifyEmail(c *fiber.Ctx) error {
+	authVerifyEmailRequest := web.AuthVerifyEmailRequest{}
+	if err := helper.ReadFromRequestBody(c, &authVerifyEmailRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	if err := controller.authService.VerifyEmail(c.Context(), authVerifyEmailRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "email verified",
+	})
+}
+
+func (controller *AuthControllerImpl) ResendVerification(c *fiber.Ctx) error {
+	authResendVerificationRequest := web.AuthResendVerificationRequest{}
+	if err := helper.ReadFromRequestBody(c, &authResendVerificationRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	if err := controller.authService.ResendVerification(c.Context(), authResendVerificationRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "if the account exists and is not verified yet, a verification email has been sent",
+	})
+}
+
+func (controller *AuthControllerImpl) ForgotPassword(c *fiber.Ctx) error {
+	authForgotPasswordRequest := web.AuthForgotPasswordRequest{}
+	if err := helper.ReadFromRequestBody(c, &authForgotPasswordRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	if err := controller.authService.ForgotPassword(c.Context(), authForgotPasswordRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "if an account exists for this email, a password reset link has been sent",
+	})
+}
+
+func (controller *AuthControllerImpl) ResetPassword(c *fiber.Ctx) error {
+	authResetPasswordRequest := web.AuthResetPasswordRequest{}
+	if err := helper.ReadFromRequestBody(c, &authResetPasswordRequest); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	if err := controller.authService.ResetPassword(c.Context(), authResetPasswordRequest); err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "password has been reset",
+	})
+}
+
+// hasErrorResponse reports errors that exception.NewErrorHandler renders
+// itself: 423 and 429 responses with a Retry-After header, and 400
+// responses listing field errors.
+func (controller *AuthControllerImpl) StartPasswordless(c *fiber.Ctx) error {
+	request := web.AuthPasswordlessStartRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	if err := controller.authService.StartPasswordless(c.Context(), request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "if an account exists for this email, a sign-in email has been sent",
+	})
+}
+
+func (controller *AuthControllerImpl) VerifyPasswordless(c *fiber.Ctx) error {
+	request := web.AuthPasswordlessVerifyRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.ClientIp = c.IP()
+	request.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	tokens, err := controller.authService.VerifyPasswordless(c.Context(), request)
+	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.Unauthorized(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, tokens)
+}
+
+func hasErrorResponse(err error) bool {
+	var locked exception.AccountLockedError
+	var tooMany exception.TooManyRequestsError
+	var validation exception.ValidationError
+	return errors.As(err, &locked) || errors.As(err, &tooMany) || errors.As(err, &validation)
+}
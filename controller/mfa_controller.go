@@ -0,0 +1,10 @@
+package controller
+
+import "github.com/gofiber/fiber/v2"
+
+type MfaController interface {
+	Enroll(c *fiber.Ctx) error
+	Confirm(c *fiber.Ctx) error
+	Disable(c *fiber.Ctx) error
+	RegenerateRecoveryCodes(c *fiber.Ctx) error
+}
@@ -0,0 +1,53 @@
+package controller
+
+// EnrollMfa godoc
+// @Summary Start MFA enrollment
+// @Description Membuat secret TOTP baru dan provisioning URI untuk aplikasi authenticator. MFA baru aktif setelah dikonfirmasi
+// @Tags MFA
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/me/mfa/enroll [post]
+func (MfaControllerImpl) EnrollDocs() {}
+
+// ConfirmMfa godoc
+// @Summary Confirm MFA enrollment
+// @Description Mengaktifkan MFA dengan kode TOTP pertama dan mengembalikan recovery code (hanya ditampilkan sekali)
+// @Tags MFA
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body web.MfaCodeRequest true "TOTP code"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/me/mfa/confirm [post]
+func (MfaControllerImpl) ConfirmDocs() {}
+
+// DisableMfa godoc
+// @Summary Disable MFA
+// @Description Menonaktifkan MFA dengan kode TOTP atau recovery code. Tidak bisa dilakukan jika role user mewajibkan MFA
+// @Tags MFA
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body web.MfaCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
+// @Router /users/me/mfa [delete]
+func (MfaControllerImpl) DisableDocs() {}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Mengganti semua recovery code lama dengan yang baru
+// @Tags MFA
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body web.MfaCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
+// @Router /users/me/mfa/recovery-codes [post]
+func (MfaControllerImpl) RegenerateRecoveryCodesDocs() {}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MfaControllerImpl struct {
+	mfaService service.MfaService
+}
+
+func NewMfaController(mfaService service.MfaService) MfaController {
+	return &MfaControllerImpl{
+		mfaService: mfaService,
+	}
+}
+
+func (controller *MfaControllerImpl) Enroll(c *fiber.Ctx) error {
+	authUserId := c.Locals("userId").(string)
+
+	response, err := controller.mfaService.Enroll(c.Context(), authUserId)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, response)
+}
+
+func (controller *MfaControllerImpl) Confirm(c *fiber.Ctx) error {
+	request := web.MfaCodeRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.UserId = c.Locals("userId").(string)
+
+	response, err := controller.mfaService.Confirm(c.Context(), request)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, response)
+}
+
+func (controller *MfaControllerImpl) Disable(c *fiber.Ctx) error {
+	request := web.MfaCodeRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.UserId = c.Locals("userId").(string)
+	request.ClientIp = c.IP()
+
+	if err := controller.mfaService.Disable(c.Context(), request); err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "mfa disabled",
+	})
+}
+
+func (controller *MfaControllerImpl) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	request := web.MfaCodeRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.UserId = c.Locals("userId").(string)
+	request.ClientIp = c.IP()
+
+	response, err := controller.mfaService.RegenerateRecoveryCodes(c.Context(), request)
+	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, response)
+}
@@ -0,0 +1,18 @@
+package controller
+
+import "github.com/gofiber/fiber/v2"
+
+type OAuthController interface {
+	AuthorizePage(c *fiber.Ctx) error
+	Authorize(c *fiber.Ctx) error
+	Token(c *fiber.Ctx) error
+	UserInfo(c *fiber.Ctx) error
+	Introspect(c *fiber.Ctx) error
+	Revoke(c *fiber.Ctx) error
+	DeviceAuthorization(c *fiber.Ctx) error
+	DevicePage(c *fiber.Ctx) error
+	VerifyDevice(c *fiber.Ctx) error
+	CreateClient(c *fiber.Ctx) error
+	FindAllClients(c *fiber.Ctx) error
+	DeleteClient(c *fiber.Ctx) error
+}
@@ -0,0 +1,153 @@
+package controller
+
+// OAuthAuthorizePage godoc
+// @Summary OAuth authorization endpoint
+// @Description Menampilkan halaman login & persetujuan untuk client OAuth. redirect_uri harus sama persis dengan yang terdaftar, dan PKCE (code_challenge_method S256) wajib
+// @Tags OAuth
+// @Produce html
+// @Param response_type query string true "Harus code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Redirect URI yang terdaftar"
+// @Param scope query string false "Scope dipisah spasi"
+// @Param state query string false "State dari client"
+// @Param code_challenge query string true "PKCE code challenge (S256)"
+// @Param code_challenge_method query string true "Harus S256"
+// @Param nonce query string false "Nonce OpenID Connect, disalin ke id_token"
+// @Success 200 {string} string "Halaman login"
+// @Failure 400 {string} string "Halaman error"
+// @Router /oauth/authorize [get]
+func (OAuthControllerImpl) AuthorizePageDocs() {}
+
+// OAuthAuthorize godoc
+// @Summary Submit OAuth login form
+// @Description Memproses form login & persetujuan. Jika berhasil, redirect ke redirect_uri dengan code dan state; jika ditolak, redirect dengan error=access_denied
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Success 303 {string} string "Redirect ke client"
+// @Failure 401 {string} string "Halaman login dengan pesan error"
+// @Router /oauth/authorize [post]
+func (OAuthControllerImpl) AuthorizeDocs() {}
+
+// OAuthToken godoc
+// @Summary OAuth token endpoint
+// @Description Menukar authorization code (grant_type authorization_code, wajib code_verifier), refresh token (grant_type refresh_token), kredensial client confidential (grant_type client_credentials), atau device code (grant_type urn:ietf:params:oauth:grant-type:device_code) dengan JWT. Device yang belum disetujui mendapat error authorization_pending, atau slow_down jika polling terlalu cepat. Jika scope openid diberikan, authorization code juga menghasilkan id_token. Client confidential mengirim client_secret lewat HTTP Basic atau form. Respons dan error memakai format RFC 6749, tanpa web.WebResponse
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param request body web.OAuthTokenRequest true "Token request"
+// @Success 200 {object} web.OAuthTokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /oauth/token [post]
+func (OAuthControllerImpl) TokenDocs() {}
+
+// OAuthDeviceAuthorization godoc
+// @Summary Start device authorization
+// @Description Memulai device authorization grant (RFC 8628) untuk CLI tanpa browser. Mengembalikan device_code untuk polling ke /oauth/token dan user_code yang dimasukkan user di halaman /device
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param request body web.OAuthDeviceAuthorizationRequest true "Device authorization request"
+// @Success 200 {object} web.OAuthDeviceAuthorizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /oauth/device_authorization [post]
+func (OAuthControllerImpl) DeviceAuthorizationDocs() {}
+
+// OAuthDevicePage godoc
+// @Summary Device verification page
+// @Description Halaman untuk memasukkan user_code dari device, lalu login dan menyetujui atau menolak permintaan device
+// @Tags OAuth
+// @Produce html
+// @Param user_code query string false "User code yang ditampilkan device"
+// @Success 200 {string} string "Halaman verifikasi"
+// @Failure 400 {string} string "Halaman dengan pesan error"
+// @Failure 429 {string} string "Terlalu banyak kode yang salah dari IP ini"
+// @Router /device [get]
+func (OAuthControllerImpl) DevicePageDocs() {}
+
+// OAuthVerifyDevice godoc
+// @Summary Approve or deny a device
+// @Description Memproses form halaman /device. Allow maupun Deny butuh login. Setelah disetujui, polling device di /oauth/token mendapat JWT yang sama seperti login biasa
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Success 200 {string} string "Halaman konfirmasi"
+// @Failure 400 {string} string "Kode tidak valid atau kedaluwarsa"
+// @Failure 401 {string} string "Halaman dengan pesan error"
+// @Failure 429 {string} string "Terlalu banyak percobaan"
+// @Router /device [post]
+func (OAuthControllerImpl) VerifyDeviceDocs() {}
+
+// OAuthIntrospect godoc
+// @Summary Token introspection
+// @Description Memeriksa apakah access token atau refresh token masih aktif (RFC 7662), dengan pemeriksaan token yang sama seperti JWTMiddleware (token terbatas dan token user yang belum verifikasi email saat REQUIRE_VERIFIED_EMAIL aktif dianggap tidak aktif). Hanya untuk client confidential (client_secret lewat HTTP Basic atau form). Token tidak aktif hanya mengembalikan {"active": false}
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param request body web.OAuthIntrospectRequest true "Introspection request"
+// @Success 200 {object} web.OAuthIntrospectResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /oauth/introspect [post]
+func (OAuthControllerImpl) IntrospectDocs() {}
+
+// OAuthRevoke godoc
+// @Summary Token revocation
+// @Description Mencabut access token atau refresh token milik client pemanggil (RFC 7009). Mencabut refresh token mengakhiri seluruh sesinya. Token yang tidak dikenal atau sudah tidak berlaku tetap dijawab 200
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Param request body web.OAuthRevokeRequest true "Revocation request"
+// @Success 200
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /oauth/revoke [post]
+func (OAuthControllerImpl) RevokeDocs() {}
+
+// OAuthUserInfo godoc
+// @Summary OpenID Connect userinfo
+// @Description Mengembalikan claim user pemilik access token sesuai scope yang diberikan: sub selalu, email & email_verified untuk scope email, name untuk scope profile. Access token wajib punya scope openid
+// @Tags OAuth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} web.OIDCUserInfoResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /userinfo [get]
+func (OAuthControllerImpl) UserInfoDocs() {}
+
+// CreateOAuthClient godoc
+// @Summary Register OAuth client
+// @Description Mendaftarkan aplikasi (SPA / mobile) sebagai client OAuth dengan redirect URI dan scope yang diizinkan. Dengan confidential, client_secret dibuat dan hanya ditampilkan sekali
+// @Tags OAuth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body web.OAuthClientCreateRequest true "Client payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /oauth/clients [post]
+func (OAuthControllerImpl) CreateClientDocs() {}
+
+// FindAllOAuthClients godoc
+// @Summary List OAuth clients
+// @Description Menampilkan semua client OAuth yang terdaftar
+// @Tags OAuth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} web.WebResponse
+// @Router /oauth/clients [get]
+func (OAuthControllerImpl) FindAllClientsDocs() {}
+
+// DeleteOAuthClient godoc
+// @Summary Delete OAuth client
+// @Description Menghapus client OAuth; authorization code dan refresh token-nya tidak bisa ditukar lagi, dan token client_credentials-nya langsung ditolak
+// @Tags OAuth
+// @Security BearerAuth
+// @Produce json
+// @Param clientId path string true "Client ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /oauth/clients/{clientId} [delete]
+func (OAuthControllerImpl) DeleteClientDocs() {}
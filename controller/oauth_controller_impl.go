@@ -0,0 +1,344 @@
+package controller
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OAuthControllerImpl struct {
+	oauthService service.OAuthService
+}
+
+func NewOAuthController(oauthService service.OAuthService) OAuthController {
+	return &OAuthControllerImpl{
+		oauthService: oauthService,
+	}
+}
+
+func (controller *OAuthControllerImpl) AuthorizePage(c *fiber.Ctx) error {
+	request := web.OAuthAuthorizeRequest{}
+	if err := c.QueryParser(&request); err != nil {
+		return renderAuthorizePage(c, fiber.StatusBadRequest, authorizePage{Error: err.Error()})
+	}
+
+	consent, err := controller.oauthService.ValidateAuthorization(c.Context(), request)
+	if err != nil {
+		return authorizeError(c, request, err)
+	}
+
+	return renderAuthorizePage(c, fiber.StatusOK, authorizePage{Request: request, Consent: &consent})
+}
+
+// Authorize handles the login form. Wrong credentials show the page again;
+// the code, or the user's refusal, goes back to the client.
+func (controller *OAuthControllerImpl) Authorize(c *fiber.Ctx) error {
+	request := web.OAuthAuthorizeRequest{}
+	if err := c.BodyParser(&request); err != nil {
+		return renderAuthorizePage(c, fiber.StatusBadRequest, authorizePage{Error: err.Error()})
+	}
+
+	request.ClientIp = c.IP()
+	request.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	location, err := controller.oauthService.Authorize(c.Context(), request)
+	if err == nil {
+		return c.Redirect(location, fiber.StatusSeeOther)
+	}
+
+	var oauthError exception.OAuthError
+	if errors.As(err, &oauthError) {
+		return authorizeError(c, request, err)
+	}
+
+	consent, validateErr := controller.oauthService.ValidateAuthorization(c.Context(), request)
+	if validateErr != nil {
+		return authorizeError(c, request, validateErr)
+	}
+
+	status := fiber.StatusUnauthorized
+	askCode := request.Code != ""
+	if errors.Is(err, service.ErrMfaCodeRequired) {
+		status = fiber.StatusOK
+		askCode = true
+	}
+
+	request.Password = ""
+	return renderAuthorizePage(c, status, authorizePage{Request: request, Consent: &consent, Error: err.Error(), AskCode: askCode})
+}
+
+// authorizeError sends an OAuth error back to the client when its
+// redirect URI has been checked, and shows it to the user otherwise.
+func authorizeError(c *fiber.Ctx, request web.OAuthAuthorizeRequest, err error) error {
+	var oauthError exception.OAuthError
+	if errors.As(err, &oauthError) && oauthError.RedirectUri != "" {
+		location := oauthErrorLocation(oauthError, request.State)
+		return c.Redirect(location, fiber.StatusSeeOther)
+	}
+
+	return renderAuthorizePage(c, fiber.StatusBadRequest, authorizePage{Error: err.Error()})
+}
+
+func oauthErrorLocation(oauthError exception.OAuthError, state string) string {
+	params := url.Values{
+		"error":             {oauthError.Code},
+		"error_description": {oauthError.Description},
+		"state":             {state},
+	}
+
+	return utils.AppendQuery(oauthError.RedirectUri, params)
+}
+
+// Token answers in the RFC 6749 format; errors are rendered the same way
+// by the error handler.
+func (controller *OAuthControllerImpl) Token(c *fiber.Ctx) error {
+	request := web.OAuthTokenRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return exception.OAuthError{Code: "invalid_request", Description: err.Error()}
+	}
+
+	if clientId, clientSecret, ok := basicClientCredentials(c); ok {
+		request.ClientId = clientId
+		request.ClientSecret = clientSecret
+	}
+
+	request.ClientIp = c.IP()
+	request.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	tokens, err := controller.oauthService.Token(c.Context(), request)
+	if err != nil {
+		return toOAuthError(err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(tokens)
+}
+
+func (controller *OAuthControllerImpl) Introspect(c *fiber.Ctx) error {
+	request := web.OAuthIntrospectRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return exception.OAuthError{Code: "invalid_request", Description: err.Error()}
+	}
+
+	if clientId, clientSecret, ok := basicClientCredentials(c); ok {
+		request.ClientId = clientId
+		request.ClientSecret = clientSecret
+	}
+
+	introspection, err := controller.oauthService.Introspect(c.Context(), request)
+	if err != nil {
+		return toOAuthError(err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(introspection)
+}
+
+// Revoke answers 200 with an empty body, also for tokens that were
+// already invalid (RFC 7009 section 2.2).
+func (controller *OAuthControllerImpl) Revoke(c *fiber.Ctx) error {
+	request := web.OAuthRevokeRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return exception.OAuthError{Code: "invalid_request", Description: err.Error()}
+	}
+
+	if clientId, clientSecret, ok := basicClientCredentials(c); ok {
+		request.ClientId = clientId
+		request.ClientSecret = clientSecret
+	}
+
+	if err := controller.oauthService.Revoke(c.Context(), request); err != nil {
+		return toOAuthError(err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// toOAuthError answers errors of the token endpoints in the RFC 6749
+// format.
+func toOAuthError(err error) error {
+	var oauthError exception.OAuthError
+	if errors.As(err, &oauthError) {
+		return err
+	}
+	return exception.OAuthError{Code: "invalid_request", Description: err.Error()}
+}
+
+// UserInfo is the OpenID Connect userinfo endpoint. Like Token it answers
+// without the web.WebResponse envelope.
+func (controller *OAuthControllerImpl) UserInfo(c *fiber.Ctx) error {
+	userId, _ := c.Locals("userId").(string)
+	scopes, _ := c.Locals("scopes").([]string)
+
+	claims, err := controller.oauthService.UserInfo(c.Context(), userId, scopes)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(claims)
+}
+
+// DeviceAuthorization answers in the RFC 8628 format, like Token.
+func (controller *OAuthControllerImpl) DeviceAuthorization(c *fiber.Ctx) error {
+	request := web.OAuthDeviceAuthorizationRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return exception.OAuthError{Code: "invalid_request", Description: err.Error()}
+	}
+
+	if clientId, clientSecret, ok := basicClientCredentials(c); ok {
+		request.ClientId = clientId
+		request.ClientSecret = clientSecret
+	}
+
+	authorization, err := controller.oauthService.DeviceAuthorization(c.Context(), request)
+	if err != nil {
+		return toOAuthError(err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(authorization)
+}
+
+// DevicePage asks for the user code, or for the user's credentials once
+// the code is known, e.g. from verification_uri_complete.
+func (controller *OAuthControllerImpl) DevicePage(c *fiber.Ctx) error {
+	request := web.OAuthDeviceVerifyRequest{}
+	if err := c.QueryParser(&request); err != nil {
+		return renderDevicePage(c, fiber.StatusBadRequest, devicePage{Error: err.Error()})
+	}
+
+	if request.UserCode == "" {
+		return renderDevicePage(c, fiber.StatusOK, devicePage{})
+	}
+
+	request.ClientIp = c.IP()
+	consent, err := controller.oauthService.ValidateUserCode(c.Context(), request)
+	if err != nil {
+		return renderDevicePage(c, deviceErrorStatus(err), devicePage{Request: request, Error: err.Error()})
+	}
+
+	return renderDevicePage(c, fiber.StatusOK, devicePage{Request: request, Consent: &consent})
+}
+
+func (controller *OAuthControllerImpl) VerifyDevice(c *fiber.Ctx) error {
+	request := web.OAuthDeviceVerifyRequest{}
+	if err := c.BodyParser(&request); err != nil {
+		return renderDevicePage(c, fiber.StatusBadRequest, devicePage{Error: err.Error()})
+	}
+
+	request.ClientIp = c.IP()
+	request.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	err := controller.oauthService.VerifyDevice(c.Context(), request)
+	if err == nil {
+		done := "Your device is connected"
+		if request.Decision != "allow" {
+			done = "The request was denied"
+		}
+		return renderDevicePage(c, fiber.StatusOK, devicePage{Done: done})
+	}
+
+	// The code itself was rejected; looking it up again would count twice.
+	var tooMany exception.TooManyRequestsError
+	if errors.Is(err, service.ErrInvalidUserCode) || errors.As(err, &tooMany) {
+		return renderDevicePage(c, deviceErrorStatus(err), devicePage{Error: err.Error()})
+	}
+
+	consent, validateErr := controller.oauthService.ValidateUserCode(c.Context(), request)
+	if validateErr != nil {
+		return renderDevicePage(c, deviceErrorStatus(validateErr), devicePage{Error: validateErr.Error()})
+	}
+
+	status := fiber.StatusUnauthorized
+	askCode := request.Code != ""
+	if errors.Is(err, service.ErrMfaCodeRequired) {
+		status = fiber.StatusOK
+		askCode = true
+	}
+
+	request.Password = ""
+	return renderDevicePage(c, status, devicePage{Request: request, Consent: &consent, Error: err.Error(), AskCode: askCode})
+}
+
+func deviceErrorStatus(err error) int {
+	var tooMany exception.TooManyRequestsError
+	if errors.As(err, &tooMany) {
+		return fiber.StatusTooManyRequests
+	}
+	return fiber.StatusBadRequest
+}
+
+// basicClientCredentials reads client_secret_basic authentication. Both
+// parts are form-encoded before being joined, per RFC 6749 section 2.3.1.
+func basicClientCredentials(c *fiber.Ctx) (string, string, bool) {
+	header := c.Get(fiber.HeaderAuthorization)
+	if !strings.HasPrefix(header, "Basic ") {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return "", "", false
+	}
+
+	rawId, rawSecret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+
+	clientId, err := url.QueryUnescape(rawId)
+	if err != nil {
+		return "", "", false
+	}
+	clientSecret, err := url.QueryUnescape(rawSecret)
+	if err != nil {
+		return "", "", false
+	}
+
+	return clientId, clientSecret, true
+}
+
+func (controller *OAuthControllerImpl) CreateClient(c *fiber.Ctx) error {
+	request := web.OAuthClientCreateRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	client, err := controller.oauthService.CreateClient(c.Context(), request)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, client)
+}
+
+func (controller *OAuthControllerImpl) FindAllClients(c *fiber.Ctx) error {
+	clients, err := controller.oauthService.FindAllClients(c.Context())
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, clients)
+}
+
+func (controller *OAuthControllerImpl) DeleteClient(c *fiber.Ctx) error {
+	clientId := c.Params("clientId")
+
+	if err := controller.oauthService.DeleteClient(c.Context(), clientId); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message":   "client deleted",
+		"client_id": clientId,
+	})
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"auth-api-jwt/models/web"
+	"bytes"
+	"html/template"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// authorizePage is the data of the OAuth login and consent page. Without
+// a Consent only Error is shown, for requests that cannot be sent back to
+// the client.
+type authorizePage struct {
+	Request web.OAuthAuthorizeRequest
+	Consent *web.OAuthConsentResponse
+	Error   string
+	AskCode bool
+}
+
+// devicePage is the data of the /device page. Without a Consent it asks
+// for the user code; Done replaces the form once the request is answered.
+type devicePage struct {
+	Request web.OAuthDeviceVerifyRequest
+	Consent *web.OAuthConsentResponse
+	Error   string
+	AskCode bool
+	Done    string
+}
+
+const pageHead = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f4f4f5; display: flex; justify-content: center; padding-top: 10vh; }
+main { background: #fff; border-radius: 8px; padding: 2rem; width: 22rem; box-shadow: 0 1px 4px rgba(0,0,0,.1); }
+label { display: block; margin-top: 1rem; font-size: .9rem; }
+input { width: 100%; box-sizing: border-box; padding: .5rem; margin-top: .25rem; }
+.error { color: #b91c1c; }
+.actions { display: flex; gap: .5rem; margin-top: 1.5rem; }
+button { flex: 1; padding: .6rem; }
+</style>
+</head>
+<body>
+<main>
+`
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(pageHead + `{{if .Consent}}
+<h1>Sign in to {{.Consent.ClientName}}</h1>
+{{if .Consent.Scopes}}
+<p>{{.Consent.ClientName}} is asking for:</p>
+<ul>{{range .Consent.Scopes}}<li>{{.}}</li>{{end}}</ul>
+{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth/authorize">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientId}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectUri}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<label>Email <input type="email" name="email" value="{{.Request.Email}}" autocomplete="username" required></label>
+<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+{{if .AskCode}}
+<label>Authentication or recovery code <input type="text" name="code" autocomplete="one-time-code" autofocus required></label>
+{{end}}
+<div class="actions">
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+<button type="submit" name="decision" value="allow">Allow</button>
+</div>
+</form>
+{{else}}
+<h1>Cannot sign in</h1>
+<p class="error">{{.Error}}</p>
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+var deviceTemplate = template.Must(template.New("device").Parse(pageHead + `{{if .Done}}
+<h1>{{.Done}}</h1>
+<p>You can close this page and return to your device.</p>
+{{else if .Consent}}
+<h1>Connect {{.Consent.ClientName}}</h1>
+<p>Only continue if {{.Consent.ClientName}} on your device shows the code <strong>{{.Request.UserCode}}</strong>.</p>
+{{if .Consent.Scopes}}
+<p>It is asking for:</p>
+<ul>{{range .Consent.Scopes}}<li>{{.}}</li>{{end}}</ul>
+{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/device">
+<input type="hidden" name="user_code" value="{{.Request.UserCode}}">
+<label>Email <input type="email" name="email" value="{{.Request.Email}}" autocomplete="username" required></label>
+<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+{{if .AskCode}}
+<label>Authentication or recovery code <input type="text" name="code" autocomplete="one-time-code" autofocus required></label>
+{{end}}
+<div class="actions">
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+<button type="submit" name="decision" value="allow">Allow</button>
+</div>
+</form>
+{{else}}
+<h1>Connect a device</h1>
+<p>Enter the code shown on your device.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="get" action="/device">
+<label>Code <input type="text" name="user_code" value="{{.Request.UserCode}}" autocomplete="off" autofocus required></label>
+<div class="actions">
+<button type="submit">Continue</button>
+</div>
+</form>
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+// renderAuthorizePage sends the page with headers that keep it out of
+// caches and frames, so it cannot be used for clickjacking.
+func renderAuthorizePage(c *fiber.Ctx, status int, page authorizePage) error {
+	return renderPage(c, status, authorizeTemplate, page)
+}
+
+func renderDevicePage(c *fiber.Ctx, status int, page devicePage) error {
+	return renderPage(c, status, deviceTemplate, page)
+}
+
+func renderPage(c *fiber.Ctx, status int, pageTemplate *template.Template, data interface{}) error {
+	var body bytes.Buffer
+	if err := pageTemplate.Execute(&body, data); err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderXFrameOptions, "DENY")
+	c.Set(fiber.HeaderContentSecurityPolicy, "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Type("html", "utf-8")
+
+	return c.Status(status).Send(body.Bytes())
+}
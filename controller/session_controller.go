@@ -0,0 +1,12 @@
+package controller
+
+import "github.com/gofiber/fiber/v2"
+
+type SessionController interface {
+	FindMine(c *fiber.Ctx) error
+	RevokeMine(c *fiber.Ctx) error
+	RevokeMyOthers(c *fiber.Ctx) error
+	FindByUser(c *fiber.Ctx) error
+	RevokeByUser(c *fiber.Ctx) error
+	RevokeAllByUser(c *fiber.Ctx) error
+}
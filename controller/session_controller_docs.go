@@ -0,0 +1,75 @@
+package controller
+
+// FindMySessions godoc
+// @Summary List own sessions
+// @Description Menampilkan sesi aktif user (perangkat, user agent, IP, waktu login & terakhir aktif). Sesi dari token yang dipakai ditandai current
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Router /users/me/sessions [get]
+func (SessionControllerImpl) FindMineDocs() {}
+
+// RevokeMySession godoc
+// @Summary Revoke own session
+// @Description Mengeluarkan satu sesi: refresh token-nya dicabut dan access token-nya langsung ditolak
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Param sessionId path string true "Session ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/me/sessions/{sessionId} [delete]
+func (SessionControllerImpl) RevokeMineDocs() {}
+
+// RevokeMyOtherSessions godoc
+// @Summary Log out everywhere else
+// @Description Mengeluarkan semua sesi user kecuali sesi yang sedang dipakai
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/me/sessions [delete]
+func (SessionControllerImpl) RevokeMyOthersDocs() {}
+
+// FindUserSessions godoc
+// @Summary List user sessions (Admin only)
+// @Description Menampilkan sesi aktif user tertentu
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /users/{userId}/sessions [get]
+func (SessionControllerImpl) FindByUserDocs() {}
+
+// RevokeUserSession godoc
+// @Summary Revoke user session (Admin only)
+// @Description Mengeluarkan satu sesi user tertentu
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Param userId path string true "User ID"
+// @Param sessionId path string true "Session ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /users/{userId}/sessions/{sessionId} [delete]
+func (SessionControllerImpl) RevokeByUserDocs() {}
+
+// RevokeAllUserSessions godoc
+// @Summary Revoke all user sessions (Admin only)
+// @Description Mengeluarkan user tertentu dari semua sesi
+// @Tags Session
+// @Security BearerAuth
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 403 {object} web.WebResponse
+// @Router /users/{userId}/sessions [delete]
+func (SessionControllerImpl) RevokeAllByUserDocs() {}
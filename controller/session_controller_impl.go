@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/service"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type SessionControllerImpl struct {
+	sessionService service.SessionService
+}
+
+func NewSessionController(sessionService service.SessionService) SessionController {
+	return &SessionControllerImpl{
+		sessionService: sessionService,
+	}
+}
+
+func (controller *SessionControllerImpl) FindMine(c *fiber.Ctx) error {
+	authUserId := c.Locals("userId").(string)
+	sessionId, _ := c.Locals("sessionId").(string)
+
+	sessions, err := controller.sessionService.FindByUser(c.Context(), authUserId, sessionId)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, sessions)
+}
+
+func (controller *SessionControllerImpl) RevokeMine(c *fiber.Ctx) error {
+	authUserId := c.Locals("userId").(string)
+
+	if err := controller.sessionService.Revoke(c.Context(), authUserId, c.Params("sessionId")); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "session revoked",
+	})
+}
+
+// RevokeMyOthers keeps the session of the calling token. A token without a
+// session id signs the user out everywhere.
+func (controller *SessionControllerImpl) RevokeMyOthers(c *fiber.Ctx) error {
+	authUserId := c.Locals("userId").(string)
+	sessionId, _ := c.Locals("sessionId").(string)
+
+	if err := controller.sessionService.RevokeOthers(c.Context(), authUserId, sessionId); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "other sessions revoked",
+	})
+}
+
+func (controller *SessionControllerImpl) FindByUser(c *fiber.Ctx) error {
+	userId := c.Params("userId")
+	if _, err := uuid.Parse(userId); err != nil {
+		return helper.BadRequest(c, "invalid UUID")
+	}
+
+	sessions, err := controller.sessionService.FindByUser(c.Context(), userId, "")
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, sessions)
+}
+
+func (controller *SessionControllerImpl) RevokeByUser(c *fiber.Ctx) error {
+	userId := c.Params("userId")
+	if _, err := uuid.Parse(userId); err != nil {
+		return helper.BadRequest(c, "invalid UUID")
+	}
+
+	if err := controller.sessionService.Revoke(c.Context(), userId, c.Params("sessionId")); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "session revoked",
+	})
+}
+
+func (controller *SessionControllerImpl) RevokeAllByUser(c *fiber.Ctx) error {
+	userId := c.Params("userId")
+	if _, err := uuid.Parse(userId); err != nil {
+		return helper.BadRequest(c, "invalid UUID")
+	}
+
+	if err := controller.sessionService.RevokeOthers(c.Context(), userId, ""); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "all sessions revoked",
+		"id":      userId,
+	})
+}
@@ -72,3 +72,29 @@ func (UserControllerImpl) MeDocs() {}
 // @Success 200 {object} web.WebResponse
 // @Router /users/me [put]
 func (UserControllerImpl) UpdateMeDocs() {}
+
+// ChangePassword godoc
+// @Summary Change own password
+// @Description Mengganti password user yang sedang login dengan memasukkan password saat ini. Password baru harus memenuhi kebijakan password dan tidak boleh sama dengan beberapa password terakhir. Semua sesi lain dikeluarkan, email notifikasi dikirim, dan respons berisi token baru untuk perangkat ini. Token dengan must_change_password hanya bisa dipakai di endpoint ini.
+// @Tags User
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body web.AuthChangePasswordRequest true "Current and new password"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
+// @Router /users/me/password [post]
+func (UserControllerImpl) ChangePasswordDocs() {}
+
+// UnlockUser godoc
+// @Summary Unlock user (Admin only)
+// @Description Menghapus penghitung login gagal dan membuka kunci akun user
+// @Tags User
+// @Security BearerAuth
+// @Produce json
+// @Param userId path string true "User ID"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/{userId}/unlock [post]
+func (UserControllerImpl) UnlockDocs() {}
@@ -11,11 +11,13 @@ import (
 
 type UserControllerImpl struct {
 	userService service.UserService
+	authService service.AuthService
 }
 
-func NewUserController(userService service.UserService) UserController {
+func NewUserController(userService service.UserService, authService service.AuthService) UserController {
 	return &UserControllerImpl{
 		userService: userService,
+		authService: authService,
 	}
 }
 
@@ -31,6 +33,9 @@ func (controller *UserControllerImpl) Create(c *fiber.Ctx) error {
 
 	user, err := controller.userService.Create(c.Context(), userCreateRequest)
 	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
 		return c.Status(500).JSON(helper.ErrorResponse(err))
 	}
 
@@ -55,6 +60,9 @@ func (controller *UserControllerImpl) Update(c *fiber.Ctx) error {
 
 	user, err := controller.userService.Update(c.Context(), request)
 	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
 		return helper.BadRequest(c, err.Error())
 	}
 
@@ -74,12 +82,36 @@ func (controller *UserControllerImpl) UpdateMe(c *fiber.Ctx) error {
 
 	user, err := controller.userService.UpdateMe(c.Context(), request)
 	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
 		return helper.BadRequest(c, err.Error())
 	}
 
 	return helper.ResponseSuccess(c, helper.ToUserResponse(user))
 }
 
+func (controller *UserControllerImpl) ChangePassword(c *fiber.Ctx) error {
+	request := web.AuthChangePasswordRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.UserId = c.Locals("userId").(string)
+	request.ClientIp = c.IP()
+	request.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	tokens, err := controller.authService.ChangePassword(c.Context(), request)
+	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, tokens)
+}
+
 func (controller *UserControllerImpl) Delete(c *fiber.Ctx) error {
 	userId := c.Params("userId")
 	if _, err := uuid.Parse(userId); err != nil {
@@ -141,3 +173,19 @@ func (controller *UserControllerImpl) FindAll(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{"data": response})
 }
+
+func (controller *UserControllerImpl) Unlock(c *fiber.Ctx) error {
+	userId := c.Params("userId")
+	if _, err := uuid.Parse(userId); err != nil {
+		return helper.BadRequest(c, "invalid UUID")
+	}
+
+	if err := controller.userService.Unlock(c.Context(), userId); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "user unlocked",
+		"id":      userId,
+	})
+}
@@ -0,0 +1,20 @@
+package controller
+
+// ImportUsers godoc
+// @Summary Import users (Admin only)
+// @Description Mengimpor user dari export NDJSON/CSV penyedia lain (bcrypt/Auth0, argon2id, scrypt, Firebase scrypt, Django PBKDF2). Hash asing diganti dengan hash native saat user pertama kali login. Gunakan dry_run untuk validasi tanpa menyimpan
+// @Tags User
+// @Security BearerAuth
+// @Accept mpfd
+// @Produce json
+// @Param file formData file true "File NDJSON atau CSV"
+// @Param format formData string false "ndjson atau csv (default dari ekstensi file)"
+// @Param dry_run formData bool false "Validasi saja, tidak menyimpan user"
+// @Param firebase_signer_key formData string false "Base64 signer key Firebase"
+// @Param firebase_salt_separator formData string false "Base64 salt separator Firebase"
+// @Param firebase_rounds formData int false "Rounds Firebase"
+// @Param firebase_mem_cost formData int false "Mem cost Firebase"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /users/import [post]
+func (UserImportControllerImpl) ImportDocs() {}
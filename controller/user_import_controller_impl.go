@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UserImportControllerImpl struct {
+	userImportService service.UserImportService
+}
+
+func NewUserImportController(userImportService service.UserImportService) UserImportController {
+	return &UserImportControllerImpl{
+		userImportService: userImportService,
+	}
+}
+
+func (controller *UserImportControllerImpl) Import(c *fiber.Ctx) error {
+	request := web.UserImportRequest{}
+	if err := c.BodyParser(&request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return helper.BadRequest(c, "file is required")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+	defer file.Close()
+
+	request.Filename = fileHeader.Filename
+	request.Data = file
+
+	response, err := controller.userImportService.Import(c.Context(), request)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, response)
+}
@@ -0,0 +1,12 @@
+package controller
+
+import "github.com/gofiber/fiber/v2"
+
+type WebAuthnController interface {
+	RegisterOptions(c *fiber.Ctx) error
+	Register(c *fiber.Ctx) error
+	SignupOptions(c *fiber.Ctx) error
+	Signup(c *fiber.Ctx) error
+	LoginOptions(c *fiber.Ctx) error
+	Login(c *fiber.Ctx) error
+}
@@ -0,0 +1,72 @@
+package controller
+
+// WebAuthnRegisterOptions godoc
+// @Summary Passkey registration options
+// @Description Membuat challenge dan opsi untuk navigator.credentials.create agar user yang sedang login bisa menambah passkey
+// @Tags WebAuthn
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /auth/webauthn/register/options [post]
+func (WebAuthnControllerImpl) RegisterOptionsDocs() {}
+
+// WebAuthnRegister godoc
+// @Summary Register passkey
+// @Description Memverifikasi hasil navigator.credentials.create dan menyimpan passkey untuk user yang sedang login
+// @Tags WebAuthn
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body web.WebAuthnRegisterRequest true "Attestation payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /auth/webauthn/register [post]
+func (WebAuthnControllerImpl) RegisterDocs() {}
+
+// WebAuthnSignupOptions godoc
+// @Summary Passkey signup options
+// @Description Membuat challenge untuk mendaftarkan akun baru yang hanya memakai passkey (tanpa password)
+// @Tags WebAuthn
+// @Accept json
+// @Produce json
+// @Param request body web.WebAuthnSignupOptionsRequest true "Signup options payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
+// @Router /auth/webauthn/signup/options [post]
+func (WebAuthnControllerImpl) SignupOptionsDocs() {}
+
+// WebAuthnSignup godoc
+// @Summary Sign up with passkey
+// @Description Membuat akun tanpa password dari hasil navigator.credentials.create. Respons sama saja walaupun email sudah terdaftar; pemilik akun tersebut menerima email pemberitahuan
+// @Tags WebAuthn
+// @Accept json
+// @Produce json
+// @Param request body web.AuthPasskeySignupRequest true "Signup payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 400 {object} web.WebResponse
+// @Router /auth/webauthn/signup [post]
+func (WebAuthnControllerImpl) SignupDocs() {}
+
+// WebAuthnLoginOptions godoc
+// @Summary Passkey login options
+// @Description Membuat challenge untuk navigator.credentials.get (discoverable credential, tanpa email)
+// @Tags WebAuthn
+// @Produce json
+// @Success 200 {object} web.WebResponse
+// @Failure 429 {object} web.WebResponse
+// @Router /auth/webauthn/login/options [post]
+func (WebAuthnControllerImpl) LoginOptionsDocs() {}
+
+// WebAuthnLogin godoc
+// @Summary Login with passkey
+// @Description Memverifikasi hasil navigator.credentials.get dan mengembalikan access token dan refresh token
+// @Tags WebAuthn
+// @Accept json
+// @Produce json
+// @Param request body web.AuthPasskeyLoginRequest true "Assertion payload"
+// @Success 200 {object} web.WebResponse
+// @Failure 401 {object} web.WebResponse
+// @Router /auth/webauthn/login [post]
+func (WebAuthnControllerImpl) LoginDocs() {}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebAuthnControllerImpl struct {
+	webAuthnService service.WebAuthnService
+	authService     service.AuthService
+}
+
+func NewWebAuthnController(webAuthnService service.WebAuthnService, authService service.AuthService) WebAuthnController {
+	return &WebAuthnControllerImpl{
+		webAuthnService: webAuthnService,
+		authService:     authService,
+	}
+}
+
+func (controller *WebAuthnControllerImpl) RegisterOptions(c *fiber.Ctx) error {
+	authUserId := c.Locals("userId").(string)
+
+	options, err := controller.webAuthnService.RegistrationOptions(c.Context(), authUserId)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, options)
+}
+
+func (controller *WebAuthnControllerImpl) Register(c *fiber.Ctx) error {
+	request := web.WebAuthnRegisterRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.UserId = c.Locals("userId").(string)
+
+	credential, err := controller.webAuthnService.Register(c.Context(), request)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, credential)
+}
+
+func (controller *WebAuthnControllerImpl) SignupOptions(c *fiber.Ctx) error {
+	request := web.WebAuthnSignupOptionsRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.ClientIp = c.IP()
+
+	options, err := controller.webAuthnService.SignupOptions(c.Context(), request)
+	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, options)
+}
+
+func (controller *WebAuthnControllerImpl) Signup(c *fiber.Ctx) error {
+	request := web.AuthPasskeySignupRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	if err := controller.authService.SignupWithPasskey(c.Context(), request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, fiber.Map{
+		"message": "check your email to finish signing up",
+	})
+}
+
+func (controller *WebAuthnControllerImpl) LoginOptions(c *fiber.Ctx) error {
+	options, err := controller.webAuthnService.LoginOptions(c.Context(), web.WebAuthnLoginOptionsRequest{ClientIp: c.IP()})
+	if err != nil {
+		if hasErrorResponse(err) {
+			return err
+		}
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, options)
+}
+
+func (controller *WebAuthnControllerImpl) Login(c *fiber.Ctx) error {
+	request := web.AuthPasskeyLoginRequest{}
+	if err := helper.ReadFromRequestBody(c, &request); err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	request.ClientIp = c.IP()
+	request.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	tokens, err := controller.authService.LoginWithPasskey(c.Context(), request)
+	if err != nil {
+		return helper.Unauthorized(c, err.Error())
+	}
+
+	return helper.ResponseSuccess(c, tokens)
+}
@@ -0,0 +1,8 @@
+package controller
+
+import "github.com/gofiber/fiber/v2"
+
+type WellKnownController interface {
+	JWKS(c *fiber.Ctx) error
+	OpenIDConfiguration(c *fiber.Ctx) error
+}
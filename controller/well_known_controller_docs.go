@@ -0,0 +1,19 @@
+package controller
+
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public key yang dipakai untuk memverifikasi JWT (termasuk key yang sudah dipensiunkan tetapi tokennya belum kedaluwarsa)
+// @Tags Well-Known
+// @Produce json
+// @Success 200 {object} utils.JWKS
+// @Router /.well-known/jwks.json [get]
+func (WellKnownControllerImpl) JWKSDocs() {}
+
+// OpenIDConfiguration godoc
+// @Summary OpenID Connect discovery
+// @Description Dokumen discovery OpenID Connect: endpoint, scope, dan algoritma id_token yang didukung. Alamatnya diambil dari OIDC_ISSUER
+// @Tags Well-Known
+// @Produce json
+// @Success 200 {object} web.OpenIDConfigurationResponse
+// @Router /.well-known/openid-configuration [get]
+func (WellKnownControllerImpl) OpenIDConfigurationDocs() {}
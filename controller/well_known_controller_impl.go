@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"auth-api-jwt/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WellKnownControllerImpl struct {
+	signingKeyService service.SigningKeyService
+	oauthService      service.OAuthService
+}
+
+func NewWellKnownController(signingKeyService service.SigningKeyService, oauthService service.OAuthService) WellKnownController {
+	return &WellKnownControllerImpl{
+		signingKeyService: signingKeyService,
+		oauthService:      oauthService,
+	}
+}
+
+func (controller *WellKnownControllerImpl) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(controller.signingKeyService.JWKS())
+}
+
+func (controller *WellKnownControllerImpl) OpenIDConfiguration(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(controller.oauthService.OpenIDConfiguration())
+}
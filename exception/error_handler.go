@@ -2,6 +2,9 @@ package exception
 
 import (
 	"auth-api-jwt/models/web"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -16,6 +19,14 @@ func NewErrorHandler(c *fiber.Ctx, err error) error {
 		})
 	}
 
+	if validationError, ok := err.(ValidationError); ok {
+		return c.Status(fiber.StatusBadRequest).JSON(web.WebResponse{
+			Code:   fiber.StatusBadRequest,
+			Status: "BAD REQUEST",
+			Data:   validationError.Errors,
+		})
+	}
+
 	if notFound, ok := err.(NotFoundError); ok {
 		return c.Status(fiber.StatusNotFound).JSON(web.WebResponse{
 			Code:   fiber.StatusNotFound,
@@ -24,6 +35,36 @@ func NewErrorHandler(c *fiber.Ctx, err error) error {
 		})
 	}
 
+	if locked, ok := err.(AccountLockedError); ok {
+		setRetryAfter(c, locked.RetryAfter)
+		return c.Status(fiber.StatusLocked).JSON(web.WebResponse{
+			Code:   fiber.StatusLocked,
+			Status: "LOCKED",
+			Data:   locked.Error(),
+		})
+	}
+
+	if tooMany, ok := err.(TooManyRequestsError); ok {
+		setRetryAfter(c, tooMany.RetryAfter)
+		return c.Status(fiber.StatusTooManyRequests).JSON(web.WebResponse{
+			Code:   fiber.StatusTooManyRequests,
+			Status: "TOO MANY REQUESTS",
+			Data:   tooMany.Error(),
+		})
+	}
+
+	if oauthError, ok := err.(OAuthError); ok {
+		status := oauthError.Status
+		if status == 0 {
+			status = fiber.StatusBadRequest
+		}
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.Status(status).JSON(fiber.Map{
+			"error":             oauthError.Code,
+			"error_description": oauthError.Description,
+		})
+	}
+
 	if fiberErr, ok := err.(*fiber.Error); ok {
 		code := fiberErr.Code
 		if code == 0 {
@@ -50,3 +91,9 @@ func NewErrorHandler(c *fiber.Ctx, err error) error {
 		Data:   err.Error(),
 	})
 }
+
+func setRetryAfter(c *fiber.Ctx, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+}
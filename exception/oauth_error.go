@@ -0,0 +1,16 @@
+package exception
+
+// OAuthError is an error of the OAuth endpoints, answered in the format of
+// RFC 6749 section 5.2 instead of web.WebResponse. Status defaults to 400.
+// RedirectUri is set when the error may be sent back to the client on its
+// redirect URI instead of being shown to the user.
+type OAuthError struct {
+	Code        string
+	Description string
+	Status      int
+	RedirectUri string
+}
+
+func (e OAuthError) Error() string {
+	return e.Description
+}
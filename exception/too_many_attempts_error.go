@@ -0,0 +1,25 @@
+package exception
+
+import "time"
+
+// AccountLockedError is returned while an account is locked after too many
+// failed logins.
+type AccountLockedError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e AccountLockedError) Error() string {
+	return e.Message
+}
+
+// TooManyRequestsError is returned when a caller has to wait before trying
+// again, for example between failed logins.
+type TooManyRequestsError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e TooManyRequestsError) Error() string {
+	return e.Message
+}
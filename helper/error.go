@@ -1,6 +1,11 @@
 package helper
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 func PanicIfError(err error) {
 	if err != nil {
@@ -20,3 +25,17 @@ func Unauthorized(c *fiber.Ctx, message string) error {
 		"message": message,
 	})
 }
+
+// ReauthenticationRequired answers with the step-up challenge of RFC 9470,
+// telling the client to call /auth/reauthenticate and retry.
+func ReauthenticationRequired(c *fiber.Ctx, maxAge time.Duration) error {
+	seconds := int(maxAge.Seconds())
+	c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="insufficient_user_authentication", error_description="recent authentication required", max_age=%d`, seconds))
+
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"status":  "UNAUTHORIZED",
+		"error":   "insufficient_user_authentication",
+		"message": "recent authentication required, call /auth/reauthenticate",
+		"max_age": seconds,
+	})
+}
@@ -1,11 +1,57 @@
 package helper
 
-import "gorm.io/gorm"
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+const afterCommitKey = "helper:after_commit"
 
 func CommitOrRollback(tx *gorm.DB) {
 	if r := recover(); r != nil {
 		tx.Rollback()
 		panic(r)
 	}
-	tx.Commit()
+	if tx.Commit().Error == nil {
+		runAfterCommit(tx)
+	}
+}
+
+// CommitOrRollbackThen works like CommitOrRollback and then calls
+// afterCommit, but only if the commit succeeded. It must be deferred
+// directly for the recover to take effect.
+func CommitOrRollbackThen(tx *gorm.DB, afterCommit func()) {
+	if r := recover(); r != nil {
+		tx.Rollback()
+		panic(r)
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Commit transaction fail:", err)
+		return
+	}
+	runAfterCommit(tx)
+	afterCommit()
+}
+
+// AfterCommit runs fn once tx is committed by CommitOrRollback or
+// CommitOrRollbackThen, for work that must not see the data before other
+// connections do. Outside a transaction fn runs right away.
+func AfterCommit(tx *gorm.DB, fn func()) {
+	if _, ok := tx.Statement.ConnPool.(gorm.TxCommitter); !ok {
+		fn()
+		return
+	}
+
+	hooks, _ := tx.Statement.Settings.Load(afterCommitKey)
+	fns, _ := hooks.([]func())
+	tx.Statement.Settings.Store(afterCommitKey, append(fns, fn))
+}
+
+func runAfterCommit(tx *gorm.DB) {
+	hooks, _ := tx.Statement.Settings.Load(afterCommitKey)
+	fns, _ := hooks.([]func())
+	for _, fn := range fns {
+		fn()
+	}
 }
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// sendTimeout bounds how long a background send may take.
+const sendTimeout = time.Minute
+
+// BackgroundMailer hands messages to another Mailer without waiting for
+// them to be delivered, so a request takes as long whether or not it sent
+// an email. Delivery errors can only be logged.
+type BackgroundMailer struct {
+	Mailer  Mailer
+	pending sync.WaitGroup
+}
+
+func NewBackgroundMailer(mailer Mailer) *BackgroundMailer {
+	return &BackgroundMailer{
+		Mailer: mailer,
+	}
+}
+
+// Send returns immediately. The request context is not used for the
+// delivery, since it ends, and may be reused, once the response is sent.
+func (mailer *BackgroundMailer) Send(ctx context.Context, message Message) error {
+	mailer.pending.Add(1)
+
+	go func() {
+		defer mailer.pending.Done()
+
+		sendCtx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+
+		if err := mailer.Mailer.Send(sendCtx, message); err != nil {
+			log.Println("Send mail fail:", err)
+		}
+	}()
+
+	return nil
+}
+
+// Wait blocks until every message handed to Send has been delivered or
+// has failed.
+func (mailer *BackgroundMailer) Wait() {
+	mailer.pending.Wait()
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer writes every message to its own file in Dir, which is handy
+// for local development and tests.
+type FileMailer struct {
+	Dir string
+}
+
+func NewFileMailer(dir string) Mailer {
+	return &FileMailer{
+		Dir: dir,
+	}
+}
+
+func (mailer *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := os.MkdirAll(mailer.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), uuid.NewString())
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", message.To, message.Subject, message.Body)
+
+	return os.WriteFile(filepath.Join(mailer.Dir, name), []byte(content), 0o600)
+}
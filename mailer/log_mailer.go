@@ -0,0 +1,17 @@
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+type LogMailer struct{}
+
+func NewLogMailer() Mailer {
+	return &LogMailer{}
+}
+
+func (mailer *LogMailer) Send(ctx context.Context, message Message) error {
+	log.Printf("Mail to=%s subject=%q\n%s\n", message.To, message.Subject, message.Body)
+	return nil
+}
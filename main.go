@@ -4,17 +4,26 @@ import (
 	"auth-api-jwt/config"
 	"auth-api-jwt/controller"
 	"auth-api-jwt/exception"
+	"auth-api-jwt/mailer"
+	"auth-api-jwt/middleware"
 
 	_ "auth-api-jwt/docs"
 	"auth-api-jwt/repository"
 	"auth-api-jwt/routes"
 	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
 
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -27,21 +36,144 @@ func main() {
 		ErrorHandler: exception.NewErrorHandler,
 	})
 
+	if err := utils.CheckPasswordHasherConfig(); err != nil {
+		log.Fatal("Password hasher config invalid:", err)
+	}
+
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		if err := utils.LoadBreachedPasswords(path); err != nil {
+			log.Fatal("Load breached password list fail:", err)
+		}
+	}
+
 	db := config.NewDB()
+	config.Migrate(db)
 	validate := validator.New()
 
 	userRepository := repository.NewUserRepository(db)
 	authRepository := repository.NewAuthRepository(db)
+	refreshTokenRepository := repository.NewRefreshTokenRepository(db)
+	revocationStore := newRevocationStore(db)
+	sessionActivityStore := repository.NewBatchedSessionActivityStore(db)
+	repository.StartSessionActivityFlush(context.Background(), sessionActivityStore, utils.GetEnvDuration("SESSION_ACTIVITY_FLUSH_INTERVAL", time.Minute))
+	tokenVersionStore := repository.NewCachedTokenVersionStore(db, utils.GetEnvDuration("TOKEN_VERSION_CACHE_TTL", 30*time.Second))
+	signingKeyRepository := repository.NewSigningKeyRepository(db)
+	userTokenRepository := repository.NewUserTokenRepository(db)
+	mfaRecoveryCodeRepository := repository.NewMfaRecoveryCodeRepository(db)
+	webAuthnCredentialRepository := repository.NewWebAuthnCredentialRepository(db)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(db)
+	passwordHistoryRepository := repository.NewPasswordHistoryRepository(db)
+	sessionRepository := repository.NewSessionRepository(db)
+	oauthClientRepository := repository.NewOAuthClientRepository(db)
+	oauthAuthorizationCodeRepository := repository.NewOAuthAuthorizationCodeRepository(db)
+	oauthDeviceCodeRepository := repository.NewOAuthDeviceCodeRepository(db)
+	// emails are sent in the background so responses do not reveal whether one was sent
+	authMailer := mailer.NewBackgroundMailer(newMailer())
+
+	signingKeyService := newSigningKeyService(signingKeyRepository, db)
+
+	userService := service.NewUserService(userRepository, loginAttemptRepository, passwordHistoryRepository, refreshTokenRepository, sessionRepository, userTokenRepository, revocationStore, tokenVersionStore, authMailer, db, validate)
+	userImportService := service.NewUserImportService(authRepository, db, validate)
+	mfaService := service.NewMfaService(userRepository, mfaRecoveryCodeRepository, loginAttemptRepository, db, validate)
+	sessionService := service.NewSessionService(sessionRepository, refreshTokenRepository, revocationStore, tokenVersionStore, db)
+	webAuthnService := service.NewWebAuthnService(userRepository, webAuthnCredentialRepository, userTokenRepository, db, validate)
+	authService := service.NewAuthService(authRepository, userRepository, refreshTokenRepository, userTokenRepository, loginAttemptRepository, passwordHistoryRepository, sessionRepository, revocationStore, tokenVersionStore, mfaService, webAuthnService, authMailer, db, validate)
+
+	jwtConfig := middleware.JWTConfig{
+		RevocationStore:      revocationStore,
+		TokenVersions:        tokenVersionStore,
+		SessionActivity:      sessionActivityStore,
+		SessionIdleTimeout:   utils.GetEnvDuration("SESSION_IDLE_TIMEOUT", 0),
+		RequireVerifiedEmail: utils.GetEnvBool("REQUIRE_VERIFIED_EMAIL", false),
+	}
 
-	userService := service.NewUserService(userRepository, db, validate)
-	authService := service.NewAuthService(authRepository, userRepository, db, validate)
+	oauthService := service.NewOAuthService(oauthClientRepository, oauthAuthorizationCodeRepository, oauthDeviceCodeRepository, userRepository, refreshTokenRepository, sessionRepository, loginAttemptRepository, revocationStore, authService, userService, jwtConfig, db, validate)
 
-	userController := controller.NewUserController(userService)
+	userController := controller.NewUserController(userService, authService)
 	authController := controller.NewAuthController(authService)
+	mfaController := controller.NewMfaController(mfaService)
+	sessionController := controller.NewSessionController(sessionService)
+	userImportController := controller.NewUserImportController(userImportService)
+	webAuthnController := controller.NewWebAuthnController(webAuthnService, authService)
+	wellKnownController := controller.NewWellKnownController(signingKeyService, oauthService)
+	oauthController := controller.NewOAuthController(oauthService)
+
+	routes.NewUserRouter(app, userController, mfaController, sessionController, userImportController, jwtConfig)
+	routes.NewAuthRoutes(app, authController, jwtConfig)
+	routes.NewWebAuthnRoutes(app, webAuthnController, jwtConfig)
+	routes.NewWellKnownRoutes(app, wellKnownController)
+	routes.NewOAuthRoutes(app, oauthController, jwtConfig)
 
-	routes.NewUserRouter(app, userController)
-	routes.NewAuthRoutes(app, authController)
+	go func() {
+		if err := app.Listen(":3000"); err != nil {
+			log.Fatal("Listen fail:", err)
+		}
+	}()
 
-	app.Listen(":3000")
+	// On shutdown, requests in flight finish first and then the emails they
+	// queued are delivered, so no reset link or notification is dropped.
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	if err := app.Shutdown(); err != nil {
+		log.Println("Shutdown fail:", err)
+	}
+	authMailer.Wait()
+}
+
+func newRevocationStore(db *gorm.DB) repository.RevocationStore {
+	var store repository.RevocationStore
+	if os.Getenv("TOKEN_REVOCATION_STORE") == "memory" {
+		store = repository.NewMemoryRevocationStore()
+	} else {
+		store = repository.NewSQLRevocationStore(db)
+	}
 
+	interval := utils.GetEnvDuration("TOKEN_REVOCATION_PURGE_INTERVAL", 10*time.Minute)
+	repository.StartRevocationPurge(context.Background(), store, interval)
+
+	return store
+}
+
+func newSigningKeyService(signingKeyRepository repository.SigningKeyRepository, db *gorm.DB) service.SigningKeyService {
+	algorithm := os.Getenv("JWT_ALGORITHM")
+	if algorithm == "" {
+		algorithm = "HS256"
+	}
+
+	signingKeyService := service.NewSigningKeyService(signingKeyRepository, db, algorithm)
+	if algorithm == "HS256" {
+		return signingKeyService
+	}
+
+	if err := signingKeyService.Initialize(context.Background()); err != nil {
+		log.Fatal("Signing key setup fail:", err)
+	}
+
+	interval := utils.GetEnvDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour)
+	signingKeyService.StartRotation(context.Background(), interval)
+
+	return signingKeyService
+}
+
+func newMailer() mailer.Mailer {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		return mailer.NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("MAIL_FROM"),
+		)
+	case "file":
+		dir := os.Getenv("MAILER_FILE_DIR")
+		if dir == "" {
+			dir = "mails"
+		}
+		return mailer.NewFileMailer(dir)
+	default:
+		return mailer.NewLogMailer()
+	}
 }
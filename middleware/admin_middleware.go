@@ -6,8 +6,27 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func AdminOnly() fiber.Handler {
+// AdminOnly lets admins through. OAuth clients are let through when a
+// resource is given and their token has its scope: "<resource>:read" is
+// enough for GET and HEAD, "<resource>:write" allows every method.
+func AdminOnly(resource ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("clientId").(string); ok {
+			if len(resource) == 0 {
+				return helper.Forbidden(c, "admin only endpoint")
+			}
+
+			scopes, _ := c.Locals("scopes").([]string)
+			if hasScope(scopes, resource[0]+":write") {
+				return c.Next()
+			}
+			if (c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead) && hasScope(scopes, resource[0]+":read") {
+				return c.Next()
+			}
+
+			return helper.Forbidden(c, "insufficient scope")
+		}
+
 		role, ok := c.Locals("role").(string)
 		if !ok {
 			return helper.Forbidden(c, "role not found in token")
@@ -20,3 +39,24 @@ func AdminOnly() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// RequireUser rejects OAuth clients on routes about the signed-in user,
+// like /users/me, which they cannot have.
+func RequireUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("userId").(string); !ok {
+			return helper.Forbidden(c, "only available to users")
+		}
+
+		return c.Next()
+	}
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, granted := range scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
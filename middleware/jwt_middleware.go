@@ -2,14 +2,48 @@ package middleware
 
 import (
 	"auth-api-jwt/helper"
-	"os"
+	"auth-api-jwt/repository"
+	"errors"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func JWTMiddleware() fiber.Handler {
+type JWTConfig struct {
+	// RevocationStore is consulted for the token's jti when set.
+	RevocationStore repository.RevocationStore
+	// TokenVersions rejects tokens whose "ver" claim is older than the
+	// user's current token version when set.
+	TokenVersions repository.TokenVersionStore
+	// SessionActivity records when each session is used. With
+	// SessionIdleTimeout set, tokens of sessions unused for longer are
+	// rejected even before they expire.
+	SessionActivity    repository.SessionActivityStore
+	SessionIdleTimeout time.Duration
+	// RequireVerifiedEmail rejects tokens issued to users whose email
+	// address has not been verified yet.
+	RequireVerifiedEmail bool
+	// AllowedRestrictions lists the "restricted_to" claims accepted by this
+	// route. Restricted tokens are rejected everywhere else.
+	AllowedRestrictions []string
+	// AllowClients accepts tokens OAuth clients got for themselves with the
+	// client_credentials grant. They name a client instead of a user, so
+	// only routes that check scopes, like AdminOnly with a resource, should
+	// allow them.
+	AllowClients bool
+	// AllowDelegated accepts user tokens issued to an OAuth client (they
+	// have a client_id claim). The client only holds the scopes the user
+	// granted it, so first-party routes reject them whatever the role.
+	AllowDelegated bool
+}
+
+func JWTMiddleware(config ...JWTConfig) fiber.Handler {
+	cfg := JWTConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -21,38 +55,59 @@ func JWTMiddleware() fiber.Handler {
 			return helper.Unauthorized(c, "invalid authorization format")
 		}
 
-		tokenString := fields[1]
-		secret := os.Getenv("JWT_SECRET")
+		token, err := VerifyToken(c.Context(), cfg, fields[1])
+		if err != nil {
+			var tokenError TokenError
+			if errors.As(err, &tokenError) && tokenError.Forbidden {
+				return helper.Forbidden(c, tokenError.Message)
+			}
+			if errors.As(err, &tokenError) {
+				return helper.Unauthorized(c, tokenError.Message)
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
 
-		token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid token signature")
+		if token.UserId == "" {
+			if !cfg.AllowClients {
+				return helper.Forbidden(c, "client tokens are not accepted here")
 			}
-			return []byte(secret), nil
-		})
 
-		if err != nil || !token.Valid {
-			return helper.Unauthorized(c, "invalid or expired token")
-		}
+			c.Locals("clientId", token.ClientId)
+			c.Locals("scopes", token.Scopes)
+			c.Locals("jti", token.Jti)
+			c.Locals("tokenExpiresAt", token.ExpiresAt)
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return helper.Unauthorized(c, "invalid token claims")
+			return c.Next()
 		}
 
-		userId, ok := claims["user_id"].(string)
-		if !ok {
-			return helper.Unauthorized(c, "invalid user id in token")
+		if token.ClientId != "" && !cfg.AllowDelegated {
+			return helper.Forbidden(c, "tokens issued to an OAuth client are not accepted here")
 		}
 
-		role, ok := claims["role"].(string)
-		if !ok || role == "" {
-			return helper.Unauthorized(c, "invalid role in token")
+		if cfg.SessionActivity != nil && token.SessionId != "" {
+			cfg.SessionActivity.Touch(token.SessionId, time.Now())
 		}
 
-		c.Locals("userId", userId)
-		c.Locals("role", role)
+		c.Locals("userId", token.UserId)
+		c.Locals("role", token.Role)
+		c.Locals("jti", token.Jti)
+		c.Locals("tokenExpiresAt", token.ExpiresAt)
+
+		c.Locals("sessionId", token.SessionId)
+
+		// Tokens from an OAuth client carry the scopes the user granted it.
+		c.Locals("scopes", token.Scopes)
+		c.Locals("authTime", token.AuthTime)
 
 		return c.Next()
 	}
 }
+
+func isRestrictionAllowed(cfg JWTConfig, restriction string) bool {
+	for _, allowed := range cfg.AllowedRestrictions {
+		if allowed == restriction {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"auth-api-jwt/helper"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireRecentAuth guards sensitive actions. It must run after
+// JWTMiddleware and rejects tokens whose auth_time is older than maxAge
+// with the "insufficient_user_authentication" error, so clients know to
+// re-authenticate instead of signing in again. OAuth clients have no
+// sign-in to repeat and pass; their scopes are checked by AdminOnly.
+func RequireRecentAuth(maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("clientId").(string); ok {
+			return c.Next()
+		}
+
+		authTime, _ := c.Locals("authTime").(time.Time)
+		if authTime.IsZero() || time.Since(authTime) > maxAge {
+			return helper.ReauthenticationRequired(c, maxAge)
+		}
+
+		return c.Next()
+	}
+}
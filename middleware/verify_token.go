@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// VerifiedToken is an access token that passed VerifyToken. Tokens issued
+// to an OAuth client for itself have a ClientId but no UserId or Role.
+type VerifiedToken struct {
+	Claims    jwt.MapClaims
+	UserId    string
+	Role      string
+	ClientId  string
+	SessionId string
+	Jti       string
+	Scopes    []string
+	ExpiresAt time.Time
+	AuthTime  time.Time
+}
+
+// TokenError is returned by VerifyToken when the token is not valid.
+// Forbidden tokens are genuine but not usable here, like a restricted token
+// or one of an unverified user. Other errors mean one of the stores failed.
+type TokenError struct {
+	Message   string
+	Forbidden bool
+}
+
+func (e TokenError) Error() string {
+	return e.Message
+}
+
+// VerifyToken checks the signature and expiry of an access token and,
+// when cfg has them, the revocation store, the token version, the session
+// idle timeout, RequireVerifiedEmail and AllowedRestrictions. Whether client
+// or delegated tokens are welcome is left to JWTMiddleware.
+func VerifyToken(ctx context.Context, cfg JWTConfig, tokenString string) (VerifiedToken, error) {
+	claims, err := utils.ParseJWT(tokenString)
+	if err != nil {
+		return VerifiedToken{}, TokenError{Message: err.Error()}
+	}
+
+	token := VerifiedToken{Claims: claims}
+	token.ClientId, _ = claims["client_id"].(string)
+	token.Jti, _ = claims["jti"].(string)
+	scope, _ := claims["scope"].(string)
+	token.Scopes = strings.Fields(scope)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		token.ExpiresAt = exp.Time
+	}
+
+	if _, isUser := claims["user_id"]; !isUser && token.ClientId != "" {
+		return verifyClientToken(ctx, cfg, token)
+	}
+
+	userId, ok := claims["user_id"].(string)
+	if !ok {
+		return VerifiedToken{}, TokenError{Message: "invalid user id in token"}
+	}
+
+	role, ok := claims["role"].(string)
+	if !ok || role == "" {
+		return VerifiedToken{}, TokenError{Message: "invalid role in token"}
+	}
+
+	token.UserId = userId
+	token.Role = role
+	token.SessionId, _ = claims["sid"].(string)
+	if seconds, ok := claims["auth_time"].(float64); ok {
+		token.AuthTime = time.Unix(int64(seconds), 0)
+	}
+
+	if cfg.RevocationStore != nil {
+		revoked, err := isTokenRevoked(ctx, cfg.RevocationStore, token.Jti, token.SessionId, userId, claims)
+		if err != nil {
+			return VerifiedToken{}, err
+		}
+		if revoked {
+			return VerifiedToken{}, TokenError{Message: "token has been revoked"}
+		}
+	}
+
+	if cfg.TokenVersions != nil {
+		current, err := cfg.TokenVersions.Current(ctx, userId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return VerifiedToken{}, TokenError{Message: "user no longer exists"}
+		}
+		if err != nil {
+			return VerifiedToken{}, err
+		}
+
+		// Tokens issued before versions existed count as version 0.
+		version, _ := claims["ver"].(float64)
+		if int(version) < current {
+			return VerifiedToken{}, TokenError{Message: "token has been invalidated, sign in again"}
+		}
+	}
+
+	if cfg.SessionActivity != nil && token.SessionId != "" {
+		idle, err := isSessionIdle(ctx, cfg, token.SessionId, time.Now())
+		if err != nil {
+			return VerifiedToken{}, err
+		}
+		if idle {
+			return VerifiedToken{}, TokenError{Message: "session expired due to inactivity"}
+		}
+	}
+
+	if cfg.RequireVerifiedEmail {
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return VerifiedToken{}, TokenError{Message: "email not verified", Forbidden: true}
+		}
+	}
+
+	if restriction, _ := claims["restricted_to"].(string); restriction != "" && !isRestrictionAllowed(cfg, restriction) {
+		return VerifiedToken{}, TokenError{Message: "token is restricted to " + restriction, Forbidden: true}
+	}
+
+	return token, nil
+}
+
+// verifyClientToken checks a token issued to an OAuth client. It has no
+// session or token version; a deleted client is cut off through the same
+// revocation cutoff as a user.
+func verifyClientToken(ctx context.Context, cfg JWTConfig, token VerifiedToken) (VerifiedToken, error) {
+	if cfg.RevocationStore != nil {
+		revoked, err := isTokenRevoked(ctx, cfg.RevocationStore, token.Jti, "", token.ClientId, token.Claims)
+		if err != nil {
+			return VerifiedToken{}, err
+		}
+		if revoked {
+			return VerifiedToken{}, TokenError{Message: "token has been revoked"}
+		}
+	}
+
+	return token, nil
+}
+
+// isTokenRevoked checks the token's own jti, its session (revoked sessions
+// denylist their sid) and the user's revocation cutoff.
+func isTokenRevoked(ctx context.Context, store repository.RevocationStore, jti string, sessionId string, userId string, claims jwt.MapClaims) (bool, error) {
+	for _, id := range []string{jti, sessionId} {
+		if id == "" {
+			continue
+		}
+		revoked, err := store.IsRevoked(ctx, id)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	issuedAt := time.Time{}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
+	}
+
+	return store.IsUserRevoked(ctx, userId, issuedAt)
+}
+
+// isSessionIdle double-checks an idle-looking session against the database,
+// where other instances flush the activity they saw.
+func isSessionIdle(ctx context.Context, cfg JWTConfig, sessionId string, now time.Time) (bool, error) {
+	if cfg.SessionIdleTimeout <= 0 {
+		return false, nil
+	}
+
+	lastSeen, ok, err := cfg.SessionActivity.LastSeen(ctx, sessionId)
+	if err != nil || !ok || now.Sub(lastSeen) <= cfg.SessionIdleTimeout {
+		return false, err
+	}
+
+	lastSeen, ok, err = cfg.SessionActivity.Reload(ctx, sessionId)
+	return ok && now.Sub(lastSeen) > cfg.SessionIdleTimeout, err
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// LoginAttempt counts recent failed logins for one throttling key, such as
+// an email address or a client IP.
+type LoginAttempt struct {
+	Key           string `gorm:"type:varchar(320);primaryKey"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
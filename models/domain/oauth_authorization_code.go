@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthAuthorizationCode is handed to a client through its redirect URI and
+// exchanged once at /oauth/token. Only a hash of the code is stored, with
+// the PKCE challenge it was bound to. Its Id becomes the id of the session
+// the exchange starts, so a replayed code can end that session.
+type OAuthAuthorizationCode struct {
+	Id            uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CodeHash      string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ClientId      string    `gorm:"type:varchar(64);not null;index"`
+	UserId        uuid.UUID `gorm:"type:uuid;not null"`
+	RedirectUri   string    `gorm:"type:text;not null"`
+	Scope         string    `gorm:"type:varchar(255)"`
+	CodeChallenge string    `gorm:"type:varchar(128);not null"`
+	AuthTime      time.Time
+	Amr           string    `gorm:"type:varchar(100)"`
+	Nonce         string    `gorm:"type:varchar(255)"`
+	ExpiresAt     time.Time `gorm:"not null"`
+	UsedAt        *time.Time
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthClient is an app registered to sign users in through /oauth/authorize.
+// RedirectUris holds one URI per line; a redirect_uri must equal one of them
+// exactly. Scopes are the space-separated scopes the client may ask for.
+// Confidential clients have a SecretHash and may use the client_credentials
+// grant; the secret is random, so a SHA-256 hash is enough.
+type OAuthClient struct {
+	Id           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ClientId     string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Name         string    `gorm:"type:varchar(100);not null"`
+	SecretHash   string    `gorm:"type:varchar(64)"`
+	RedirectUris string    `gorm:"type:text;not null"`
+	Scopes       string    `gorm:"type:varchar(255)"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthDeviceCode is a device authorization request (RFC 8628). The device
+// polls /oauth/token with the device code while the user types the short
+// user code into /device on another screen. Only hashes of both codes are
+// stored. Like an authorization code, its Id becomes the session id.
+type OAuthDeviceCode struct {
+	Id             uuid.UUID `gorm:"type:uuid;primaryKey"`
+	DeviceCodeHash string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserCodeHash   string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ClientId       string    `gorm:"type:varchar(64);not null;index"`
+	Scope          string    `gorm:"type:varchar(255)"`
+	// UserId is set once the user approved the request.
+	UserId     *uuid.UUID `gorm:"type:uuid"`
+	AuthTime   time.Time
+	Amr        string `gorm:"type:varchar(100)"`
+	ApprovedAt *time.Time
+	DeniedAt   *time.Time
+	UsedAt     *time.Time
+	// PollInterval is the minimum number of seconds between two polls. It
+	// grows each time the device polls too fast.
+	PollInterval int `gorm:"not null"`
+	LastPolledAt *time.Time
+	ExpiresAt    time.Time `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
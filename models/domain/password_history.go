@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordHistory keeps a hash the user had before, so it can't be chosen
+// again.
+type PasswordHistory struct {
+	Id           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserId       uuid.UUID `gorm:"type:uuid;not null;index"`
+	PasswordHash string    `gorm:"type:text;not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefreshToken struct {
+	Id           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserId       uuid.UUID `gorm:"type:uuid;not null;index"`
+	FamilyId     uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash    string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	RevokedAt    *time.Time
+	ReplacedById *uuid.UUID `gorm:"type:uuid"`
+	// AuthTime and Amr describe the last time the user proved who they are
+	// in this session, so refreshed access tokens keep the auth_time and
+	// amr claims.
+	AuthTime *time.Time
+	Amr      string `gorm:"type:varchar(100)"`
+	// ClientId and Scope are set on tokens issued to an OAuth client.
+	ClientId  string    `gorm:"type:varchar(64)"`
+	Scope     string    `gorm:"type:varchar(255)"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+type RevokedToken struct {
+	Jti       string    `gorm:"type:varchar(64);primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// UserRevocation rejects every token of a user issued at or before
+// IssuedBefore, e.g. after a password reset.
+type UserRevocation struct {
+	UserId       string    `gorm:"type:varchar(64);primaryKey"`
+	IssuedBefore time.Time `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one signed-in device. Its Id is the refresh token family id,
+// which access tokens carry as the "sid" claim, and Jti is the latest access
+// token issued to it. ExpiresAt follows the newest refresh token.
+// RememberMe sessions live longer and can only be refreshed with DeviceId.
+type Session struct {
+	Id         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserId     uuid.UUID `gorm:"type:uuid;not null;index"`
+	Device     string    `gorm:"type:varchar(100)"`
+	UserAgent  string    `gorm:"type:varchar(255)"`
+	IpAddress  string    `gorm:"type:varchar(45)"`
+	Jti        string    `gorm:"type:varchar(64)"`
+	RememberMe bool      `gorm:"not null;default:false"`
+	DeviceId   string    `gorm:"type:varchar(64);index"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	LastSeenAt time.Time
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+type SigningKey struct {
+	Kid           string    `gorm:"type:varchar(64);primaryKey"`
+	Algorithm     string    `gorm:"type:varchar(16);not null"`
+	PrivateKeyPem string    `gorm:"type:text;not null"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	ActivatesAt   time.Time
+	RetiredAt     *time.Time
+	ExpiresAt     *time.Time `gorm:"index"`
+}
@@ -8,13 +8,23 @@ import (
 )
 
 type User struct {
-	Id           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Email        string    `gorm:"type:varchar(255);unique;not null"`
-	PasswordHash string    `gorm:"type:text;not null"`
-	FullName     string    `gorm:"type:varchar(100);not null"`
-	IsVerified   bool      `gorm:"default:false"`
-	Role         string    `gorm:"type:varchar(50);default:'user'"`
-	LastLoginAt  *time.Time
+	Id              uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Email           string    `gorm:"type:varchar(255);unique;not null"`
+	PasswordHash    string    `gorm:"type:text;not null"`
+	FullName        string    `gorm:"type:varchar(100);not null"`
+	IsVerified      bool      `gorm:"default:false"`
+	Role            string    `gorm:"type:varchar(50);default:'user'"`
+	MfaEnabled      bool      `gorm:"default:false"`
+	MfaSecret       string    `gorm:"type:varchar(64)"`
+	MfaLastUsedStep int64     `gorm:"default:0"`
+	LastLoginAt     *time.Time
+	// MustChangePassword limits the user's tokens to changing the password
+	// until a new one is set.
+	MustChangePassword bool `gorm:"default:false"`
+	PasswordChangedAt  *time.Time
+	// TokenVersion is embedded in access tokens as "ver". Bumping it
+	// invalidates every token issued before.
+	TokenVersion int            `gorm:"not null;default:0"`
 	CreatedAt    time.Time      `gorm:"autoCreateTime"`
 	UpdatedAt    time.Time      `gorm:"autoCreateTime;autoUpdateTime"`
 	DeletedAt    gorm.DeletedAt `gorm:"index"`
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserTokenEmailVerification = "email_verification"
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenMfaChallenge      = "mfa_challenge"
+	UserTokenWebAuthnRegister  = "webauthn_register"
+	UserTokenWebAuthnSignup    = "webauthn_signup"
+	UserTokenWebAuthnLogin     = "webauthn_login"
+	UserTokenPasswordlessLink  = "passwordless_link"
+	UserTokenPasswordlessCode  = "passwordless_code"
+)
+
+// UserToken is a single-use, expiring token sent to a user out of band.
+// WebAuthn challenges are stored the same way; a login challenge issued
+// before the user is known has a nil UserId. Challenges issued without
+// signing in keep the address that asked for them, and signup challenges
+// the email the account will be created with.
+type UserToken struct {
+	Id        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserId    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Purpose   string    `gorm:"type:varchar(50);not null;index"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Email     string    `gorm:"type:varchar(255)"`
+	ClientIp  string    `gorm:"type:varchar(45);index"`
+	ExpiresAt time.Time `gorm:"not null"`
+	Attempts  int       `gorm:"default:0"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user.
+// PublicKey holds the COSE encoded key exactly as the authenticator sent it.
+type WebAuthnCredential struct {
+	Id           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserId       uuid.UUID `gorm:"type:uuid;not null;index"`
+	CredentialId string    `gorm:"type:text;uniqueIndex;not null"`
+	PublicKey    []byte    `gorm:"not null"`
+	SignCount    int64     `gorm:"default:0"`
+	Name         string    `gorm:"type:varchar(100)"`
+	LastUsedAt   *time.Time
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,9 @@
+package web
+
+type AuthChangePasswordRequest struct {
+	UserId          string `json:"-"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+	ClientIp        string `json:"-"`
+	UserAgent       string `json:"-"`
+}
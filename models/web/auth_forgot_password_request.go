@@ -0,0 +1,5 @@
+package web
+
+type AuthForgotPasswordRequest struct {
+	Email string `validate:"required,email"`
+}
@@ -3,4 +3,13 @@ package web
 type AuthLoginRequest struct {
 	Email    string `validate:"required,email"`
 	Password string `validate:"required"`
+	// Device optionally names the device in the session list, instead of
+	// the name derived from the User-Agent header.
+	Device string `json:"device" validate:"max=100"`
+	// RememberMe asks for a long-lived session bound to DeviceId. A device
+	// id is generated when none is sent, and must be sent back on refresh.
+	RememberMe bool   `json:"remember_me"`
+	DeviceId   string `json:"device_id" validate:"omitempty,max=64,printascii"`
+	ClientIp   string `json:"-"`
+	UserAgent  string `json:"-"`
 }
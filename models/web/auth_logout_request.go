@@ -0,0 +1,11 @@
+package web
+
+import "time"
+
+type AuthLogoutRequest struct {
+	UserId         string    `json:"-"`
+	SessionId      string    `json:"-"`
+	Jti            string    `json:"-"`
+	TokenExpiresAt time.Time `json:"-"`
+	RefreshToken   string    `json:"refresh_token"`
+}
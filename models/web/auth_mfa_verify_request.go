@@ -0,0 +1,12 @@
+package web
+
+type AuthMfaVerifyRequest struct {
+	MfaToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+	// RememberMe and DeviceId repeat the flags sent to /auth/login.
+	RememberMe bool   `json:"remember_me"`
+	DeviceId   string `json:"device_id" validate:"omitempty,max=64,printascii"`
+	ClientIp   string `json:"-"`
+	UserAgent  string `json:"-"`
+}
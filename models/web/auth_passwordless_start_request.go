@@ -0,0 +1,8 @@
+package web
+
+type AuthPasswordlessStartRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	// Method is "link" (the default) to email a sign-in link, or "code" to
+	// email a 6-digit code to type into the app that asked for it.
+	Method string `json:"method" validate:"omitempty,oneof=link code"`
+}
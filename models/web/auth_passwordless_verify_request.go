@@ -0,0 +1,9 @@
+package web
+
+type AuthPasswordlessVerifyRequest struct {
+	Token     string `json:"token" validate:"required_without=Code"`
+	Email     string `json:"email" validate:"required_with=Code,omitempty,email"`
+	Code      string `json:"code" validate:"omitempty,len=6,numeric"`
+	ClientIp  string `json:"-"`
+	UserAgent string `json:"-"`
+}
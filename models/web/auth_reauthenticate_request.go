@@ -0,0 +1,10 @@
+package web
+
+type AuthReauthenticateRequest struct {
+	UserId       string `json:"-"`
+	SessionId    string `json:"-"`
+	Password     string `json:"password" validate:"required_without_all=Code RecoveryCode"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	ClientIp     string `json:"-"`
+}
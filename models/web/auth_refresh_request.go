@@ -0,0 +1,7 @@
+package web
+
+type AuthRefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+	// DeviceId is required for sessions started with remember_me.
+	DeviceId string `json:"device_id"`
+}
@@ -0,0 +1,5 @@
+package web
+
+type AuthResendVerificationRequest struct {
+	Email string `validate:"required,email"`
+}
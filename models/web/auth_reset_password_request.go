@@ -0,0 +1,6 @@
+package web
+
+type AuthResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
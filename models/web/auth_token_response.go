@@ -0,0 +1,22 @@
+package web
+
+type AuthTokenResponse struct {
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	// RefreshExpiresIn is how long RefreshToken stays valid. DeviceId is
+	// set when the session was remembered and must be kept by the client.
+	RefreshExpiresIn int64  `json:"refresh_expires_in,omitempty"`
+	DeviceId         string `json:"device_id,omitempty"`
+	// MfaRequired means the password was correct but a second factor must
+	// be sent to /auth/mfa/verify together with MfaToken.
+	MfaRequired bool   `json:"mfa_required,omitempty"`
+	MfaToken    string `json:"mfa_token,omitempty"`
+	// MfaEnrollmentRequired means Token is only accepted by the
+	// /users/me/mfa enrollment endpoints until MFA has been set up.
+	MfaEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+	// MustChangePassword means Token is only accepted by
+	// POST /users/me/password until a new password has been set.
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+}
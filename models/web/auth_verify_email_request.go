@@ -0,0 +1,5 @@
+package web
+
+type AuthVerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,8 @@
+package web
+
+type MfaCodeRequest struct {
+	UserId       string `json:"-"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+	ClientIp     string `json:"-"`
+}
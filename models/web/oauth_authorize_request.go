@@ -0,0 +1,24 @@
+package web
+
+// OAuthAuthorizeRequest is read from the query of GET /oauth/authorize and
+// sent back as hidden fields by the login page, together with what the
+// user typed in.
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `query:"response_type" form:"response_type"`
+	ClientId            string `query:"client_id" form:"client_id"`
+	RedirectUri         string `query:"redirect_uri" form:"redirect_uri"`
+	Scope               string `query:"scope" form:"scope"`
+	State               string `query:"state" form:"state"`
+	CodeChallenge       string `query:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" form:"code_challenge_method"`
+	// Nonce is copied into the id_token so the client can detect replays.
+	Nonce    string `query:"nonce" form:"nonce"`
+	Email    string `query:"-" form:"email"`
+	Password string `query:"-" form:"password"`
+	// Code is a TOTP or recovery code, asked for when the user has MFA.
+	Code string `query:"-" form:"code"`
+	// Decision is "allow" or "deny".
+	Decision  string `query:"-" form:"decision"`
+	ClientIp  string `query:"-" form:"-"`
+	UserAgent string `query:"-" form:"-"`
+}
@@ -0,0 +1,11 @@
+package web
+
+type OAuthClientCreateRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Confidential clients get a secret. Redirect URIs are only needed for
+	// /oauth/authorize; machines and CLIs using the client_credentials or
+	// device grant have none.
+	Confidential bool     `json:"confidential"`
+	RedirectUris []string `json:"redirect_uris" validate:"dive,required,url"`
+	Scopes       []string `json:"scopes" validate:"dive,required,max=50,printascii,excludesall= "`
+}
@@ -0,0 +1,14 @@
+package web
+
+import "time"
+
+type OAuthClientResponse struct {
+	ClientId string `json:"client_id"`
+	// ClientSecret is only returned when the client is created.
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Confidential bool      `json:"confidential"`
+	Name         string    `json:"name"`
+	RedirectUris []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,8 @@
+package web
+
+// OAuthConsentResponse is what the login page shows about the request.
+type OAuthConsentResponse struct {
+	ClientId   string
+	ClientName string
+	Scopes     []string
+}
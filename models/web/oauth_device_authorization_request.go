@@ -0,0 +1,8 @@
+package web
+
+type OAuthDeviceAuthorizationRequest struct {
+	ClientId string `json:"client_id" form:"client_id"`
+	// ClientSecret may also be sent with HTTP Basic authentication.
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	Scope        string `json:"scope" form:"scope"`
+}
@@ -0,0 +1,12 @@
+package web
+
+// OAuthDeviceAuthorizationResponse is the RFC 8628 device authorization
+// response, sent without the web.WebResponse envelope.
+type OAuthDeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
@@ -0,0 +1,15 @@
+package web
+
+// OAuthDeviceVerifyRequest is sent by the /device page, where the user
+// types in the code shown by the device and signs in to approve it.
+type OAuthDeviceVerifyRequest struct {
+	UserCode string `query:"user_code" form:"user_code"`
+	Email    string `query:"-" form:"email"`
+	Password string `query:"-" form:"password"`
+	// Code is a TOTP or recovery code, asked for when the user has MFA.
+	Code string `query:"-" form:"code"`
+	// Decision is "allow" or "deny".
+	Decision  string `query:"-" form:"decision"`
+	ClientIp  string `query:"-" form:"-"`
+	UserAgent string `query:"-" form:"-"`
+}
@@ -0,0 +1,11 @@
+package web
+
+type OAuthIntrospectRequest struct {
+	Token string `json:"token" form:"token"`
+	// TokenTypeHint is accepted for RFC 7662 clients but not needed: access
+	// tokens are JWTs and refresh tokens are opaque.
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+	ClientId      string `json:"client_id" form:"client_id"`
+	// ClientSecret may also be sent with HTTP Basic authentication.
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+}
@@ -0,0 +1,9 @@
+package web
+
+type OAuthRevokeRequest struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+	ClientId      string `json:"client_id" form:"client_id"`
+	// ClientSecret may also be sent with HTTP Basic authentication.
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+}
@@ -0,0 +1,16 @@
+package web
+
+type OAuthTokenRequest struct {
+	GrantType string `json:"grant_type" form:"grant_type"`
+	ClientId  string `json:"client_id" form:"client_id"`
+	// ClientSecret may also be sent with HTTP Basic authentication.
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	Scope        string `json:"scope" form:"scope"`
+	Code         string `json:"code" form:"code"`
+	RedirectUri  string `json:"redirect_uri" form:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	DeviceCode   string `json:"device_code" form:"device_code"`
+	ClientIp     string `json:"-" form:"-"`
+	UserAgent    string `json:"-" form:"-"`
+}
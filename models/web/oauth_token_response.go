@@ -0,0 +1,14 @@
+package web
+
+// OAuthTokenResponse is the RFC 6749 token response. It is sent as is,
+// without the web.WebResponse envelope, so OAuth libraries can read it.
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// IdToken is the OpenID Connect ID token, when the openid scope was
+	// granted.
+	IdToken string `json:"id_token,omitempty"`
+}
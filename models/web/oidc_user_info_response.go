@@ -0,0 +1,10 @@
+package web
+
+// OIDCUserInfoResponse holds the standard OpenID Connect claims of a user.
+// Claims outside the granted scopes are left empty and omitted.
+type OIDCUserInfoResponse struct {
+	Sub           string `json:"sub"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+}
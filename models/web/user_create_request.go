@@ -5,4 +5,7 @@ type UserCreateRequest struct {
 	Password string `validate:"required"`
 	FullName string `validate:"required"`
 	Role     string `validate:"omitempty,oneof=user admin"`
+	// MustChangePassword makes the user replace the initial password on
+	// the first login.
+	MustChangePassword bool
 }
@@ -0,0 +1,30 @@
+package web
+
+import "io"
+
+// UserImportRequest carries an NDJSON or CSV export from another identity
+// provider. Format may be left empty when Filename ends in .csv, .ndjson,
+// .jsonl or .json.
+type UserImportRequest struct {
+	Format   string    `form:"format" validate:"omitempty,oneof=ndjson csv"`
+	DryRun   bool      `form:"dry_run"`
+	Filename string    `form:"-"`
+	Data     io.Reader `form:"-" validate:"required"`
+
+	// Project-wide parameters of a Firebase password export.
+	FirebaseSignerKey     string `form:"firebase_signer_key"`
+	FirebaseSaltSeparator string `form:"firebase_salt_separator"`
+	FirebaseRounds        int    `form:"firebase_rounds"`
+	FirebaseMemCost       int    `form:"firebase_mem_cost"`
+}
+
+// UserImportRow is one user read from an import file.
+type UserImportRow struct {
+	Email         string `validate:"required,email"`
+	FullName      string `validate:"max=100"`
+	Role          string `validate:"oneof=user admin"`
+	EmailVerified bool
+	PasswordHash  string
+	PasswordSalt  string
+	HashAlgorithm string
+}
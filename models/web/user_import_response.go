@@ -0,0 +1,16 @@
+package web
+
+type UserImportResponse struct {
+	DryRun bool `json:"dry_run"`
+	Total  int  `json:"total"`
+	// Imported counts the rows that would be imported on a dry run.
+	Imported int                  `json:"imported"`
+	Failed   int                  `json:"failed"`
+	Errors   []UserImportRowError `json:"errors"`
+}
+
+type UserImportRowError struct {
+	Row   int    `json:"row"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
@@ -5,7 +5,9 @@ import "github.com/google/uuid"
 type UserUpdateRequest struct {
 	Id           uuid.UUID
 	Email        string `validate:"omitempty,email"`
-	PasswordHash string `validate:"omitempty,min=6"`
+	PasswordHash string `validate:"omitempty"`
 	FullName     string `validate:"omitempty"`
 	Role         string `validate:"omitempty,oneof=user admin"`
+	// MustChangePassword is only honoured for admins.
+	MustChangePassword *bool
 }
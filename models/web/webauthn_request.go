@@ -0,0 +1,57 @@
+package web
+
+// PublicKeyCredential fields use the names produced by the browser's
+// PublicKeyCredential.toJSON(); binary values are base64url encoded.
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+	AttestationObject string `json:"attestationObject" validate:"required"`
+}
+
+type WebAuthnAttestation struct {
+	Id       string                           `json:"id" validate:"required"`
+	Type     string                           `json:"type" validate:"required,eq=public-key"`
+	Response AuthenticatorAttestationResponse `json:"response"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+	AuthenticatorData string `json:"authenticatorData" validate:"required"`
+	Signature         string `json:"signature" validate:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+type WebAuthnAssertion struct {
+	Id       string                         `json:"id" validate:"required"`
+	Type     string                         `json:"type" validate:"required,eq=public-key"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+type WebAuthnRegisterRequest struct {
+	UserId     string              `json:"-"`
+	Name       string              `json:"name" validate:"max=100"`
+	Credential WebAuthnAttestation `json:"credential"`
+}
+
+type WebAuthnSignupOptionsRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	FullName string `json:"full_name" validate:"required"`
+	ClientIp string `json:"-"`
+}
+
+type WebAuthnLoginOptionsRequest struct {
+	ClientIp string `json:"-"`
+}
+
+type AuthPasskeySignupRequest struct {
+	Email      string              `json:"email" validate:"required,email"`
+	FullName   string              `json:"full_name" validate:"required"`
+	Name       string              `json:"name" validate:"max=100"`
+	Credential WebAuthnAttestation `json:"credential"`
+}
+
+type AuthPasskeyLoginRequest struct {
+	Credential WebAuthnAssertion `json:"credential"`
+	ClientIp   string            `json:"-"`
+	UserAgent  string            `json:"-"`
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LoginAttemptRepository interface {
+	Find(ctx context.Context, tx *gorm.DB, key string) (domain.LoginAttempt, error)
+	RecordFailure(ctx context.Context, tx *gorm.DB, key string, at time.Time) (domain.LoginAttempt, error)
+	Lock(ctx context.Context, tx *gorm.DB, key string, until time.Time) error
+	Reset(ctx context.Context, tx *gorm.DB, key string) error
+}
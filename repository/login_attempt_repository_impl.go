@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginAttemptRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &LoginAttemptRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *LoginAttemptRepositoryImpl) Find(ctx context.Context, tx *gorm.DB, key string) (domain.LoginAttempt, error) {
+	var attempt domain.LoginAttempt
+	err := tx.WithContext(ctx).Where("key = ?", key).First(&attempt).Error
+
+	return attempt, err
+}
+
+// RecordFailure increments the counter in a single statement, so parallel
+// guesses cannot overwrite each other's count.
+func (repository *LoginAttemptRepositoryImpl) RecordFailure(ctx context.Context, tx *gorm.DB, key string, at time.Time) (domain.LoginAttempt, error) {
+	err := tx.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":        gorm.Expr("login_attempts.failures + 1"),
+			"last_failure_at": at,
+		}),
+	}).Create(&domain.LoginAttempt{
+		Key:           key,
+		Failures:      1,
+		LastFailureAt: at,
+	}).Error
+	if err != nil {
+		return domain.LoginAttempt{}, err
+	}
+
+	return repository.Find(ctx, tx, key)
+}
+
+func (repository *LoginAttemptRepositoryImpl) Lock(ctx context.Context, tx *gorm.DB, key string, until time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.LoginAttempt{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+func (repository *LoginAttemptRepositoryImpl) Reset(ctx context.Context, tx *gorm.DB, key string) error {
+	return tx.WithContext(ctx).Where("key = ?", key).Delete(&domain.LoginAttempt{}).Error
+}
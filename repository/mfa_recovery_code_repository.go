@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MfaRecoveryCodeRepository interface {
+	ReplaceForUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, codeHashes []string) error
+	Use(ctx context.Context, tx *gorm.DB, userId uuid.UUID, codeHash string, usedAt time.Time) (bool, error)
+	DeleteByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID) error
+}
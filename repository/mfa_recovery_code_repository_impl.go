@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MfaRecoveryCodeRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewMfaRecoveryCodeRepository(db *gorm.DB) MfaRecoveryCodeRepository {
+	return &MfaRecoveryCodeRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *MfaRecoveryCodeRepositoryImpl) ReplaceForUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, codeHashes []string) error {
+	if err := repository.DeleteByUser(ctx, tx, userId); err != nil {
+		return err
+	}
+
+	codes := make([]domain.MfaRecoveryCode, 0, len(codeHashes))
+	for _, codeHash := range codeHashes {
+		codes = append(codes, domain.MfaRecoveryCode{
+			Id:       uuid.New(),
+			UserId:   userId,
+			CodeHash: codeHash,
+		})
+	}
+
+	return tx.WithContext(ctx).Create(&codes).Error
+}
+
+func (repository *MfaRecoveryCodeRepositoryImpl) Use(ctx context.Context, tx *gorm.DB, userId uuid.UUID, codeHash string, usedAt time.Time) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.MfaRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).
+		Update("used_at", usedAt)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repository *MfaRecoveryCodeRepositoryImpl) DeleteByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID) error {
+	return tx.WithContext(ctx).Where("user_id = ?", userId).Delete(&domain.MfaRecoveryCode{}).Error
+}
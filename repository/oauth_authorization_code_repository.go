@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OAuthAuthorizationCodeRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, code domain.OAuthAuthorizationCode) (domain.OAuthAuthorizationCode, error)
+	FindByCodeHash(ctx context.Context, tx *gorm.DB, codeHash string) (domain.OAuthAuthorizationCode, error)
+	MarkUsed(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, usedAt time.Time) (bool, error)
+}
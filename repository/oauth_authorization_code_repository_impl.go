@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OAuthAuthorizationCodeRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewOAuthAuthorizationCodeRepository(db *gorm.DB) OAuthAuthorizationCodeRepository {
+	return &OAuthAuthorizationCodeRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *OAuthAuthorizationCodeRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, code domain.OAuthAuthorizationCode) (domain.OAuthAuthorizationCode, error) {
+	if code.Id == uuid.Nil {
+		code.Id = uuid.New()
+	}
+
+	err := tx.WithContext(ctx).Create(&code).Error
+	return code, err
+}
+
+func (repository *OAuthAuthorizationCodeRepositoryImpl) FindByCodeHash(ctx context.Context, tx *gorm.DB, codeHash string) (domain.OAuthAuthorizationCode, error) {
+	var code domain.OAuthAuthorizationCode
+	err := tx.WithContext(ctx).Where("code_hash = ?", codeHash).First(&code).Error
+
+	return code, err
+}
+
+func (repository *OAuthAuthorizationCodeRepositoryImpl) MarkUsed(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, usedAt time.Time) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.OAuthAuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", codeId).
+		Update("used_at", usedAt)
+
+	return result.RowsAffected == 1, result.Error
+}
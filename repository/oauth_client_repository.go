@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type OAuthClientRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, client domain.OAuthClient) (domain.OAuthClient, error)
+	FindByClientId(ctx context.Context, tx *gorm.DB, clientId string) (domain.OAuthClient, error)
+	FindAll(ctx context.Context, tx *gorm.DB) ([]domain.OAuthClient, error)
+	Delete(ctx context.Context, tx *gorm.DB, clientId string) (bool, error)
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OAuthClientRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	return &OAuthClientRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *OAuthClientRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, client domain.OAuthClient) (domain.OAuthClient, error) {
+	if client.Id == uuid.Nil {
+		client.Id = uuid.New()
+	}
+
+	err := tx.WithContext(ctx).Create(&client).Error
+	return client, err
+}
+
+func (repository *OAuthClientRepositoryImpl) FindByClientId(ctx context.Context, tx *gorm.DB, clientId string) (domain.OAuthClient, error) {
+	var client domain.OAuthClient
+	err := tx.WithContext(ctx).Where("client_id = ?", clientId).First(&client).Error
+
+	return client, err
+}
+
+func (repository *OAuthClientRepositoryImpl) FindAll(ctx context.Context, tx *gorm.DB) ([]domain.OAuthClient, error) {
+	var clients []domain.OAuthClient
+	err := tx.WithContext(ctx).Order("created_at").Find(&clients).Error
+
+	return clients, err
+}
+
+func (repository *OAuthClientRepositoryImpl) Delete(ctx context.Context, tx *gorm.DB, clientId string) (bool, error) {
+	result := tx.WithContext(ctx).Where("client_id = ?", clientId).Delete(&domain.OAuthClient{})
+
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OAuthDeviceCodeRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, code domain.OAuthDeviceCode) (domain.OAuthDeviceCode, error)
+	FindByDeviceCodeHash(ctx context.Context, tx *gorm.DB, deviceCodeHash string) (domain.OAuthDeviceCode, error)
+	FindByUserCodeHash(ctx context.Context, tx *gorm.DB, userCodeHash string) (domain.OAuthDeviceCode, error)
+	// Approve and Deny only change a request nobody has answered yet.
+	Approve(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, userId uuid.UUID, amr string, approvedAt time.Time) (bool, error)
+	Deny(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, deniedAt time.Time) (bool, error)
+	MarkPolled(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, interval int, polledAt time.Time) error
+	MarkUsed(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, usedAt time.Time) (bool, error)
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OAuthDeviceCodeRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewOAuthDeviceCodeRepository(db *gorm.DB) OAuthDeviceCodeRepository {
+	return &OAuthDeviceCodeRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *OAuthDeviceCodeRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, code domain.OAuthDeviceCode) (domain.OAuthDeviceCode, error) {
+	if code.Id == uuid.Nil {
+		code.Id = uuid.New()
+	}
+
+	err := tx.WithContext(ctx).Create(&code).Error
+	return code, err
+}
+
+func (repository *OAuthDeviceCodeRepositoryImpl) FindByDeviceCodeHash(ctx context.Context, tx *gorm.DB, deviceCodeHash string) (domain.OAuthDeviceCode, error) {
+	var code domain.OAuthDeviceCode
+	err := tx.WithContext(ctx).Where("device_code_hash = ?", deviceCodeHash).First(&code).Error
+
+	return code, err
+}
+
+func (repository *OAuthDeviceCodeRepositoryImpl) FindByUserCodeHash(ctx context.Context, tx *gorm.DB, userCodeHash string) (domain.OAuthDeviceCode, error) {
+	var code domain.OAuthDeviceCode
+	err := tx.WithContext(ctx).Where("user_code_hash = ?", userCodeHash).First(&code).Error
+
+	return code, err
+}
+
+func (repository *OAuthDeviceCodeRepositoryImpl) Approve(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, userId uuid.UUID, amr string, approvedAt time.Time) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.OAuthDeviceCode{}).
+		Where("id = ? AND approved_at IS NULL AND denied_at IS NULL", codeId).
+		Updates(map[string]interface{}{
+			"user_id":     userId,
+			"auth_time":   approvedAt,
+			"amr":         amr,
+			"approved_at": approvedAt,
+		})
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repository *OAuthDeviceCodeRepositoryImpl) Deny(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, deniedAt time.Time) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.OAuthDeviceCode{}).
+		Where("id = ? AND approved_at IS NULL AND denied_at IS NULL", codeId).
+		Update("denied_at", deniedAt)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repository *OAuthDeviceCodeRepositoryImpl) MarkPolled(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, interval int, polledAt time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.OAuthDeviceCode{}).
+		Where("id = ?", codeId).
+		Updates(map[string]interface{}{
+			"poll_interval":  interval,
+			"last_polled_at": polledAt,
+		}).Error
+}
+
+func (repository *OAuthDeviceCodeRepositoryImpl) MarkUsed(ctx context.Context, tx *gorm.DB, codeId uuid.UUID, usedAt time.Time) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.OAuthDeviceCode{}).
+		Where("id = ? AND used_at IS NULL", codeId).
+		Update("used_at", usedAt)
+
+	return result.RowsAffected == 1, result.Error
+}
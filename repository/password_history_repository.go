@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, entry domain.PasswordHistory) (domain.PasswordHistory, error)
+	FindRecentByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, limit int) ([]domain.PasswordHistory, error)
+	PruneByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, keep int) error
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &PasswordHistoryRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *PasswordHistoryRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, entry domain.PasswordHistory) (domain.PasswordHistory, error) {
+	if entry.Id == uuid.Nil {
+		entry.Id = uuid.New()
+	}
+
+	err := tx.WithContext(ctx).Create(&entry).Error
+	return entry, err
+}
+
+// FindRecentByUser returns up to limit previous hashes, newest first.
+func (repository *PasswordHistoryRepositoryImpl) FindRecentByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, limit int) ([]domain.PasswordHistory, error) {
+	var entries []domain.PasswordHistory
+	err := tx.WithContext(ctx).
+		Where("user_id = ?", userId).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&entries).Error
+
+	return entries, err
+}
+
+// PruneByUser deletes everything but the keep newest hashes of the user.
+func (repository *PasswordHistoryRepositoryImpl) PruneByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, keep int) error {
+	recent, err := repository.FindRecentByUser(ctx, tx, userId, keep)
+	if err != nil {
+		return err
+	}
+
+	query := tx.WithContext(ctx).Where("user_id = ?", userId)
+	if len(recent) > 0 {
+		ids := make([]uuid.UUID, 0, len(recent))
+		for _, entry := range recent {
+			ids = append(ids, entry.Id)
+		}
+		query = query.Where("id NOT IN ?", ids)
+	}
+
+	return query.Delete(&domain.PasswordHistory{}).Error
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, token domain.RefreshToken) (domain.RefreshToken, error)
+	FindByTokenHash(ctx context.Context, tx *gorm.DB, tokenHash string) (domain.RefreshToken, error)
+	MarkRotated(ctx context.Context, tx *gorm.DB, tokenId uuid.UUID, replacedById uuid.UUID, rotatedAt time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, tx *gorm.DB, familyId uuid.UUID, revokedAt time.Time) error
+	RevokeByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, revokedAt time.Time) error
+	FindActiveFamiliesByClient(ctx context.Context, tx *gorm.DB, clientId string) ([]uuid.UUID, error)
+	UpdateAuthentication(ctx context.Context, tx *gorm.DB, userId uuid.UUID, familyId uuid.UUID, authTime time.Time, amr string) (bool, error)
+}
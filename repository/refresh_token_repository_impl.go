@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &RefreshTokenRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *RefreshTokenRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, token domain.RefreshToken) (domain.RefreshToken, error) {
+	if token.Id == uuid.Nil {
+		token.Id = uuid.New()
+	}
+
+	err := tx.WithContext(ctx).Create(&token).Error
+	return token, err
+}
+
+func (repository *RefreshTokenRepositoryImpl) FindByTokenHash(ctx context.Context, tx *gorm.DB, tokenHash string) (domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	err := tx.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+
+	return token, err
+}
+
+// MarkRotated only succeeds for a token that is still active, so two
+// concurrent refreshes with the same token cannot both win.
+func (repository *RefreshTokenRepositoryImpl) MarkRotated(ctx context.Context, tx *gorm.DB, tokenId uuid.UUID, replacedById uuid.UUID, rotatedAt time.Time) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", tokenId).
+		Updates(map[string]interface{}{
+			"revoked_at":     rotatedAt,
+			"replaced_by_id": replacedById,
+		})
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repository *RefreshTokenRepositoryImpl) RevokeFamily(ctx context.Context, tx *gorm.DB, familyId uuid.UUID, revokedAt time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", revokedAt).Error
+}
+
+func (repository *RefreshTokenRepositoryImpl) RevokeByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, revokedAt time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", revokedAt).Error
+}
+
+// FindActiveFamiliesByClient returns the sessions that still have a usable
+// refresh token issued to clientId.
+func (repository *RefreshTokenRepositoryImpl) FindActiveFamiliesByClient(ctx context.Context, tx *gorm.DB, clientId string) ([]uuid.UUID, error) {
+	var familyIds []uuid.UUID
+	err := tx.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("client_id = ? AND revoked_at IS NULL", clientId).
+		Distinct().Pluck("family_id", &familyIds).Error
+
+	return familyIds, err
+}
+
+// UpdateAuthentication records a re-authentication on the active token of
+// the family. It reports false when the session has ended.
+func (repository *RefreshTokenRepositoryImpl) UpdateAuthentication(ctx context.Context, tx *gorm.DB, userId uuid.UUID, familyId uuid.UUID, authTime time.Time, amr string) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.RefreshToken{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userId, familyId).
+		Updates(map[string]interface{}{
+			"auth_time": authTime,
+			"amr":       amr,
+		})
+
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RevocationStore is a denylist of access token ids (jti) and revoked
+// session ids (sid), plus per-user cutoffs that reject every token issued before a point in time. Entries
+// only need to live until the tokens they revoke would have expired anyway.
+//
+// iat only has second precision, so cutoffs are truncated to the second:
+// tokens issued in the same second as the cutoff stay valid, otherwise a
+// sign-in right after a password reset would be revoked with the rest.
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeUser(ctx context.Context, userId string, issuedBefore time.Time, expiresAt time.Time) error
+	IsUserRevoked(ctx context.Context, userId string, issuedAt time.Time) (bool, error)
+	PurgeExpired(ctx context.Context) error
+}
+
+func StartRevocationPurge(ctx context.Context, store RevocationStore, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := store.PurgeExpired(ctx); err != nil {
+					log.Println("Purge revoked tokens fail:", err)
+				}
+			}
+		}
+	}()
+}
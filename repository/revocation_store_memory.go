@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type userCutoff struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+type MemoryRevocationStore struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time
+	users   map[string]userCutoff
+}
+
+func NewMemoryRevocationStore() RevocationStore {
+	return &MemoryRevocationStore{
+		entries: map[string]time.Time{},
+		users:   map[string]userCutoff{},
+	}
+}
+
+func (store *MemoryRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.entries[jti] = expiresAt
+	return nil
+}
+
+func (store *MemoryRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	expiresAt, ok := store.entries[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (store *MemoryRevocationStore) RevokeUser(ctx context.Context, userId string, issuedBefore time.Time, expiresAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.users[userId] = userCutoff{issuedBefore: issuedBefore.Truncate(time.Second), expiresAt: expiresAt}
+	return nil
+}
+
+func (store *MemoryRevocationStore) IsUserRevoked(ctx context.Context, userId string, issuedAt time.Time) (bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	cutoff, ok := store.users[userId]
+	if !ok || !time.Now().Before(cutoff.expiresAt) {
+		return false, nil
+	}
+
+	return issuedAt.Before(cutoff.issuedBefore), nil
+}
+
+func (store *MemoryRevocationStore) PurgeExpired(ctx context.Context) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	for jti, expiresAt := range store.entries {
+		if !now.Before(expiresAt) {
+			delete(store.entries, jti)
+		}
+	}
+
+	for userId, cutoff := range store.users {
+		if !now.Before(cutoff.expiresAt) {
+			delete(store.users, userId)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SQLRevocationStore struct {
+	DB *gorm.DB
+}
+
+func NewSQLRevocationStore(db *gorm.DB) RevocationStore {
+	return &SQLRevocationStore{
+		DB: db,
+	}
+}
+
+func (store *SQLRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	return store.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.RevokedToken{
+		Jti:       jti,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func (store *SQLRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := store.DB.WithContext(ctx).Model(&domain.RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+func (store *SQLRevocationStore) RevokeUser(ctx context.Context, userId string, issuedBefore time.Time, expiresAt time.Time) error {
+	return store.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"issued_before", "expires_at"}),
+	}).Create(&domain.UserRevocation{
+		UserId:       userId,
+		IssuedBefore: issuedBefore.Truncate(time.Second),
+		ExpiresAt:    expiresAt,
+	}).Error
+}
+
+func (store *SQLRevocationStore) IsUserRevoked(ctx context.Context, userId string, issuedAt time.Time) (bool, error) {
+	var count int64
+	err := store.DB.WithContext(ctx).Model(&domain.UserRevocation{}).
+		Where("user_id = ? AND issued_before > ? AND expires_at > ?", userId, issuedAt, time.Now()).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+func (store *SQLRevocationStore) PurgeExpired(ctx context.Context) error {
+	now := time.Now()
+
+	if err := store.DB.WithContext(ctx).Where("expires_at <= ?", now).Delete(&domain.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	return store.DB.WithContext(ctx).Where("expires_at <= ?", now).Delete(&domain.UserRevocation{}).Error
+}
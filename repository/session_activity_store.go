@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SessionActivityStore tracks when each session was last used. Touch only
+// records the time in memory; Flush writes the pending times to the
+// sessions table in one batch, so requests don't each cause a write.
+type SessionActivityStore interface {
+	Touch(sessionId string, at time.Time)
+	// LastSeen returns the latest known activity of the session. ok is false
+	// for unknown sessions, like those issued before sessions were tracked.
+	LastSeen(ctx context.Context, sessionId string) (lastSeen time.Time, ok bool, err error)
+	// Reload drops the cached time and reads it from the database, which
+	// holds activity flushed by other instances. ok is false for remembered
+	// sessions, which don't expire from inactivity.
+	Reload(ctx context.Context, sessionId string) (lastSeen time.Time, ok bool, err error)
+	Flush(ctx context.Context) error
+}
+
+func StartSessionActivityFlush(ctx context.Context, store SessionActivityStore, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := store.Flush(ctx); err != nil {
+					log.Println("Flush session activity fail:", err)
+				}
+			}
+		}
+	}()
+}
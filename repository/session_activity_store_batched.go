@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type BatchedSessionActivityStore struct {
+	DB *gorm.DB
+
+	mu      sync.Mutex
+	known   map[string]time.Time
+	pending map[string]time.Time
+}
+
+func NewBatchedSessionActivityStore(db *gorm.DB) SessionActivityStore {
+	return &BatchedSessionActivityStore{
+		DB:      db,
+		known:   map[string]time.Time{},
+		pending: map[string]time.Time{},
+	}
+}
+
+func (store *BatchedSessionActivityStore) Touch(sessionId string, at time.Time) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if at.After(store.known[sessionId]) {
+		store.known[sessionId] = at
+		store.pending[sessionId] = at
+	}
+}
+
+func (store *BatchedSessionActivityStore) LastSeen(ctx context.Context, sessionId string) (time.Time, bool, error) {
+	store.mu.Lock()
+	lastSeen, ok := store.known[sessionId]
+	store.mu.Unlock()
+	if ok {
+		return lastSeen, true, nil
+	}
+
+	return store.Reload(ctx, sessionId)
+}
+
+func (store *BatchedSessionActivityStore) Reload(ctx context.Context, sessionId string) (time.Time, bool, error) {
+	var session domain.Session
+	err := store.DB.WithContext(ctx).Select("last_seen_at", "remember_me").Where("id = ?", sessionId).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if session.RememberMe {
+		return time.Time{}, false, nil
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// Activity seen here but not flushed yet is newer than the database.
+	if pending, ok := store.pending[sessionId]; ok && pending.After(session.LastSeenAt) {
+		return pending, true, nil
+	}
+	store.known[sessionId] = session.LastSeenAt
+
+	return session.LastSeenAt, true, nil
+}
+
+// Flush writes the pending times. A time older than the stored one, e.g.
+// from a refresh on another instance, is skipped. Sessions that are done
+// are forgotten, so the maps only hold sessions in use.
+func (store *BatchedSessionActivityStore) Flush(ctx context.Context) error {
+	store.mu.Lock()
+	pending := store.pending
+	store.pending = map[string]time.Time{}
+	store.known = map[string]time.Time{}
+	for sessionId, lastSeen := range pending {
+		store.known[sessionId] = lastSeen
+	}
+	store.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := store.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for sessionId, lastSeen := range pending {
+			err := tx.Model(&domain.Session{}).
+				Where("id = ? AND last_seen_at < ?", sessionId, lastSeen).
+				Update("last_seen_at", lastSeen).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Kept for the next flush.
+		store.mu.Lock()
+		for sessionId, lastSeen := range pending {
+			if lastSeen.After(store.pending[sessionId]) {
+				store.pending[sessionId] = lastSeen
+			}
+		}
+		store.mu.Unlock()
+	}
+
+	return err
+}
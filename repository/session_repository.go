@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, session domain.Session) (domain.Session, error)
+	FindById(ctx context.Context, tx *gorm.DB, sessionId uuid.UUID) (domain.Session, error)
+	FindActiveByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, now time.Time) ([]domain.Session, error)
+	Touch(ctx context.Context, tx *gorm.DB, session domain.Session) error
+	Revoke(ctx context.Context, tx *gorm.DB, sessionId uuid.UUID, revokedAt time.Time) (bool, error)
+	RevokeByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, revokedAt time.Time) error
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &SessionRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *SessionRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, session domain.Session) (domain.Session, error) {
+	if session.Id == uuid.Nil {
+		session.Id = uuid.New()
+	}
+
+	err := tx.WithContext(ctx).Create(&session).Error
+	return session, err
+}
+
+func (repository *SessionRepositoryImpl) FindById(ctx context.Context, tx *gorm.DB, sessionId uuid.UUID) (domain.Session, error) {
+	var session domain.Session
+	err := tx.WithContext(ctx).Where("id = ?", sessionId).First(&session).Error
+
+	return session, err
+}
+
+// FindActiveByUser returns the sessions that are neither revoked nor
+// expired, most recently used first.
+func (repository *SessionRepositoryImpl) FindActiveByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, now time.Time) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := tx.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+
+	return sessions, err
+}
+
+// Touch records a new access token for an active session. Empty fields and
+// a zero ExpiresAt are left unchanged.
+func (repository *SessionRepositoryImpl) Touch(ctx context.Context, tx *gorm.DB, session domain.Session) error {
+	updates := map[string]interface{}{
+		"last_seen_at": session.LastSeenAt,
+	}
+	if session.Jti != "" {
+		updates["jti"] = session.Jti
+	}
+	if session.IpAddress != "" {
+		updates["ip_address"] = session.IpAddress
+	}
+	if !session.ExpiresAt.IsZero() {
+		updates["expires_at"] = session.ExpiresAt
+	}
+
+	return tx.WithContext(ctx).Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", session.Id).
+		Updates(updates).Error
+}
+
+// Revoke reports whether the session was still active.
+func (repository *SessionRepositoryImpl) Revoke(ctx context.Context, tx *gorm.DB, sessionId uuid.UUID, revokedAt time.Time) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionId).
+		Update("revoked_at", revokedAt)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+func (repository *SessionRepositoryImpl) RevokeByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, revokedAt time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", revokedAt).Error
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, key domain.SigningKey) (domain.SigningKey, error)
+	FindPublished(ctx context.Context, tx *gorm.DB, now time.Time) ([]domain.SigningKey, error)
+	RetireActive(ctx context.Context, tx *gorm.DB, retiredAt time.Time, expiresAt time.Time) error
+	DeleteExpired(ctx context.Context, tx *gorm.DB, now time.Time) error
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &SigningKeyRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *SigningKeyRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, key domain.SigningKey) (domain.SigningKey, error) {
+	err := tx.WithContext(ctx).Create(&key).Error
+	return key, err
+}
+
+func (repository *SigningKeyRepositoryImpl) FindPublished(ctx context.Context, tx *gorm.DB, now time.Time) ([]domain.SigningKey, error) {
+	var keys []domain.SigningKey
+	err := tx.WithContext(ctx).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("created_at").
+		Find(&keys).Error
+
+	return keys, err
+}
+
+func (repository *SigningKeyRepositoryImpl) RetireActive(ctx context.Context, tx *gorm.DB, retiredAt time.Time, expiresAt time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.SigningKey{}).
+		Where("retired_at IS NULL").
+		Updates(map[string]interface{}{
+			"retired_at": retiredAt,
+			"expires_at": expiresAt,
+		}).Error
+}
+
+func (repository *SigningKeyRepositoryImpl) DeleteExpired(ctx context.Context, tx *gorm.DB, now time.Time) error {
+	return tx.WithContext(ctx).Where("expires_at <= ?", now).Delete(&domain.SigningKey{}).Error
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// TokenVersionStore tracks each user's token version. Access tokens carry
+// the version they were issued with, and bumping it invalidates all of them
+// at once, e.g. after a role or password change.
+type TokenVersionStore interface {
+	// Current returns gorm.ErrRecordNotFound for users that no longer exist.
+	Current(ctx context.Context, userId string) (int, error)
+	// Bump increments the version inside tx and returns the new one.
+	Bump(ctx context.Context, tx *gorm.DB, userId string) (int, error)
+}
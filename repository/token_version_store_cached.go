@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/domain"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type cachedTokenVersion struct {
+	version   int
+	expiresAt time.Time
+}
+
+// CachedTokenVersionStore keeps versions in memory for TTL so the JWT
+// middleware does not query the users table on every request. Bumps made by
+// this instance take effect immediately; other instances see them once
+// their cached entry expires. Expired entries are swept at most once per
+// TTL, so the cache only holds users seen recently.
+type CachedTokenVersionStore struct {
+	DB  *gorm.DB
+	TTL time.Duration
+
+	mu        sync.RWMutex
+	entries   map[string]cachedTokenVersion
+	nextSweep time.Time
+}
+
+func NewCachedTokenVersionStore(db *gorm.DB, ttl time.Duration) TokenVersionStore {
+	return &CachedTokenVersionStore{
+		DB:      db,
+		TTL:     ttl,
+		entries: map[string]cachedTokenVersion{},
+	}
+}
+
+func (store *CachedTokenVersionStore) Current(ctx context.Context, userId string) (int, error) {
+	now := time.Now()
+
+	store.mu.RLock()
+	entry, ok := store.entries[userId]
+	store.mu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.version, nil
+	}
+
+	var user domain.User
+	err := store.DB.WithContext(ctx).Select("token_version").Where("id = ?", userId).First(&user).Error
+	if err != nil {
+		return 0, err
+	}
+
+	store.mu.Lock()
+	if !now.Before(store.nextSweep) {
+		for id, cached := range store.entries {
+			if !now.Before(cached.expiresAt) {
+				delete(store.entries, id)
+			}
+		}
+		store.nextSweep = now.Add(store.TTL)
+	}
+	store.entries[userId] = cachedTokenVersion{version: user.TokenVersion, expiresAt: now.Add(store.TTL)}
+	store.mu.Unlock()
+
+	return user.TokenVersion, nil
+}
+
+func (store *CachedTokenVersionStore) Bump(ctx context.Context, tx *gorm.DB, userId string) (int, error) {
+	// Dropped now, so Current stops returning the old version, and again
+	// once tx commits, in case a concurrent Current cached the old row in
+	// between.
+	store.evict(userId)
+	helper.AfterCommit(tx, func() { store.evict(userId) })
+
+	err := tx.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userId).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+
+	// A user that does not exist has no tokens left to invalidate.
+	var user domain.User
+	err = tx.WithContext(ctx).Unscoped().Select("token_version").Where("id = ?", userId).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+
+	return user.TokenVersion, err
+}
+
+func (store *CachedTokenVersionStore) evict(userId string) {
+	store.mu.Lock()
+	delete(store.entries, userId)
+	store.mu.Unlock()
+}
@@ -15,4 +15,9 @@ type UserRepository interface {
 	FindById(ctx context.Context, tx *gorm.DB, userId string) (domain.User, error)
 	FindAll(ctx context.Context, tx *gorm.DB) ([]domain.User, error)
 	UpdateLastLogin(ctx context.Context, tx *gorm.DB, userId string, loginAt time.Time) error
+	MarkVerified(ctx context.Context, tx *gorm.DB, userId string) error
+	UpdatePassword(ctx context.Context, tx *gorm.DB, userId string, passwordHash string) error
+	ChangePassword(ctx context.Context, tx *gorm.DB, userId string, passwordHash string, changedAt time.Time) error
+	UpdateMfa(ctx context.Context, tx *gorm.DB, userId string, enabled bool, secret string) error
+	UseMfaStep(ctx context.Context, tx *gorm.DB, userId string, step int64) (bool, error)
 }
@@ -25,10 +25,13 @@ func (repository *UserRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, use
 
 func (repository *UserRepositoryImpl) Update(ctx context.Context, tx *gorm.DB, user domain.User) (domain.User, error) {
 	err := tx.WithContext(ctx).Model(domain.User{}).Where("id = ?", user.Id).Updates(map[string]interface{}{
-		"email":         user.Email,
-		"password_hash": user.PasswordHash,
-		"full_name":     user.FullName,
-		"role":          user.Role,
+		"email":                user.Email,
+		"password_hash":        user.PasswordHash,
+		"full_name":            user.FullName,
+		"role":                 user.Role,
+		"must_change_password": user.MustChangePassword,
+		"password_changed_at":  user.PasswordChangedAt,
+		"is_verified":          user.IsVerified,
 	}).Error
 
 	return user, err
@@ -59,3 +62,39 @@ func (repository *UserRepositoryImpl) FindAll(ctx context.Context, tx *gorm.DB)
 func (repository *UserRepositoryImpl) UpdateLastLogin(ctx context.Context, tx *gorm.DB, userId string, loginAt time.Time) error {
 	return tx.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userId).Update("last_login_at", loginAt).Error
 }
+
+func (repository *UserRepositoryImpl) MarkVerified(ctx context.Context, tx *gorm.DB, userId string) error {
+	return tx.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userId).Update("is_verified", true).Error
+}
+
+func (repository *UserRepositoryImpl) UpdatePassword(ctx context.Context, tx *gorm.DB, userId string, passwordHash string) error {
+	return tx.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userId).Update("password_hash", passwordHash).Error
+}
+
+// ChangePassword stores a password the user chose. Unlike UpdatePassword,
+// which only re-encodes the same password, it restarts the password's age
+// and clears MustChangePassword.
+func (repository *UserRepositoryImpl) ChangePassword(ctx context.Context, tx *gorm.DB, userId string, passwordHash string, changedAt time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"password_hash":        passwordHash,
+		"password_changed_at":  changedAt,
+		"must_change_password": false,
+	}).Error
+}
+
+func (repository *UserRepositoryImpl) UpdateMfa(ctx context.Context, tx *gorm.DB, userId string, enabled bool, secret string) error {
+	return tx.WithContext(ctx).Model(&domain.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+		"mfa_enabled": enabled,
+		"mfa_secret":  secret,
+	}).Error
+}
+
+// UseMfaStep records step as the last accepted TOTP step. It reports false
+// when that step, or a later one, was already used.
+func (repository *UserRepositoryImpl) UseMfaStep(ctx context.Context, tx *gorm.DB, userId string, step int64) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.User{}).
+		Where("id = ? AND mfa_last_used_step < ?", userId, step).
+		Update("mfa_last_used_step", step)
+
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserTokenRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, token domain.UserToken) (domain.UserToken, error)
+	FindByTokenHash(ctx context.Context, tx *gorm.DB, purpose string, tokenHash string) (domain.UserToken, error)
+	FindActiveByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, purpose string, now time.Time) (domain.UserToken, error)
+	MarkUsed(ctx context.Context, tx *gorm.DB, tokenId uuid.UUID, usedAt time.Time) (bool, error)
+	IncrementAttempts(ctx context.Context, tx *gorm.DB, tokenId uuid.UUID) error
+	InvalidateByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, purpose string, usedAt time.Time) error
+	CountCreatedSince(ctx context.Context, tx *gorm.DB, userId uuid.UUID, purpose string, since time.Time) (int64, error)
+	// CountPendingByClientIp counts unused, unexpired tokens of purpose
+	// issued to clientIp.
+	CountPendingByClientIp(ctx context.Context, tx *gorm.DB, purpose string, clientIp string, now time.Time) (int64, error)
+	DeleteExpired(ctx context.Context, tx *gorm.DB, purpose string, now time.Time) error
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserTokenRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &UserTokenRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *UserTokenRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, token domain.UserToken) (domain.UserToken, error) {
+	if token.Id == uuid.Nil {
+		token.Id = uuid.New()
+	}
+
+	err := tx.WithContext(ctx).Create(&token).Error
+	return token, err
+}
+
+func (repository *UserTokenRepositoryImpl) FindByTokenHash(ctx context.Context, tx *gorm.DB, purpose string, tokenHash string) (domain.UserToken, error) {
+	var token domain.UserToken
+	err := tx.WithContext(ctx).Where("purpose = ? AND token_hash = ?", purpose, tokenHash).First(&token).Error
+
+	return token, err
+}
+
+// FindActiveByUser returns the newest unused, unexpired token of purpose.
+// It is used for short codes, which are checked against the user's pending
+// token instead of being looked up by hash.
+func (repository *UserTokenRepositoryImpl) FindActiveByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, purpose string, now time.Time) (domain.UserToken, error) {
+	var token domain.UserToken
+	err := tx.WithContext(ctx).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", userId, purpose, now).
+		Order("created_at DESC").
+		First(&token).Error
+
+	return token, err
+}
+
+func (repository *UserTokenRepositoryImpl) MarkUsed(ctx context.Context, tx *gorm.DB, tokenId uuid.UUID, usedAt time.Time) (bool, error) {
+	result := tx.WithContext(ctx).Model(&domain.UserToken{}).
+		Where("id = ? AND used_at IS NULL", tokenId).
+		Update("used_at", usedAt)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (repository *UserTokenRepositoryImpl) IncrementAttempts(ctx context.Context, tx *gorm.DB, tokenId uuid.UUID) error {
+	return tx.WithContext(ctx).Model(&domain.UserToken{}).
+		Where("id = ?", tokenId).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+func (repository *UserTokenRepositoryImpl) InvalidateByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID, purpose string, usedAt time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose).
+		Update("used_at", usedAt).Error
+}
+
+func (repository *UserTokenRepositoryImpl) CountCreatedSince(ctx context.Context, tx *gorm.DB, userId uuid.UUID, purpose string, since time.Time) (int64, error) {
+	var count int64
+	err := tx.WithContext(ctx).Model(&domain.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at > ?", userId, purpose, since).
+		Count(&count).Error
+
+	return count, err
+}
+
+func (repository *UserTokenRepositoryImpl) CountPendingByClientIp(ctx context.Context, tx *gorm.DB, purpose string, clientIp string, now time.Time) (int64, error) {
+	var count int64
+	err := tx.WithContext(ctx).Model(&domain.UserToken{}).
+		Where("purpose = ? AND client_ip = ? AND used_at IS NULL AND expires_at > ?", purpose, clientIp, now).
+		Count(&count).Error
+
+	return count, err
+}
+
+func (repository *UserTokenRepositoryImpl) DeleteExpired(ctx context.Context, tx *gorm.DB, purpose string, now time.Time) error {
+	return tx.WithContext(ctx).
+		Where("purpose = ? AND expires_at <= ?", purpose, now).
+		Delete(&domain.UserToken{}).Error
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebAuthnCredentialRepository interface {
+	Save(ctx context.Context, tx *gorm.DB, credential domain.WebAuthnCredential) (domain.WebAuthnCredential, error)
+	FindByCredentialId(ctx context.Context, tx *gorm.DB, credentialId string) (domain.WebAuthnCredential, error)
+	FindByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID) ([]domain.WebAuthnCredential, error)
+	UpdateSignCount(ctx context.Context, tx *gorm.DB, credentialId uuid.UUID, signCount int64, usedAt time.Time) error
+}
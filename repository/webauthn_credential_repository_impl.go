@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"auth-api-jwt/models/domain"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebAuthnCredentialRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewWebAuthnCredentialRepository(db *gorm.DB) WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepositoryImpl{
+		DB: db,
+	}
+}
+
+func (repository *WebAuthnCredentialRepositoryImpl) Save(ctx context.Context, tx *gorm.DB, credential domain.WebAuthnCredential) (domain.WebAuthnCredential, error) {
+	if credential.Id == uuid.Nil {
+		credential.Id = uuid.New()
+	}
+
+	err := tx.WithContext(ctx).Create(&credential).Error
+	return credential, err
+}
+
+func (repository *WebAuthnCredentialRepositoryImpl) FindByCredentialId(ctx context.Context, tx *gorm.DB, credentialId string) (domain.WebAuthnCredential, error) {
+	var credential domain.WebAuthnCredential
+	err := tx.WithContext(ctx).Where("credential_id = ?", credentialId).First(&credential).Error
+
+	return credential, err
+}
+
+func (repository *WebAuthnCredentialRepositoryImpl) FindByUser(ctx context.Context, tx *gorm.DB, userId uuid.UUID) ([]domain.WebAuthnCredential, error) {
+	var credentials []domain.WebAuthnCredential
+	err := tx.WithContext(ctx).Where("user_id = ?", userId).Order("created_at").Find(&credentials).Error
+
+	return credentials, err
+}
+
+func (repository *WebAuthnCredentialRepositoryImpl) UpdateSignCount(ctx context.Context, tx *gorm.DB, credentialId uuid.UUID, signCount int64, usedAt time.Time) error {
+	return tx.WithContext(ctx).Model(&domain.WebAuthnCredential{}).
+		Where("id = ?", credentialId).
+		Updates(map[string]interface{}{
+			"sign_count":   signCount,
+			"last_used_at": usedAt,
+		}).Error
+}
@@ -2,13 +2,24 @@ package routes
 
 import (
 	"auth-api-jwt/controller"
+	"auth-api-jwt/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func NewAuthRoutes(app *fiber.App, authController controller.AuthController) {
+func NewAuthRoutes(app *fiber.App, authController controller.AuthController, jwtConfig middleware.JWTConfig) {
 	auth := app.Group("/auth")
 
 	auth.Post("/register", authController.Register)
 	auth.Post("/login", authController.Login)
+	auth.Post("/mfa/verify", authController.VerifyMfa)
+	auth.Post("/refresh", authController.Refresh)
+	auth.Post("/logout", middleware.JWTMiddleware(jwtConfig), authController.Logout)
+	auth.Post("/reauthenticate", middleware.JWTMiddleware(jwtConfig), authController.Reauthenticate)
+	auth.Post("/verify-email", authController.VerifyEmail)
+	auth.Post("/resend-verification", authController.ResendVerification)
+	auth.Post("/forgot-password", authController.ForgotPassword)
+	auth.Post("/reset-password", authController.ResetPassword)
+	auth.Post("/passwordless/start", authController.StartPasswordless)
+	auth.Post("/passwordless/verify", authController.VerifyPasswordless)
 }
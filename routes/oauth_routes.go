@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func NewOAuthRoutes(app *fiber.App, oauthController controller.OAuthController, jwtConfig middleware.JWTConfig) {
+	oauth := app.Group("/oauth")
+
+	oauth.Get("/authorize", oauthController.AuthorizePage)
+	oauth.Post("/authorize", oauthController.Authorize)
+	oauth.Post("/token", oauthController.Token)
+	oauth.Post("/device_authorization", oauthController.DeviceAuthorization)
+	oauth.Post("/introspect", oauthController.Introspect)
+	oauth.Post("/revoke", oauthController.Revoke)
+
+	app.Get("/device", oauthController.DevicePage)
+	app.Post("/device", oauthController.VerifyDevice)
+
+	userInfoConfig := jwtConfig
+	userInfoConfig.AllowDelegated = true
+
+	app.Get("/userinfo", middleware.JWTMiddleware(userInfoConfig), middleware.RequireUser(), oauthController.UserInfo)
+	app.Post("/userinfo", middleware.JWTMiddleware(userInfoConfig), middleware.RequireUser(), oauthController.UserInfo)
+
+	clients := oauth.Group("/clients", middleware.JWTMiddleware(jwtConfig), middleware.AdminOnly())
+
+	clients.Get("/", oauthController.FindAllClients)
+	clients.Post("/", oauthController.CreateClient)
+	clients.Delete("/:clientId", oauthController.DeleteClient)
+}
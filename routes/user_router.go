@@ -3,21 +3,61 @@ package routes
 import (
 	"auth-api-jwt/controller"
 	"auth-api-jwt/middleware"
+	"auth-api-jwt/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func NewUserRouter(app *fiber.App, userController controller.UserController) {
-	user := app.Group("/users", middleware.JWTMiddleware())
+func NewUserRouter(app *fiber.App, userController controller.UserController, mfaController controller.MfaController, sessionController controller.SessionController, userImportController controller.UserImportController, jwtConfig middleware.JWTConfig) {
+	// The MFA routes also accept enrollment-only tokens. They are registered
+	// before the /users group so its middleware does not reject those tokens
+	// first.
+	mfaConfig := jwtConfig
+	mfaConfig.AllowedRestrictions = append(append([]string(nil), jwtConfig.AllowedRestrictions...), utils.RestrictionMfaEnrollment)
 
-	user.Put("/me", userController.UpdateMe)
-	user.Get("/me", userController.Me)
+	// Sensitive actions need a recent sign-in or /auth/reauthenticate, not
+	// just any valid token.
+	recentAuth := middleware.RequireRecentAuth(utils.GetEnvDuration("REAUTH_MAX_AGE", 10*time.Minute))
 
-	admin := user.Group("/", middleware.AdminOnly())
+	mfa := app.Group("/users/me/mfa", middleware.JWTMiddleware(mfaConfig))
+
+	mfa.Post("/enroll", mfaController.Enroll)
+	mfa.Post("/confirm", mfaController.Confirm)
+	mfa.Delete("/", recentAuth, mfaController.Disable)
+	mfa.Post("/recovery-codes", recentAuth, mfaController.RegenerateRecoveryCodes)
+
+	// Tokens issued while the password must be changed are only good here.
+	passwordConfig := jwtConfig
+	passwordConfig.AllowedRestrictions = append(append([]string(nil), jwtConfig.AllowedRestrictions...), utils.RestrictionPasswordChange)
+
+	app.Post("/users/me/password", middleware.JWTMiddleware(passwordConfig), userController.ChangePassword)
+
+	// Backend jobs call the admin routes with client_credentials tokens
+	// scoped to users:read or users:write.
+	clientConfig := jwtConfig
+	clientConfig.AllowClients = true
+
+	user := app.Group("/users", middleware.JWTMiddleware(clientConfig))
+
+	me := user.Group("/me", middleware.RequireUser())
+
+	me.Put("/", recentAuth, userController.UpdateMe)
+	me.Get("/", userController.Me)
+	me.Get("/sessions", sessionController.FindMine)
+	me.Delete("/sessions", sessionController.RevokeMyOthers)
+	me.Delete("/sessions/:sessionId", sessionController.RevokeMine)
+
+	admin := user.Group("/", middleware.AdminOnly("users"))
 
 	admin.Get("/", userController.FindAll)
 	admin.Get("/:userId", userController.FindById)
 	admin.Post("/", userController.Create)
-	admin.Put("/:userId", userController.Update)
-	admin.Delete("/:userId", userController.Delete)
+	admin.Post("/import", userImportController.Import)
+	admin.Put("/:userId", recentAuth, userController.Update)
+	admin.Delete("/:userId", recentAuth, userController.Delete)
+	admin.Post("/:userId/unlock", userController.Unlock)
+	admin.Get("/:userId/sessions", sessionController.FindByUser)
+	admin.Delete("/:userId/sessions", sessionController.RevokeAllByUser)
+	admin.Delete("/:userId/sessions/:sessionId", sessionController.RevokeByUser)
 }
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func NewWebAuthnRoutes(app *fiber.App, webAuthnController controller.WebAuthnController, jwtConfig middleware.JWTConfig) {
+	webAuthn := app.Group("/auth/webauthn")
+
+	webAuthn.Post("/register/options", middleware.JWTMiddleware(jwtConfig), webAuthnController.RegisterOptions)
+	webAuthn.Post("/register", middleware.JWTMiddleware(jwtConfig), webAuthnController.Register)
+	webAuthn.Post("/signup/options", webAuthnController.SignupOptions)
+	webAuthn.Post("/signup", webAuthnController.Signup)
+	webAuthn.Post("/login/options", webAuthnController.LoginOptions)
+	webAuthn.Post("/login", webAuthnController.Login)
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"auth-api-jwt/controller"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func NewWellKnownRoutes(app *fiber.App, wellKnownController controller.WellKnownController) {
+	wellKnown := app.Group("/.well-known")
+
+	wellKnown.Get("/jwks.json", wellKnownController.JWKS)
+	wellKnown.Get("/openid-configuration", wellKnownController.OpenIDConfiguration)
+}
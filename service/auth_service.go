@@ -4,9 +4,51 @@ import (
 	"auth-api-jwt/models/domain"
 	"auth-api-jwt/models/web"
 	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// ErrMfaCodeRequired is returned by Authenticate when the password was
+// right but the user must also enter a second factor.
+var ErrMfaCodeRequired = errors.New("enter the code from your authenticator app")
+
+// TokenGrant describes a sign-in checked outside AuthService, for
+// IssueTokens. SessionId is optional and picks the id of the new session.
+type TokenGrant struct {
+	SessionId uuid.UUID
+	AuthTime  time.Time
+	Amr       []string
+	ClientId  string
+	Scope     string
+	ClientIp  string
+	UserAgent string
+	Device    string
+}
+
 type AuthService interface {
-	Register(ctx context.Context, request web.AuthRegisterRequest) (domain.User, error)
-	Login(ctx context.Context, request web.AuthLoginRequest) (string, error)
+	Register(ctx context.Context, request web.AuthRegisterRequest) error
+	Login(ctx context.Context, request web.AuthLoginRequest) (web.AuthTokenResponse, error)
+	SignupWithPasskey(ctx context.Context, request web.AuthPasskeySignupRequest) error
+	LoginWithPasskey(ctx context.Context, request web.AuthPasskeyLoginRequest) (web.AuthTokenResponse, error)
+	VerifyMfa(ctx context.Context, request web.AuthMfaVerifyRequest) (web.AuthTokenResponse, error)
+	// Refresh only rotates first-party refresh tokens; tokens issued to an
+	// OAuth client go through RefreshForClient with that client's id.
+	Refresh(ctx context.Context, request web.AuthRefreshRequest) (web.AuthTokenResponse, error)
+	RefreshForClient(ctx context.Context, clientId string, request web.AuthRefreshRequest) (web.AuthTokenResponse, error)
+	Logout(ctx context.Context, request web.AuthLogoutRequest) error
+	VerifyEmail(ctx context.Context, request web.AuthVerifyEmailRequest) error
+	ResendVerification(ctx context.Context, request web.AuthResendVerificationRequest) error
+	ForgotPassword(ctx context.Context, request web.AuthForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, request web.AuthResetPasswordRequest) error
+	ChangePassword(ctx context.Context, request web.AuthChangePasswordRequest) (web.AuthTokenResponse, error)
+	Reauthenticate(ctx context.Context, request web.AuthReauthenticateRequest) (web.AuthTokenResponse, error)
+	StartPasswordless(ctx context.Context, request web.AuthPasswordlessStartRequest) error
+	VerifyPasswordless(ctx context.Context, request web.AuthPasswordlessVerifyRequest) (web.AuthTokenResponse, error)
+	// Authenticate and IssueTokens run inside the caller's transaction, for
+	// sign-ins that don't go through /auth/login.
+	Authenticate(ctx context.Context, tx *gorm.DB, request web.AuthLoginRequest, code string) (domain.User, []string, error)
+	IssueTokens(ctx context.Context, tx *gorm.DB, user domain.User, grant TokenGrant) (web.AuthTokenResponse, error)
 }
@@ -1,41 +1,95 @@
 package service
 
 import (
+	"auth-api-jwt/exception"
 	"auth-api-jwt/helper"
+	"auth-api-jwt/mailer"
 	"auth-api-jwt/models/domain"
 	"auth-api-jwt/models/web"
 	"auth-api-jwt/repository"
 	"auth-api-jwt/utils"
 	"context"
+	"crypto/subtle"
 	"errors"
+	"fmt"
+	"log"
+	"os"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// maxMfaAttempts is how many wrong codes a single MFA challenge accepts.
+const maxMfaAttempts = 5
+
 type AuthServiceImpl struct {
-	AuthRepository repository.AuthRepository
-	UserRepository repository.UserRepository
-	DB             *gorm.DB
-	Validate       *validator.Validate
+	AuthRepository            repository.AuthRepository
+	UserRepository            repository.UserRepository
+	RefreshTokenRepository    repository.RefreshTokenRepository
+	UserTokenRepository       repository.UserTokenRepository
+	LoginAttemptRepository    repository.LoginAttemptRepository
+	PasswordHistoryRepository repository.PasswordHistoryRepository
+	SessionRepository         repository.SessionRepository
+	RevocationStore           repository.RevocationStore
+	TokenVersionStore         repository.TokenVersionStore
+	MfaService                MfaService
+	WebAuthnService           WebAuthnService
+	Mailer                    mailer.Mailer
+	DB                        *gorm.DB
+	Validate                  *validator.Validate
 }
 
-func NewAuthService(authRepository repository.AuthRepository, userRepository repository.UserRepository, DB *gorm.DB, validate *validator.Validate) AuthService {
+func NewAuthService(authRepository repository.AuthRepository, userRepository repository.UserRepository, refreshTokenRepository repository.RefreshTokenRepository, userTokenRepository repository.UserTokenRepository, loginAttemptRepository repository.LoginAttemptRepository, passwordHistoryRepository repository.PasswordHistoryRepository, sessionRepository repository.SessionRepository, revocationStore repository.RevocationStore, tokenVersionStore repository.TokenVersionStore, mfaService MfaService, webAuthnService WebAuthnService, mailer mailer.Mailer, DB *gorm.DB, validate *validator.Validate) AuthService {
 	return &AuthServiceImpl{
-		AuthRepository: authRepository,
-		UserRepository: userRepository,
-		DB:             DB,
-		Validate:       validate,
+		AuthRepository:            authRepository,
+		UserRepository:            userRepository,
+		RefreshTokenRepository:    refreshTokenRepository,
+		UserTokenRepository:       userTokenRepository,
+		LoginAttemptRepository:    loginAttemptRepository,
+		PasswordHistoryRepository: passwordHistoryRepository,
+		SessionRepository:         sessionRepository,
+		RevocationStore:           revocationStore,
+		TokenVersionStore:         tokenVersionStore,
+		MfaService:                mfaService,
+		WebAuthnService:           webAuthnService,
+		Mailer:                    mailer,
+		DB:                        DB,
+		Validate:                  validate,
 	}
 }
 
-func (service *AuthServiceImpl) Register(ctx context.Context, request web.AuthRegisterRequest) (domain.User, error) {
+// Register answers the same way whether or not the email is taken. The
+// owner of an existing account gets an email about the attempt instead,
+// and the password is hashed up front so both paths take as long.
+func (service *AuthServiceImpl) Register(ctx context.Context, request web.AuthRegisterRequest) error {
 	if err := service.Validate.Struct(request); err != nil {
-		return domain.User{}, err
+		return err
+	}
+
+	if err := checkPasswordPolicy("password", request.Password, request.Email, request.FullName); err != nil {
+		return err
 	}
 
-	hashed, _ := utils.HashPassword(request.Password)
+	hashed, err := utils.HashPassword(request.Password)
+	if err != nil {
+		return err
+	}
+
+	mails := newOutbox(service.Mailer)
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
+
+	existing, err := service.AuthRepository.FindByEmail(ctx, tx, request.Email)
+	if err == nil {
+		mails.add(accountExistsEmail(existing))
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
 
 	user := domain.User{
 		Email:        request.Email,
@@ -45,35 +99,1203 @@ func (service *AuthServiceImpl) Register(ctx context.Context, request web.AuthRe
 		IsVerified:   false,
 	}
 
-	return service.AuthRepository.Create(ctx, service.DB, user)
+	created, err := service.AuthRepository.Create(ctx, tx, user)
+	if err != nil {
+		// Most likely the same email registered concurrently. Reporting
+		// the duplicate key would reveal the account.
+		log.Println("Register user fail:", err)
+		return nil
+	}
+
+	if err := queueVerificationEmail(ctx, tx, service.UserTokenRepository, mails, created, true); err != nil {
+		log.Println("Queue verification email fail:", err)
+	}
+
+	return nil
 }
 
-func (service *AuthServiceImpl) Login(ctx context.Context, request web.AuthLoginRequest) (string, error) {
+func (service *AuthServiceImpl) Login(ctx context.Context, request web.AuthLoginRequest) (web.AuthTokenResponse, error) {
 	if err := service.Validate.Struct(request); err != nil {
-		return "", err
+		return web.AuthTokenResponse{}, err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	throttleKeys := loginThrottleKeys(request)
+
+	user, err := service.checkPassword(ctx, tx, request, throttleKeys, time.Now())
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	// Only the account counter is cleared, so one valid login does not
+	// hide guesses against other accounts from the same address. With MFA
+	// the login isn't valid yet; VerifyMfa clears it.
+	if !user.MfaEnabled {
+		if err := service.LoginAttemptRepository.Reset(ctx, tx, throttleKeys[0].Key); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+	}
+
+	client := sessionClient{Ip: request.ClientIp, UserAgent: request.UserAgent, Device: request.Device, RememberMe: request.RememberMe, DeviceId: request.DeviceId}
+
+	return service.completeLogin(ctx, tx, user, newAuthentication(utils.AmrPassword), false, client)
+}
+
+// checkPassword is the throttled email and password check shared by the
+// sign-in flows. Clearing the throttle is left to the caller, which may
+// still need a second factor.
+func (service *AuthServiceImpl) checkPassword(ctx context.Context, tx *gorm.DB, request web.AuthLoginRequest, throttleKeys []loginThrottleKey, now time.Time) (domain.User, error) {
+	if err := checkLoginThrottle(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+		return domain.User{}, err
+	}
+
+	// An unknown email is checked against an empty hash, which still costs
+	// a full comparison. Passkey-only accounts are rejected the same way.
+	passwordHash := ""
+	user, err := service.AuthRepository.FindByEmail(ctx, tx, request.Email)
+	if err == nil {
+		passwordHash = user.PasswordHash
+	}
+
+	if !utils.CheckPasswordConstantTime(request.Password, passwordHash) || err != nil {
+		if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+			return domain.User{}, err
+		}
+		return domain.User{}, errors.New("invalid email or password")
+	}
+
+	// The plaintext is only available here, so this is where hashes made
+	// with an old algorithm or cost are upgraded.
+	if utils.PasswordNeedsRehash(user.PasswordHash) {
+		if err := service.rehashPassword(ctx, tx, user, request.Password); err != nil {
+			log.Println("Rehash password fail:", err)
+		}
+	}
+
+	return user, nil
+}
+
+// Authenticate signs a user in on a page of this service, like the OAuth
+// login page, where the password and second factor arrive together.
+// ErrMfaCodeRequired asks for the code; wrong codes count as failed logins.
+func (service *AuthServiceImpl) Authenticate(ctx context.Context, tx *gorm.DB, request web.AuthLoginRequest, code string) (domain.User, []string, error) {
+	now := time.Now()
+	throttleKeys := loginThrottleKeys(request)
+
+	user, err := service.checkPassword(ctx, tx, request, throttleKeys, now)
+	if err != nil {
+		return domain.User{}, nil, err
+	}
+
+	if requireVerifiedEmail() && !user.IsVerified {
+		return domain.User{}, nil, errors.New("email not verified")
+	}
+
+	methods := []string{utils.AmrPassword}
+	if user.MfaEnabled {
+		if code == "" {
+			return domain.User{}, nil, ErrMfaCodeRequired
+		}
+
+		totp, recoveryCode := code, ""
+		if len(code) != 6 {
+			totp, recoveryCode = "", code
+		}
+		if err := service.MfaService.VerifySecondFactor(ctx, tx, user, totp, recoveryCode); err != nil {
+			if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+				return domain.User{}, nil, err
+			}
+			return domain.User{}, nil, err
+		}
+		methods = append(methods, utils.AmrOtp, utils.AmrMultiFactor)
+	} else if service.MfaService.RequiredForRole(user.Role) {
+		return domain.User{}, nil, errors.New("set up two-factor authentication before signing in to other apps")
+	}
+
+	if passwordChangeRequired(user, now) {
+		return domain.User{}, nil, errors.New("your password must be changed, sign in to the account first")
+	}
+
+	if err := service.LoginAttemptRepository.Reset(ctx, tx, throttleKeys[0].Key); err != nil {
+		return domain.User{}, nil, err
+	}
+
+	return user, methods, nil
+}
+
+// IssueTokens starts a session for a sign-in checked elsewhere, like an
+// OAuth authorization code.
+func (service *AuthServiceImpl) IssueTokens(ctx context.Context, tx *gorm.DB, user domain.User, grant TokenGrant) (web.AuthTokenResponse, error) {
+	if err := service.UserRepository.UpdateLastLogin(ctx, tx, user.Id.String(), time.Now()); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	client := sessionClient{Ip: grant.ClientIp, UserAgent: grant.UserAgent, Device: grant.Device}
+	session := client.newSession(user)
+	if grant.SessionId != uuid.Nil {
+		session.Id = grant.SessionId
+	}
+
+	auth := authentication{Time: grant.AuthTime, Methods: grant.Amr, ClientId: grant.ClientId, Scope: grant.Scope}
+
+	return service.issueTokens(ctx, tx, user, auth, session, uuid.New())
+}
+
+// SignupWithPasskey creates an account without a password. The passkey
+// becomes its only way to sign in until a password is set through the
+// reset flow. Like Register it answers the same way when the email is
+// taken, and tells the owner of that account instead.
+func (service *AuthServiceImpl) SignupWithPasskey(ctx context.Context, request web.AuthPasskeySignupRequest) error {
+	if err := service.Validate.Struct(request); err != nil {
+		return err
+	}
+
+	mails := newOutbox(service.Mailer)
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
+
+	// the attestation is checked first, so a taken email is only revealed
+	// to the owner and not to whoever holds a signup challenge
+	credential, err := service.WebAuthnService.VerifySignup(ctx, tx, request.Email, request.Credential)
+	if err != nil {
+		return err
+	}
+
+	existing, err := service.AuthRepository.FindByEmail(ctx, tx, request.Email)
+	if err == nil {
+		mails.add(accountExistsEmail(existing))
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	created, err := service.AuthRepository.Create(ctx, tx, domain.User{
+		Id:         credential.UserId,
+		Email:      request.Email,
+		FullName:   request.FullName,
+		Role:       "user",
+		IsVerified: false,
+	})
+	if err != nil {
+		log.Println("Passkey signup fail:", err)
+		return nil
+	}
+
+	if _, err := service.WebAuthnService.SaveCredential(ctx, tx, credential, request.Name); err != nil {
+		return err
+	}
+
+	if err := queueVerificationEmail(ctx, tx, service.UserTokenRepository, mails, created, true); err != nil {
+		log.Println("Queue verification email fail:", err)
+	}
+
+	return nil
+}
+
+func (service *AuthServiceImpl) LoginWithPasskey(ctx context.Context, request web.AuthPasskeyLoginRequest) (web.AuthTokenResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	credential, userVerified, err := service.WebAuthnService.VerifyLogin(ctx, tx, request.Credential)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	user, err := service.UserRepository.FindById(ctx, tx, credential.UserId.String())
+	if err != nil {
+		return web.AuthTokenResponse{}, errors.New("unknown credential")
+	}
+
+	auth := newAuthentication(utils.AmrHardwareKey)
+	if userVerified {
+		auth.Methods = append(auth.Methods, utils.AmrMultiFactor)
+	}
+
+	client := sessionClient{Ip: request.ClientIp, UserAgent: request.UserAgent}
+
+	return service.completeLogin(ctx, tx, user, auth, userVerified, client)
+}
+
+// completeLogin runs the checks shared by every first factor. multiFactor
+// is set when the first factor already proved two factors, like a passkey
+// unlocked with a PIN or biometric.
+func (service *AuthServiceImpl) completeLogin(ctx context.Context, tx *gorm.DB, user domain.User, auth authentication, multiFactor bool, client sessionClient) (web.AuthTokenResponse, error) {
+	if requireVerifiedEmail() && !user.IsVerified {
+		return web.AuthTokenResponse{}, errors.New("email not verified")
+	}
+
+	if !multiFactor {
+		if user.MfaEnabled {
+			return service.startMfaChallenge(ctx, tx, user)
+		}
+
+		if service.MfaService.RequiredForRole(user.Role) {
+			return issueMfaEnrollmentToken(user)
+		}
+	}
+
+	err := service.UserRepository.UpdateLastLogin(ctx, tx, user.Id.String(), time.Now())
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	return service.issueTokens(ctx, tx, user, auth, client.newSession(user), uuid.New())
+}
+
+// VerifyMfa finishes a login started with a password. The challenge is
+// burned after too many wrong codes, forcing the password to be entered
+// again, and wrong codes count as failed logins of the account, so new
+// challenges do not give unlimited guesses.
+func (service *AuthServiceImpl) VerifyMfa(ctx context.Context, request web.AuthMfaVerifyRequest) (web.AuthTokenResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	now := time.Now()
+
+	challenge, err := service.UserTokenRepository.FindByTokenHash(ctx, tx, domain.UserTokenMfaChallenge, utils.HashToken(request.MfaToken))
+	if err != nil || challenge.UsedAt != nil || now.After(challenge.ExpiresAt) {
+		return web.AuthTokenResponse{}, errors.New("invalid or expired mfa token")
+	}
+
+	user, err := service.UserRepository.FindById(ctx, tx, challenge.UserId.String())
+	if err != nil || !user.MfaEnabled {
+		return web.AuthTokenResponse{}, errors.New("invalid or expired mfa token")
+	}
+
+	throttleKeys := loginThrottleKeys(web.AuthLoginRequest{Email: user.Email, ClientIp: request.ClientIp})
+	if err := checkLoginThrottle(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	verifyErr := service.MfaService.VerifySecondFactor(ctx, tx, user, request.Code, request.RecoveryCode)
+	if verifyErr != nil {
+		if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+		if err := service.UserTokenRepository.IncrementAttempts(ctx, tx, challenge.Id); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+		if challenge.Attempts+1 >= maxMfaAttempts {
+			if _, err := service.UserTokenRepository.MarkUsed(ctx, tx, challenge.Id, now); err != nil {
+				return web.AuthTokenResponse{}, err
+			}
+		}
+		return web.AuthTokenResponse{}, verifyErr
+	}
+
+	used, err := service.UserTokenRepository.MarkUsed(ctx, tx, challenge.Id, now)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+	if !used {
+		return web.AuthTokenResponse{}, errors.New("invalid or expired mfa token")
+	}
+
+	if err := service.LoginAttemptRepository.Reset(ctx, tx, throttleKeys[0].Key); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	if err := service.UserRepository.UpdateLastLogin(ctx, tx, user.Id.String(), now); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	client := sessionClient{Ip: request.ClientIp, UserAgent: request.UserAgent, RememberMe: request.RememberMe, DeviceId: request.DeviceId}
+
+	return service.issueTokens(ctx, tx, user, newAuthentication(utils.AmrOtp, utils.AmrMultiFactor), client.newSession(user), uuid.New())
+}
+
+func (service *AuthServiceImpl) Refresh(ctx context.Context, request web.AuthRefreshRequest) (web.AuthTokenResponse, error) {
+	return service.refresh(ctx, "", request)
+}
+
+func (service *AuthServiceImpl) RefreshForClient(ctx context.Context, clientId string, request web.AuthRefreshRequest) (web.AuthTokenResponse, error) {
+	return service.refresh(ctx, clientId, request)
+}
+
+// refresh rotates a refresh token that belongs to clientId, where an empty
+// clientId is the first-party app.
+func (service *AuthServiceImpl) refresh(ctx context.Context, clientId string, request web.AuthRefreshRequest) (web.AuthTokenResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.AuthTokenResponse{}, err
 	}
 
 	tx := service.DB.Begin()
 	defer helper.CommitOrRollback(tx)
 
+	now := time.Now()
+
+	stored, err := service.RefreshTokenRepository.FindByTokenHash(ctx, tx, utils.HashToken(request.RefreshToken))
+	if err != nil || stored.ClientId != clientId {
+		return web.AuthTokenResponse{}, errors.New("invalid refresh token")
+	}
+
+	if stored.RevokedAt != nil {
+		if stored.ReplacedById != nil {
+			return web.AuthTokenResponse{}, service.revokeReusedFamily(ctx, tx, stored, now)
+		}
+		return web.AuthTokenResponse{}, errors.New("invalid refresh token")
+	}
+
+	if now.After(stored.ExpiresAt) {
+		return web.AuthTokenResponse{}, errors.New("refresh token expired")
+	}
+
+	user, err := service.UserRepository.FindById(ctx, tx, stored.UserId.String())
+	if err != nil {
+		return web.AuthTokenResponse{}, errors.New("invalid refresh token")
+	}
+
+	// Families from before sessions were tracked have no row; they are
+	// refreshed as a plain session that is never saved.
+	session, err := service.SessionRepository.FindById(ctx, tx, stored.FamilyId)
+	if err != nil {
+		session = domain.Session{Id: stored.FamilyId, UserId: user.Id, CreatedAt: stored.CreatedAt}
+	}
+
+	if sessionIdle(session, now) {
+		if err := revokeSession(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, session.Id, now); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+		return web.AuthTokenResponse{}, errors.New("session expired due to inactivity, please login again")
+	}
+
+	// A remembered refresh token used without its device id is treated
+	// as stolen.
+	if session.RememberMe && subtle.ConstantTimeCompare([]byte(session.DeviceId), []byte(request.DeviceId)) != 1 {
+		if err := revokeSession(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, session.Id, now); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+		return web.AuthTokenResponse{}, errors.New("refresh token is bound to another device, please login again")
+	}
+
+	nextId := uuid.New()
+	rotated, err := service.RefreshTokenRepository.MarkRotated(ctx, tx, stored.Id, nextId, now)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	// Someone else rotated this token between our read and our write,
+	// which is indistinguishable from replaying a stolen token.
+	if !rotated {
+		return web.AuthTokenResponse{}, service.revokeReusedFamily(ctx, tx, stored, now)
+	}
+
+	return service.issueTokens(ctx, tx, user, storedAuthentication(stored), session, nextId)
+}
+
+func (service *AuthServiceImpl) Logout(ctx context.Context, request web.AuthLogoutRequest) error {
+	if request.Jti != "" {
+		if err := service.RevocationStore.Revoke(ctx, request.Jti, request.TokenExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	sessionId := request.SessionId
+	if request.RefreshToken != "" {
+		stored, err := service.RefreshTokenRepository.FindByTokenHash(ctx, tx, utils.HashToken(request.RefreshToken))
+		if err != nil || stored.UserId.String() != request.UserId {
+			return errors.New("invalid refresh token")
+		}
+		sessionId = stored.FamilyId.String()
+	}
+
+	// Tokens issued before sessions were tracked have no sid to end.
+	familyId, err := uuid.Parse(sessionId)
+	if err != nil {
+		return nil
+	}
+
+	return revokeSession(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, familyId, time.Now())
+}
+
+func (service *AuthServiceImpl) VerifyEmail(ctx context.Context, request web.AuthVerifyEmailRequest) error {
+	if err := service.Validate.Struct(request); err != nil {
+		return err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	token, err := service.consumeUserToken(ctx, tx, domain.UserTokenEmailVerification, request.Token)
+	if err != nil {
+		return errors.New("invalid or expired verification token")
+	}
+
+	return service.UserRepository.MarkVerified(ctx, tx, token.UserId.String())
+}
+
+// ResendVerification never reports whether the email exists or is already
+// verified, so it cannot be used to probe for accounts.
+func (service *AuthServiceImpl) ResendVerification(ctx context.Context, request web.AuthResendVerificationRequest) error {
+	if err := service.Validate.Struct(request); err != nil {
+		return err
+	}
+
+	mails := newOutbox(service.Mailer)
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
+
+	user, err := service.AuthRepository.FindByEmail(ctx, tx, request.Email)
+	if err != nil || user.IsVerified {
+		return nil
+	}
+
+	if err := queueVerificationEmail(ctx, tx, service.UserTokenRepository, mails, user, true); err != nil {
+		log.Println("Queue verification email fail:", err)
+	}
+
+	return nil
+}
+
+// ForgotPassword answers the same way whether or not the email belongs to
+// an account.
+func (service *AuthServiceImpl) ForgotPassword(ctx context.Context, request web.AuthForgotPasswordRequest) error {
+	if err := service.Validate.Struct(request); err != nil {
+		return err
+	}
+
+	mails := newOutbox(service.Mailer)
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
+
 	user, err := service.AuthRepository.FindByEmail(ctx, tx, request.Email)
 	if err != nil {
-		return "", errors.New("invalid email or password")
+		return nil
+	}
+
+	if err := service.queuePasswordResetEmail(ctx, tx, mails, user); err != nil {
+		log.Println("Queue password reset email fail:", err)
+	}
+
+	return nil
+}
+
+func (service *AuthServiceImpl) ResetPassword(ctx context.Context, request web.AuthResetPasswordRequest) error {
+	if err := service.Validate.Struct(request); err != nil {
+		return err
+	}
+
+	mails := newOutbox(service.Mailer)
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
+
+	pending, err := service.UserTokenRepository.FindByTokenHash(ctx, tx, domain.UserTokenPasswordReset, utils.HashToken(request.Token))
+	if err != nil || pending.UsedAt != nil || time.Now().After(pending.ExpiresAt) {
+		return errors.New("invalid or expired reset token")
+	}
+
+	user, err := service.UserRepository.FindById(ctx, tx, pending.UserId.String())
+	if err != nil {
+		return errors.New("invalid or expired reset token")
+	}
+
+	// Checked before the token is consumed, so a rejected password does not
+	// burn the link.
+	if err := checkPasswordPolicy("new_password", request.NewPassword, user.Email, user.FullName); err != nil {
+		return err
+	}
+	if err := checkPasswordReuse(ctx, tx, service.PasswordHistoryRepository, user, "new_password", request.NewPassword); err != nil {
+		return err
 	}
 
-	if !utils.CheckPassword(request.Password, user.PasswordHash) {
-		return "", errors.New("invalid email or password")
+	if _, err := service.consumeUserToken(ctx, tx, domain.UserTokenPasswordReset, request.Token); err != nil {
+		return errors.New("invalid or expired reset token")
 	}
 
-	err = service.UserRepository.UpdateLastLogin(ctx, tx, user.Id.String(), time.Now())
+	hashed, err := utils.HashPassword(request.NewPassword)
 	if err != nil {
+		return err
+	}
+
+	if err := rememberPassword(ctx, tx, service.PasswordHistoryRepository, user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	if err := service.UserRepository.ChangePassword(ctx, tx, user.Id.String(), hashed, now); err != nil {
+		return err
+	}
+
+	if err := service.UserTokenRepository.InvalidateByUser(ctx, tx, user.Id, domain.UserTokenPasswordReset, now); err != nil {
+		return err
+	}
+
+	if err := revokeAllSessions(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, service.TokenVersionStore, &user, now); err != nil {
+		return err
+	}
+
+	mails.add(mailer.Message{
+		To:      user.Email,
+		Subject: "Your password has been changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe password of your account was just reset and every device has been signed out.\n\nIf this was not you, contact support immediately.",
+			user.FullName),
+	})
+
+	return nil
+}
+
+// ChangePassword replaces the password of a signed-in user after checking
+// the current one. Every session is signed out and the caller gets a new
+// token pair, so only the device that made the change stays signed in.
+func (service *AuthServiceImpl) ChangePassword(ctx context.Context, request web.AuthChangePasswordRequest) (web.AuthTokenResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	mails := newOutbox(service.Mailer)
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
+
+	user, err := service.UserRepository.FindById(ctx, tx, request.UserId)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	now := time.Now()
+
+	// Wrong current passwords count as failed logins, so a stolen token
+	// can't be used to guess the password.
+	throttleKeys := loginThrottleKeys(web.AuthLoginRequest{Email: user.Email, ClientIp: request.ClientIp})
+	if err := checkLoginThrottle(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	if !utils.CheckPassword(request.CurrentPassword, user.PasswordHash) {
+		if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+		return web.AuthTokenResponse{}, exception.ValidationError{Errors: []exception.FieldError{{
+			Field:   "current_password",
+			Code:    "incorrect",
+			Message: "is incorrect",
+		}}}
+	}
+
+	if err := service.LoginAttemptRepository.Reset(ctx, tx, throttleKeys[0].Key); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	if err := checkPasswordPolicy("new_password", request.NewPassword, user.Email, user.FullName); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+	if err := checkPasswordReuse(ctx, tx, service.PasswordHistoryRepository, user, "new_password", request.NewPassword); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	hashed, err := utils.HashPassword(request.NewPassword)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	if err := rememberPassword(ctx, tx, service.PasswordHistoryRepository, user); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	if err := service.UserRepository.ChangePassword(ctx, tx, user.Id.String(), hashed, now); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	if err := revokeAllSessions(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, service.TokenVersionStore, &user, now); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	mails.add(mailer.Message{
+		To:      user.Email,
+		Subject: "Your password has been changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe password of your account was changed on %s and every other device has been signed out.\n\nIf this was not you, reset your password right away and contact support.",
+			user.FullName, now.UTC().Format(time.RFC1123)),
+	})
+
+	user.PasswordHash = hashed
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = false
+
+	client := sessionClient{Ip: request.ClientIp, UserAgent: request.UserAgent}
+
+	return service.issueTokens(ctx, tx, user, newAuthentication(utils.AmrPassword), client.newSession(user), uuid.New())
+}
+
+// Reauthenticate lets a signed-in user prove again who they are, with the
+// password, a second factor or both, before a sensitive action. The new
+// auth_time is stored on the session's refresh token too, so it survives
+// the next refresh. Wrong answers count as failed logins.
+func (service *AuthServiceImpl) Reauthenticate(ctx context.Context, request web.AuthReauthenticateRequest) (web.AuthTokenResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	familyId, err := uuid.Parse(request.SessionId)
+	if err != nil {
+		return web.AuthTokenResponse{}, errors.New("token has no session, sign in again")
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	user, err := service.UserRepository.FindById(ctx, tx, request.UserId)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	now := time.Now()
+	throttleKeys := loginThrottleKeys(web.AuthLoginRequest{Email: user.Email, ClientIp: request.ClientIp})
+	if err := checkLoginThrottle(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	auth := authentication{Time: now}
+	verified := true
+
+	if request.Password != "" {
+		verified = utils.CheckPassword(request.Password, user.PasswordHash)
+		auth.Methods = append(auth.Methods, utils.AmrPassword)
+	}
+
+	if verified && (request.Code != "" || request.RecoveryCode != "") {
+		verified = user.MfaEnabled && service.MfaService.VerifySecondFactor(ctx, tx, user, request.Code, request.RecoveryCode) == nil
+		auth.Methods = append(auth.Methods, utils.AmrOtp)
+	}
+
+	if !verified {
+		if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+		return web.AuthTokenResponse{}, errors.New("invalid password or code")
+	}
+
+	if len(auth.Methods) > 1 {
+		auth.Methods = append(auth.Methods, utils.AmrMultiFactor)
+	}
+
+	if err := service.LoginAttemptRepository.Reset(ctx, tx, throttleKeys[0].Key); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	active, err := service.RefreshTokenRepository.UpdateAuthentication(ctx, tx, user.Id, familyId, auth.Time, auth.amr())
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+	if !active {
+		return web.AuthTokenResponse{}, errors.New("session has ended, sign in again")
+	}
+
+	accessToken, jti, err := issueAccessToken(user, auth, familyId)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	if err := service.SessionRepository.Touch(ctx, tx, domain.Session{Id: familyId, Jti: jti, LastSeenAt: now}); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	return web.AuthTokenResponse{
+		Token:     accessToken,
+		TokenType: "Bearer",
+		ExpiresIn: int64(utils.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// StartPasswordless emails a single-use sign-in link or a 6-digit code. It
+// answers the same way whether or not the email belongs to an account; to
+// also take as long, the mailer must not wait for delivery (main wires a
+// mailer.BackgroundMailer). A new link or code replaces the pending one,
+// at most one is sent a minute and PASSWORDLESS_MAX_PER_HOUR caps how many
+// are sent an hour.
+func (service *AuthServiceImpl) StartPasswordless(ctx context.Context, request web.AuthPasswordlessStartRequest) error {
+	if err := service.Validate.Struct(request); err != nil {
+		return err
+	}
+
+	mails := newOutbox(service.Mailer)
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
+
+	user, err := service.AuthRepository.FindByEmail(ctx, tx, request.Email)
+	if err != nil {
+		return nil
+	}
+
+	allowed, err := service.allowPasswordless(ctx, tx, user.Id)
+	if err != nil || !allowed {
+		return err
+	}
+
+	if request.Method == "code" {
+		err = service.queuePasswordlessCode(ctx, tx, mails, user)
+	} else {
+		err = service.queuePasswordlessLink(ctx, tx, mails, user)
+	}
+	if err != nil {
+		log.Println("Queue passwordless email fail:", err)
+	}
+
+	return nil
+}
+
+// VerifyPasswordless signs the user in with a link token or an emailed
+// code. Wrong codes count as failed logins and burn the code after
+// maxMfaAttempts. Using either proves the user reads the address, so an
+// unverified email becomes verified.
+func (service *AuthServiceImpl) VerifyPasswordless(ctx context.Context, request web.AuthPasswordlessVerifyRequest) (web.AuthTokenResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	var user domain.User
+	var err error
+	if request.Token != "" {
+		user, err = service.verifyPasswordlessLink(ctx, tx, request.Token)
+	} else {
+		user, err = service.verifyPasswordlessCode(ctx, tx, request)
+	}
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	now := time.Now()
+	for _, purpose := range []string{domain.UserTokenPasswordlessLink, domain.UserTokenPasswordlessCode} {
+		if err := service.UserTokenRepository.InvalidateByUser(ctx, tx, user.Id, purpose, now); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+	}
+
+	if !user.IsVerified {
+		if err := service.UserRepository.MarkVerified(ctx, tx, user.Id.String()); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+		user.IsVerified = true
+	}
+
+	client := sessionClient{Ip: request.ClientIp, UserAgent: request.UserAgent}
+
+	return service.completeLogin(ctx, tx, user, newAuthentication(utils.AmrOtp), false, client)
+}
+
+func (service *AuthServiceImpl) verifyPasswordlessLink(ctx context.Context, tx *gorm.DB, rawToken string) (domain.User, error) {
+	token, err := service.consumeUserToken(ctx, tx, domain.UserTokenPasswordlessLink, rawToken)
+	if err != nil {
+		return domain.User{}, errors.New("invalid or expired sign-in link")
+	}
+
+	user, err := service.UserRepository.FindById(ctx, tx, token.UserId.String())
+	if err != nil {
+		return domain.User{}, errors.New("invalid or expired sign-in link")
+	}
+
+	return user, nil
+}
+
+func (service *AuthServiceImpl) verifyPasswordlessCode(ctx context.Context, tx *gorm.DB, request web.AuthPasswordlessVerifyRequest) (domain.User, error) {
+	now := time.Now()
+	throttleKeys := loginThrottleKeys(web.AuthLoginRequest{Email: request.Email, ClientIp: request.ClientIp})
+	if err := checkLoginThrottle(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+		return domain.User{}, err
+	}
+
+	user, err := service.AuthRepository.FindByEmail(ctx, tx, request.Email)
+	var token domain.UserToken
+	if err == nil {
+		token, err = service.UserTokenRepository.FindActiveByUser(ctx, tx, user.Id, domain.UserTokenPasswordlessCode, now)
+	}
+
+	if err != nil || subtle.ConstantTimeCompare([]byte(passwordlessCodeHash(token.Id, request.Code)), []byte(token.TokenHash)) != 1 {
+		if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+			return domain.User{}, err
+		}
+		if token.Id != uuid.Nil {
+			if err := service.UserTokenRepository.IncrementAttempts(ctx, tx, token.Id); err != nil {
+				return domain.User{}, err
+			}
+			if token.Attempts+1 >= maxMfaAttempts {
+				if _, err := service.UserTokenRepository.MarkUsed(ctx, tx, token.Id, now); err != nil {
+					return domain.User{}, err
+				}
+			}
+		}
+		return domain.User{}, errors.New("invalid or expired code")
+	}
+
+	used, err := service.UserTokenRepository.MarkUsed(ctx, tx, token.Id, now)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if !used {
+		return domain.User{}, errors.New("invalid or expired code")
+	}
+
+	if err := service.LoginAttemptRepository.Reset(ctx, tx, throttleKeys[0].Key); err != nil {
+		return domain.User{}, err
+	}
+
+	return user, nil
+}
+
+func (service *AuthServiceImpl) rehashPassword(ctx context.Context, tx *gorm.DB, user domain.User, password string) error {
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return service.UserRepository.UpdatePassword(ctx, tx, user.Id.String(), hashed)
+}
+
+func accountExistsEmail(user domain.User) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "You already have an account",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone tried to sign up with this email address, but it already belongs to your account. You can sign in as usual, or reset your password if you forgot it.\n\nIf this was not you, you can ignore this email.",
+			user.FullName),
+	}
+}
+
+func (service *AuthServiceImpl) queuePasswordResetEmail(ctx context.Context, tx *gorm.DB, mails *outbox, user domain.User) error {
+	ttl := utils.GetEnvDuration("PASSWORD_RESET_TTL", time.Hour)
+	token, err := service.createUserToken(ctx, tx, user.Id, domain.UserTokenPasswordReset, ttl)
+	if err != nil || token == "" {
+		return err
+	}
+
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		resetURL = "http://127.0.0.1:3000/reset-password?token="
+	}
+
+	mails.add(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. Open the link below to choose a new one:\n\n%s%s\n\nThe link expires in %s and can only be used once. If you did not ask for this, ignore this email.",
+			user.FullName, resetURL, token, ttl),
+	})
+
+	return nil
+}
+
+// allowPasswordless applies the passwordless rate limits, counting links
+// and codes together.
+func (service *AuthServiceImpl) allowPasswordless(ctx context.Context, tx *gorm.DB, userId uuid.UUID) (bool, error) {
+	now := time.Now()
+	maxPerHour := int64(utils.GetEnvInt("PASSWORDLESS_MAX_PER_HOUR", 5))
+
+	var lastMinute, lastHour int64
+	for _, purpose := range []string{domain.UserTokenPasswordlessLink, domain.UserTokenPasswordlessCode} {
+		minute, err := service.UserTokenRepository.CountCreatedSince(ctx, tx, userId, purpose, now.Add(-time.Minute))
+		if err != nil {
+			return false, err
+		}
+		hour, err := service.UserTokenRepository.CountCreatedSince(ctx, tx, userId, purpose, now.Add(-time.Hour))
+		if err != nil {
+			return false, err
+		}
+		lastMinute += minute
+		lastHour += hour
+	}
+
+	return lastMinute == 0 && lastHour < maxPerHour, nil
+}
+
+func (service *AuthServiceImpl) queuePasswordlessLink(ctx context.Context, tx *gorm.DB, mails *outbox, user domain.User) error {
+	ttl := utils.GetEnvDuration("PASSWORDLESS_LINK_TTL", 15*time.Minute)
+	now := time.Now()
+
+	if err := service.UserTokenRepository.InvalidateByUser(ctx, tx, user.Id, domain.UserTokenPasswordlessLink, now); err != nil {
+		return err
+	}
+
+	token, err := service.saveUserToken(ctx, tx, user.Id, domain.UserTokenPasswordlessLink, now.Add(ttl))
+	if err != nil {
+		return err
+	}
+
+	loginURL := os.Getenv("PASSWORDLESS_LOGIN_URL")
+	if loginURL == "" {
+		loginURL = "http://127.0.0.1:3000/passwordless?token="
+	}
+
+	mails.add(mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to sign in:\n\n%s%s\n\nThe link expires in %s and can only be used once. If you did not ask for this, ignore this email.",
+			user.FullName, loginURL, token, ttl),
+	})
+
+	return nil
+}
+
+// queuePasswordlessCode stores the code hashed together with its token id,
+// since six digits alone would collide across users and are easy to
+// reverse. Codes are checked against the user's pending token instead.
+func (service *AuthServiceImpl) queuePasswordlessCode(ctx context.Context, tx *gorm.DB, mails *outbox, user domain.User) error {
+	ttl := utils.GetEnvDuration("PASSWORDLESS_CODE_TTL", 10*time.Minute)
+	now := time.Now()
+
+	if err := service.UserTokenRepository.InvalidateByUser(ctx, tx, user.Id, domain.UserTokenPasswordlessCode, now); err != nil {
+		return err
+	}
+
+	code, err := utils.GenerateNumericCode(6)
+	if err != nil {
+		return err
+	}
+
+	tokenId := uuid.New()
+	if _, err := service.UserTokenRepository.Save(ctx, tx, domain.UserToken{
+		Id:        tokenId,
+		UserId:    user.Id,
+		Purpose:   domain.UserTokenPasswordlessCode,
+		TokenHash: passwordlessCodeHash(tokenId, code),
+		ExpiresAt: now.Add(ttl),
+	}); err != nil {
+		return err
+	}
+
+	mails.add(mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in code",
+		Body: fmt.Sprintf("Hi %s,\n\nYour sign-in code is %s\n\nIt expires in %s. If you did not ask for this, ignore this email.",
+			user.FullName, code, ttl),
+	})
+
+	return nil
+}
+
+func passwordlessCodeHash(tokenId uuid.UUID, code string) string {
+	return utils.HashToken(tokenId.String() + ":" + code)
+}
+
+// createUserToken replaces any pending token of the same purpose with a new
+// one. It returns an empty token when one was already sent within the last
+// minute, so these endpoints cannot be used to flood an inbox.
+func (service *AuthServiceImpl) createUserToken(ctx context.Context, tx *gorm.DB, userId uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	recent, err := service.UserTokenRepository.CountCreatedSince(ctx, tx, userId, purpose, now.Add(-time.Minute))
+	if err != nil || recent > 0 {
+		return "", err
+	}
+
+	if err := service.UserTokenRepository.InvalidateByUser(ctx, tx, userId, purpose, now); err != nil {
 		return "", err
 	}
 
-	token, err := utils.GenerateJWT(user.Id.String(), user.Role)
+	return service.saveUserToken(ctx, tx, userId, purpose, now.Add(ttl))
+}
+
+func (service *AuthServiceImpl) saveUserToken(ctx context.Context, tx *gorm.DB, userId uuid.UUID, purpose string, expiresAt time.Time) (string, error) {
+	token, err := utils.GenerateOpaqueToken()
 	if err != nil {
 		return "", err
 	}
 
+	_, err = service.UserTokenRepository.Save(ctx, tx, domain.UserToken{
+		UserId:    userId,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (service *AuthServiceImpl) startMfaChallenge(ctx context.Context, tx *gorm.DB, user domain.User) (web.AuthTokenResponse, error) {
+	ttl := utils.GetEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute)
+	mfaToken, err := service.saveUserToken(ctx, tx, user.Id, domain.UserTokenMfaChallenge, time.Now().Add(ttl))
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	return web.AuthTokenResponse{
+		MfaRequired: true,
+		MfaToken:    mfaToken,
+		ExpiresIn:   int64(ttl.Seconds()),
+	}, nil
+}
+
+// issueMfaEnrollmentToken is used when the user's role requires MFA but none
+// is set up yet. The token is only accepted by the enrollment endpoints and
+// comes without a refresh token.
+func issueMfaEnrollmentToken(user domain.User) (web.AuthTokenResponse, error) {
+	accessToken, err := utils.GenerateJWT(user.Id.String(), user.Role, jwt.MapClaims{
+		"email_verified": user.IsVerified,
+		"ver":            user.TokenVersion,
+		"restricted_to":  utils.RestrictionMfaEnrollment,
+	})
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	return web.AuthTokenResponse{
+		Token:                 accessToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             int64(utils.AccessTokenTTL().Seconds()),
+		MfaEnrollmentRequired: true,
+	}, nil
+}
+
+func (service *AuthServiceImpl) consumeUserToken(ctx context.Context, tx *gorm.DB, purpose string, rawToken string) (domain.UserToken, error) {
+	now := time.Now()
+
+	token, err := service.UserTokenRepository.FindByTokenHash(ctx, tx, purpose, utils.HashToken(rawToken))
+	if err != nil {
+		return domain.UserToken{}, err
+	}
+
+	if token.UsedAt != nil || now.After(token.ExpiresAt) {
+		return domain.UserToken{}, errors.New("token already used or expired")
+	}
+
+	used, err := service.UserTokenRepository.MarkUsed(ctx, tx, token.Id, now)
+	if err != nil {
+		return domain.UserToken{}, err
+	}
+	if !used {
+		return domain.UserToken{}, errors.New("token already used or expired")
+	}
+
 	return token, nil
 }
+
+func requireVerifiedEmail() bool {
+	return utils.GetEnvBool("REQUIRE_VERIFIED_EMAIL", false)
+}
+
+func (service *AuthServiceImpl) revokeReusedFamily(ctx context.Context, tx *gorm.DB, stored domain.RefreshToken, now time.Time) error {
+	if err := service.RefreshTokenRepository.RevokeFamily(ctx, tx, stored.FamilyId, now); err != nil {
+		return err
+	}
+
+	return errors.New("refresh token reuse detected, please login again")
+}
+
+// issuePasswordChangeToken gives a user whose password must be changed an
+// access token for POST /users/me/password only, with no refresh token.
+func issuePasswordChangeToken(user domain.User) (web.AuthTokenResponse, error) {
+	accessToken, err := utils.GenerateJWT(user.Id.String(), user.Role, jwt.MapClaims{
+		"email_verified": user.IsVerified,
+		"ver":            user.TokenVersion,
+		"restricted_to":  utils.RestrictionPasswordChange,
+	})
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	return web.AuthTokenResponse{
+		Token:              accessToken,
+		TokenType:          "Bearer",
+		ExpiresIn:          int64(utils.AccessTokenTTL().Seconds()),
+		MustChangePassword: true,
+	}, nil
+}
+
+// issueAccessToken signs an access token for the session familyId. The
+// "sid" claim ties it to the refresh token family it came from.
+// issueAccessToken also returns the token's jti, which is recorded on the
+// session.
+func issueAccessToken(user domain.User, auth authentication, familyId uuid.UUID) (string, string, error) {
+	jti := uuid.NewString()
+	accessToken, err := utils.GenerateJWT(user.Id.String(), user.Role, jwt.MapClaims{
+		"email_verified": user.IsVerified,
+		"ver":            user.TokenVersion,
+		"sid":            familyId.String(),
+		"jti":            jti,
+	}, auth.claims())
+
+	return accessToken, jti, err
+}
+
+// issueTokens issues tokens for session. A session that was not saved yet,
+// from sessionClient.newSession, is started by a new sign-in; refreshes
+// pass the stored session and keep it.
+func (service *AuthServiceImpl) issueTokens(ctx context.Context, tx *gorm.DB, user domain.User, auth authentication, session domain.Session, refreshTokenId uuid.UUID) (web.AuthTokenResponse, error) {
+	now := time.Now()
+	if passwordChangeRequired(user, now) {
+		return issuePasswordChangeToken(user)
+	}
+
+	newSession := session.CreatedAt.IsZero()
+	if newSession {
+		if session.RememberMe {
+			if err := forgetDevice(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, user.Id, session.DeviceId, now); err != nil {
+				return web.AuthTokenResponse{}, err
+			}
+		}
+
+		if err := enforceSessionLimit(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, user.Id, now); err != nil {
+			return web.AuthTokenResponse{}, err
+		}
+	}
+
+	accessToken, jti, err := issueAccessToken(user, auth, session.Id)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	stored := domain.RefreshToken{
+		Id:        refreshTokenId,
+		UserId:    user.Id,
+		FamilyId:  session.Id,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: now.Add(refreshTokenTTL(session, user.Role)),
+		Amr:       auth.amr(),
+		ClientId:  auth.ClientId,
+		Scope:     auth.Scope,
+	}
+	if !auth.Time.IsZero() {
+		stored.AuthTime = &auth.Time
+	}
+
+	_, err = service.RefreshTokenRepository.Save(ctx, tx, stored)
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	session.Jti = jti
+	session.LastSeenAt = now
+	session.ExpiresAt = stored.ExpiresAt
+	if newSession {
+		_, err = service.SessionRepository.Save(ctx, tx, session)
+	} else {
+		err = service.SessionRepository.Touch(ctx, tx, session)
+	}
+	if err != nil {
+		return web.AuthTokenResponse{}, err
+	}
+
+	response := web.AuthTokenResponse{
+		Token:            accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(utils.AccessTokenTTL().Seconds()),
+		RefreshExpiresIn: int64(stored.ExpiresAt.Sub(now).Seconds()),
+	}
+	if session.RememberMe {
+		response.DeviceId = session.DeviceId
+	}
+
+	return response, nil
+}
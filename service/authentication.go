@@ -0,0 +1,59 @@
+package service
+
+import (
+	"auth-api-jwt/models/domain"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// authentication describes when and how the user last proved who they are.
+// It becomes the auth_time and amr claims of access tokens, which
+// RequireRecentAuth uses to guard sensitive actions. ClientId and Scope
+// are set when the user signed in to an OAuth client, and become the
+// client_id, scope and aud claims. Such delegated tokens are only accepted
+// on routes meant for OAuth clients, like /userinfo.
+type authentication struct {
+	Time     time.Time
+	Methods  []string
+	ClientId string
+	Scope    string
+}
+
+func newAuthentication(methods ...string) authentication {
+	return authentication{Time: time.Now(), Methods: methods}
+}
+
+// storedAuthentication reads the authentication saved with a refresh token.
+// Tokens saved before it was recorded have none, so their sessions must
+// re-authenticate before a sensitive action.
+func storedAuthentication(token domain.RefreshToken) authentication {
+	auth := authentication{ClientId: token.ClientId, Scope: token.Scope}
+	if token.AuthTime != nil {
+		auth.Time = *token.AuthTime
+		auth.Methods = strings.Fields(token.Amr)
+	}
+
+	return auth
+}
+
+func (auth authentication) amr() string {
+	return strings.Join(auth.Methods, " ")
+}
+
+func (auth authentication) claims() jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	if !auth.Time.IsZero() {
+		claims["auth_time"] = auth.Time.Unix()
+		claims["amr"] = auth.Methods
+	}
+
+	if auth.ClientId != "" {
+		claims["client_id"] = auth.ClientId
+		claims["aud"] = auth.ClientId
+		claims["scope"] = auth.Scope
+	}
+
+	return claims
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"auth-api-jwt/mailer"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// queueVerificationEmail replaces any pending verification token of user
+// with one for user.Email and queues the link to that address. The token
+// records the address, so it cannot verify another one the user switches
+// to later. With throttle set nothing is sent when a link went out in the
+// last minute, so the public endpoints cannot be used to flood an inbox.
+func queueVerificationEmail(ctx context.Context, tx *gorm.DB, userTokens repository.UserTokenRepository, mails *outbox, user domain.User, throttle bool) error {
+	now := time.Now()
+
+	if throttle {
+		recent, err := userTokens.CountCreatedSince(ctx, tx, user.Id, domain.UserTokenEmailVerification, now.Add(-time.Minute))
+		if err != nil || recent > 0 {
+			return err
+		}
+	}
+
+	if err := userTokens.InvalidateByUser(ctx, tx, user.Id, domain.UserTokenEmailVerification, now); err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = userTokens.Save(ctx, tx, domain.UserToken{
+		UserId:    user.Id,
+		Purpose:   domain.UserTokenEmailVerification,
+		TokenHash: utils.HashToken(token),
+		Email:     user.Email,
+		ExpiresAt: now.Add(utils.GetEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)),
+	})
+	if err != nil {
+		return err
+	}
+
+	verificationURL := os.Getenv("EMAIL_VERIFICATION_URL")
+	if verificationURL == "" {
+		verificationURL = "http://127.0.0.1:3000/verify-email?token="
+	}
+
+	mails.add(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening the link below:\n\n%s%s\n\nThe link can only be used once.",
+			user.FullName, verificationURL, token),
+	})
+
+	return nil
+}
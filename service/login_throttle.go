@@ -0,0 +1,147 @@
+package service
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxLoginDelay caps the progressive delay between failed logins.
+const maxLoginDelay = 30 * time.Second
+
+type loginThrottleKey struct {
+	Key         string
+	MaxFailures int
+	Account     bool
+}
+
+func emailAttemptKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginThrottleKeys counts failures per email, whether or not an account
+// exists for it, and per client IP.
+func loginThrottleKeys(request web.AuthLoginRequest) []loginThrottleKey {
+	keys := []loginThrottleKey{{
+		Key:         emailAttemptKey(request.Email),
+		MaxFailures: utils.GetEnvInt("LOGIN_MAX_FAILURES", 5),
+		Account:     true,
+	}}
+
+	if request.ClientIp != "" {
+		keys = append(keys, loginThrottleKey{
+			Key:         "ip:" + request.ClientIp,
+			MaxFailures: utils.GetEnvInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+		})
+	}
+
+	return keys
+}
+
+// userCodeThrottleKeys counts failed device user code lookups per client
+// IP (RFC 8628 section 5.1).
+func userCodeThrottleKeys(clientIp string) []loginThrottleKey {
+	if clientIp == "" {
+		return nil
+	}
+
+	return []loginThrottleKey{{
+		Key:         "device-ip:" + clientIp,
+		MaxFailures: utils.GetEnvInt("DEVICE_CODE_MAX_FAILURES_PER_IP", 10),
+	}}
+}
+
+// deviceSignInThrottleKeys counts failed sign-ins on the /device page per
+// device request, on top of the usual email and IP keys.
+func deviceSignInThrottleKeys(userCodeHash string) []loginThrottleKey {
+	return []loginThrottleKey{{
+		Key:         "device-code:" + userCodeHash,
+		MaxFailures: utils.GetEnvInt("DEVICE_CODE_MAX_SIGNIN_FAILURES", 5),
+	}}
+}
+
+func checkLoginThrottle(ctx context.Context, tx *gorm.DB, attempts repository.LoginAttemptRepository, keys []loginThrottleKey, now time.Time) error {
+	for _, key := range keys {
+		attempt, err := attempts.Find(ctx, tx, key.Key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+			retryAfter := attempt.LockedUntil.Sub(now)
+			if key.Account {
+				return exception.AccountLockedError{Message: "account temporarily locked due to too many failed login attempts", RetryAfter: retryAfter}
+			}
+			return exception.TooManyRequestsError{Message: "too many failed login attempts from this address", RetryAfter: retryAfter}
+		}
+
+		if now.Sub(attempt.LastFailureAt) > loginFailureWindow() {
+			continue
+		}
+
+		if wait := attempt.LastFailureAt.Add(loginDelay(attempt.Failures)).Sub(now); wait > 0 {
+			return exception.TooManyRequestsError{Message: "too many failed login attempts, try again later", RetryAfter: wait}
+		}
+	}
+
+	return nil
+}
+
+func recordLoginFailure(ctx context.Context, tx *gorm.DB, attempts repository.LoginAttemptRepository, keys []loginThrottleKey, now time.Time) error {
+	for _, key := range keys {
+		// Failures outside the window no longer count, unless they still
+		// hold a lock.
+		attempt, err := attempts.Find(ctx, tx, key.Key)
+		if err == nil && now.Sub(attempt.LastFailureAt) > loginFailureWindow() &&
+			(attempt.LockedUntil == nil || now.After(*attempt.LockedUntil)) {
+			if err := attempts.Reset(ctx, tx, key.Key); err != nil {
+				return err
+			}
+		}
+
+		attempt, err = attempts.RecordFailure(ctx, tx, key.Key, now)
+		if err != nil {
+			return err
+		}
+
+		if attempt.Failures >= key.MaxFailures {
+			lockout := utils.GetEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+			if err := attempts.Lock(ctx, tx, key.Key, now.Add(lockout)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loginDelay lets the first two failures through immediately, then doubles
+// the wait from one second for every further failure.
+func loginDelay(failures int) time.Duration {
+	if failures < 3 {
+		return 0
+	}
+	if failures-3 >= 5 {
+		return maxLoginDelay
+	}
+
+	delay := time.Second << (failures - 3)
+	if delay > maxLoginDelay {
+		return maxLoginDelay
+	}
+	return delay
+}
+
+func loginFailureWindow() time.Duration {
+	return utils.GetEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute)
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type MfaService interface {
+	Enroll(ctx context.Context, userId string) (web.MfaEnrollResponse, error)
+	Confirm(ctx context.Context, request web.MfaCodeRequest) (web.MfaRecoveryCodesResponse, error)
+	Disable(ctx context.Context, request web.MfaCodeRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, request web.MfaCodeRequest) (web.MfaRecoveryCodesResponse, error)
+	// VerifySecondFactor checks a TOTP or recovery code inside the caller's
+	// transaction and burns it, so it cannot be replayed.
+	VerifySecondFactor(ctx context.Context, tx *gorm.DB, user domain.User, code string, recoveryCode string) error
+	RequiredForRole(role string) bool
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+type MfaServiceImpl struct {
+	UserRepository            repository.UserRepository
+	MfaRecoveryCodeRepository repository.MfaRecoveryCodeRepository
+	LoginAttemptRepository    repository.LoginAttemptRepository
+	DB                        *gorm.DB
+	Validate                  *validator.Validate
+}
+
+func NewMfaService(userRepository repository.UserRepository, mfaRecoveryCodeRepository repository.MfaRecoveryCodeRepository, loginAttemptRepository repository.LoginAttemptRepository, DB *gorm.DB, validate *validator.Validate) MfaService {
+	return &MfaServiceImpl{
+		UserRepository:            userRepository,
+		MfaRecoveryCodeRepository: mfaRecoveryCodeRepository,
+		LoginAttemptRepository:    loginAttemptRepository,
+		DB:                        DB,
+		Validate:                  validate,
+	}
+}
+
+// Enroll stores a new pending secret. MFA is only switched on once Confirm
+// proves the user's authenticator produces matching codes.
+func (service *MfaServiceImpl) Enroll(ctx context.Context, userId string) (web.MfaEnrollResponse, error) {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	user, err := service.UserRepository.FindById(ctx, tx, userId)
+	if err != nil {
+		return web.MfaEnrollResponse{}, errors.New("user not found")
+	}
+
+	if user.MfaEnabled {
+		return web.MfaEnrollResponse{}, errors.New("mfa already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return web.MfaEnrollResponse{}, err
+	}
+
+	if err := service.UserRepository.UpdateMfa(ctx, tx, userId, false, secret); err != nil {
+		return web.MfaEnrollResponse{}, err
+	}
+
+	return web.MfaEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(mfaIssuer(), user.Email, secret),
+	}, nil
+}
+
+func (service *MfaServiceImpl) Confirm(ctx context.Context, request web.MfaCodeRequest) (web.MfaRecoveryCodesResponse, error) {
+	if err := service.Validate.Var(request.Code, "required"); err != nil {
+		return web.MfaRecoveryCodesResponse{}, errors.New("code is required")
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	user, err := service.UserRepository.FindById(ctx, tx, request.UserId)
+	if err != nil {
+		return web.MfaRecoveryCodesResponse{}, errors.New("user not found")
+	}
+
+	if user.MfaEnabled {
+		return web.MfaRecoveryCodesResponse{}, errors.New("mfa already enabled")
+	}
+	if user.MfaSecret == "" {
+		return web.MfaRecoveryCodesResponse{}, errors.New("mfa enrollment not started")
+	}
+
+	if err := service.verifyTOTP(ctx, tx, user, request.Code); err != nil {
+		return web.MfaRecoveryCodesResponse{}, err
+	}
+
+	if err := service.UserRepository.UpdateMfa(ctx, tx, request.UserId, true, user.MfaSecret); err != nil {
+		return web.MfaRecoveryCodesResponse{}, err
+	}
+
+	return service.replaceRecoveryCodes(ctx, tx, user)
+}
+
+func (service *MfaServiceImpl) Disable(ctx context.Context, request web.MfaCodeRequest) error {
+	if err := service.Validate.Struct(request); err != nil {
+		return err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	user, err := service.UserRepository.FindById(ctx, tx, request.UserId)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	if !user.MfaEnabled {
+		return errors.New("mfa not enabled")
+	}
+	if service.RequiredForRole(user.Role) {
+		return errors.New("mfa is required for your role")
+	}
+
+	if err := service.verifyThrottled(ctx, tx, user, request); err != nil {
+		return err
+	}
+
+	if err := service.UserRepository.UpdateMfa(ctx, tx, request.UserId, false, ""); err != nil {
+		return err
+	}
+
+	return service.MfaRecoveryCodeRepository.DeleteByUser(ctx, tx, user.Id)
+}
+
+func (service *MfaServiceImpl) RegenerateRecoveryCodes(ctx context.Context, request web.MfaCodeRequest) (web.MfaRecoveryCodesResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.MfaRecoveryCodesResponse{}, err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	user, err := service.UserRepository.FindById(ctx, tx, request.UserId)
+	if err != nil {
+		return web.MfaRecoveryCodesResponse{}, errors.New("user not found")
+	}
+
+	if !user.MfaEnabled {
+		return web.MfaRecoveryCodesResponse{}, errors.New("mfa not enabled")
+	}
+
+	if err := service.verifyThrottled(ctx, tx, user, request); err != nil {
+		return web.MfaRecoveryCodesResponse{}, err
+	}
+
+	return service.replaceRecoveryCodes(ctx, tx, user)
+}
+
+func (service *MfaServiceImpl) VerifySecondFactor(ctx context.Context, tx *gorm.DB, user domain.User, code string, recoveryCode string) error {
+	if code != "" {
+		return service.verifyTOTP(ctx, tx, user, code)
+	}
+
+	if recoveryCode == "" {
+		return errors.New("invalid mfa code")
+	}
+
+	codeHash := utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))
+	used, err := service.MfaRecoveryCodeRepository.Use(ctx, tx, user.Id, codeHash, time.Now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errors.New("invalid mfa code")
+	}
+
+	return nil
+}
+
+// verifyThrottled checks the second factor of a signed-in user. Wrong codes
+// count as failed logins, so a stolen access token cannot be used to guess
+// them any faster than through login.
+func (service *MfaServiceImpl) verifyThrottled(ctx context.Context, tx *gorm.DB, user domain.User, request web.MfaCodeRequest) error {
+	now := time.Now()
+	throttleKeys := loginThrottleKeys(web.AuthLoginRequest{Email: user.Email, ClientIp: request.ClientIp})
+	if err := checkLoginThrottle(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+		return err
+	}
+
+	if verifyErr := service.VerifySecondFactor(ctx, tx, user, request.Code, request.RecoveryCode); verifyErr != nil {
+		if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+			return err
+		}
+		return verifyErr
+	}
+
+	return service.LoginAttemptRepository.Reset(ctx, tx, throttleKeys[0].Key)
+}
+
+func (service *MfaServiceImpl) RequiredForRole(role string) bool {
+	for _, required := range utils.GetEnvList("MFA_REQUIRED_ROLES") {
+		if required == role {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTOTP refuses a code from a time step that was already accepted, so
+// a code seen over someone's shoulder cannot be used a second time.
+func (service *MfaServiceImpl) verifyTOTP(ctx context.Context, tx *gorm.DB, user domain.User, code string) error {
+	step, ok := utils.ValidateTOTP(user.MfaSecret, code, time.Now())
+	if !ok {
+		return errors.New("invalid mfa code")
+	}
+
+	used, err := service.UserRepository.UseMfaStep(ctx, tx, user.Id.String(), step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errors.New("invalid mfa code")
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes returns the only plaintext copy of the new codes;
+// just their hashes are stored.
+func (service *MfaServiceImpl) replaceRecoveryCodes(ctx context.Context, tx *gorm.DB, user domain.User) (web.MfaRecoveryCodesResponse, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return web.MfaRecoveryCodesResponse{}, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+	}
+
+	if err := service.MfaRecoveryCodeRepository.ReplaceForUser(ctx, tx, user.Id, hashes); err != nil {
+		return web.MfaRecoveryCodesResponse{}, err
+	}
+
+	return web.MfaRecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Auth API"
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"auth-api-jwt/models/web"
+	"context"
+	"errors"
+)
+
+// ErrInvalidUserCode is returned for a device user code that doesn't name a
+// pending device request.
+var ErrInvalidUserCode = errors.New("invalid or expired code")
+
+type OAuthService interface {
+	CreateClient(ctx context.Context, request web.OAuthClientCreateRequest) (web.OAuthClientResponse, error)
+	FindAllClients(ctx context.Context) ([]web.OAuthClientResponse, error)
+	DeleteClient(ctx context.Context, clientId string) error
+	// ValidateAuthorization checks an authorization request before the
+	// login page is shown.
+	ValidateAuthorization(ctx context.Context, request web.OAuthAuthorizeRequest) (web.OAuthConsentResponse, error)
+	// Authorize signs the user in from the login page and returns the
+	// redirect URI that hands the authorization code to the client.
+	Authorize(ctx context.Context, request web.OAuthAuthorizeRequest) (string, error)
+	Token(ctx context.Context, request web.OAuthTokenRequest) (web.OAuthTokenResponse, error)
+	// DeviceAuthorization starts the device grant: the device shows the
+	// user code and polls Token with the device code.
+	DeviceAuthorization(ctx context.Context, request web.OAuthDeviceAuthorizationRequest) (web.OAuthDeviceAuthorizationResponse, error)
+	// ValidateUserCode looks up a pending device request for the /device
+	// page.
+	ValidateUserCode(ctx context.Context, request web.OAuthDeviceVerifyRequest) (web.OAuthConsentResponse, error)
+	// VerifyDevice signs the user in from the /device page and approves or
+	// denies the device request; either answer needs the user's
+	// credentials.
+	VerifyDevice(ctx context.Context, request web.OAuthDeviceVerifyRequest) error
+	// Introspect tells a confidential client, usually a resource server,
+	// whether an access or refresh token is active. Access tokens go through
+	// the same VerifyToken checks as JWTMiddleware, so restricted tokens are
+	// never active.
+	Introspect(ctx context.Context, request web.OAuthIntrospectRequest) (web.OAuthIntrospectResponse, error)
+	// Revoke revokes an access or refresh token issued to the calling
+	// client. Unknown or already invalid tokens are ignored.
+	Revoke(ctx context.Context, request web.OAuthRevokeRequest) error
+	// UserInfo returns the claims of the user the scopes of their access
+	// token allow. The openid scope is required.
+	UserInfo(ctx context.Context, userId string, scopes []string) (web.OIDCUserInfoResponse, error)
+	OpenIDConfiguration() web.OpenIDConfigurationResponse
+}
@@ -0,0 +1,847 @@
+package service
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/helper"
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type OAuthServiceImpl struct {
+	OAuthClientRepository            repository.OAuthClientRepository
+	OAuthAuthorizationCodeRepository repository.OAuthAuthorizationCodeRepository
+	OAuthDeviceCodeRepository        repository.OAuthDeviceCodeRepository
+	UserRepository                   repository.UserRepository
+	RefreshTokenRepository           repository.RefreshTokenRepository
+	SessionRepository                repository.SessionRepository
+	LoginAttemptRepository           repository.LoginAttemptRepository
+	RevocationStore                  repository.RevocationStore
+	AuthService                      AuthService
+	UserService                      UserService
+	JWTConfig                        middleware.JWTConfig
+	DB                               *gorm.DB
+	Validate                         *validator.Validate
+}
+
+func NewOAuthService(oauthClientRepository repository.OAuthClientRepository, oauthAuthorizationCodeRepository repository.OAuthAuthorizationCodeRepository, oauthDeviceCodeRepository repository.OAuthDeviceCodeRepository, userRepository repository.UserRepository, refreshTokenRepository repository.RefreshTokenRepository, sessionRepository repository.SessionRepository, loginAttemptRepository repository.LoginAttemptRepository, revocationStore repository.RevocationStore, authService AuthService, userService UserService, jwtConfig middleware.JWTConfig, db *gorm.DB, validate *validator.Validate) OAuthService {
+	return &OAuthServiceImpl{
+		OAuthClientRepository:            oauthClientRepository,
+		OAuthAuthorizationCodeRepository: oauthAuthorizationCodeRepository,
+		OAuthDeviceCodeRepository:        oauthDeviceCodeRepository,
+		UserRepository:                   userRepository,
+		RefreshTokenRepository:           refreshTokenRepository,
+		SessionRepository:                sessionRepository,
+		LoginAttemptRepository:           loginAttemptRepository,
+		RevocationStore:                  revocationStore,
+		AuthService:                      authService,
+		UserService:                      userService,
+		JWTConfig:                        jwtConfig,
+		DB:                               db,
+		Validate:                         validate,
+	}
+}
+
+func (service *OAuthServiceImpl) CreateClient(ctx context.Context, request web.OAuthClientCreateRequest) (web.OAuthClientResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.OAuthClientResponse{}, err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	clientId, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return web.OAuthClientResponse{}, err
+	}
+
+	secret := ""
+	if request.Confidential {
+		if secret, err = utils.GenerateOpaqueToken(); err != nil {
+			return web.OAuthClientResponse{}, err
+		}
+	}
+
+	client := domain.OAuthClient{
+		ClientId:     clientId,
+		Name:         request.Name,
+		RedirectUris: strings.Join(request.RedirectUris, "\n"),
+		Scopes:       strings.Join(request.Scopes, " "),
+	}
+	if secret != "" {
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	client, err = service.OAuthClientRepository.Save(ctx, tx, client)
+	if err != nil {
+		return web.OAuthClientResponse{}, err
+	}
+
+	response := toOAuthClientResponse(client)
+	response.ClientSecret = secret
+
+	return response, nil
+}
+
+func (service *OAuthServiceImpl) FindAllClients(ctx context.Context) ([]web.OAuthClientResponse, error) {
+	clients, err := service.OAuthClientRepository.FindAll(ctx, service.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]web.OAuthClientResponse, 0, len(clients))
+	for _, client := range clients {
+		responses = append(responses, toOAuthClientResponse(client))
+	}
+
+	return responses, nil
+}
+
+func (service *OAuthServiceImpl) DeleteClient(ctx context.Context, clientId string) error {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	deleted, err := service.OAuthClientRepository.Delete(ctx, tx, clientId)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("client not found")
+	}
+
+	// Sessions users signed in to through the client end with it.
+	now := time.Now()
+	familyIds, err := service.RefreshTokenRepository.FindActiveFamiliesByClient(ctx, tx, clientId)
+	if err != nil {
+		return err
+	}
+	for _, familyId := range familyIds {
+		if err := revokeSession(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, familyId, now); err != nil {
+			return err
+		}
+	}
+
+	// Tokens the client got for itself are cut off like a user's.
+	return service.RevocationStore.RevokeUser(ctx, clientId, now, now.Add(clientTokenTTL()))
+}
+
+func (service *OAuthServiceImpl) ValidateAuthorization(ctx context.Context, request web.OAuthAuthorizeRequest) (web.OAuthConsentResponse, error) {
+	client, scopes, err := service.validateAuthorization(ctx, service.DB, request)
+	if err != nil {
+		return web.OAuthConsentResponse{}, err
+	}
+
+	return web.OAuthConsentResponse{ClientId: client.ClientId, ClientName: client.Name, Scopes: scopes}, nil
+}
+
+func (service *OAuthServiceImpl) Authorize(ctx context.Context, request web.OAuthAuthorizeRequest) (string, error) {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	client, scopes, err := service.validateAuthorization(ctx, tx, request)
+	if err != nil {
+		return "", err
+	}
+
+	if request.Decision != "allow" {
+		return "", exception.OAuthError{Code: "access_denied", Description: "the user denied the request", RedirectUri: request.RedirectUri}
+	}
+
+	user, amr, err := service.AuthService.Authenticate(ctx, tx, web.AuthLoginRequest{Email: request.Email, Password: request.Password, ClientIp: request.ClientIp}, request.Code)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = service.OAuthAuthorizationCodeRepository.Save(ctx, tx, domain.OAuthAuthorizationCode{
+		CodeHash:      utils.HashToken(code),
+		ClientId:      client.ClientId,
+		UserId:        user.Id,
+		RedirectUri:   request.RedirectUri,
+		Scope:         strings.Join(scopes, " "),
+		CodeChallenge: request.CodeChallenge,
+		AuthTime:      now,
+		Amr:           strings.Join(amr, " "),
+		Nonce:         request.Nonce,
+		ExpiresAt:     now.Add(utils.GetEnvDuration("OAUTH_CODE_TTL", time.Minute)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return utils.AppendQuery(request.RedirectUri, url.Values{"code": {code}, "state": {request.State}}), nil
+}
+
+// validateAuthorization checks the client and redirect URI first. Until
+// both are known to be good, errors are shown to the user instead of being
+// redirected, so the endpoint cannot be used as an open redirect.
+func (service *OAuthServiceImpl) validateAuthorization(ctx context.Context, tx *gorm.DB, request web.OAuthAuthorizeRequest) (domain.OAuthClient, []string, error) {
+	client, err := service.OAuthClientRepository.FindByClientId(ctx, tx, request.ClientId)
+	if err != nil {
+		return domain.OAuthClient{}, nil, exception.OAuthError{Code: "invalid_client", Description: "unknown client"}
+	}
+
+	if !redirectUriRegistered(client, request.RedirectUri) {
+		return domain.OAuthClient{}, nil, exception.OAuthError{Code: "invalid_request", Description: "redirect_uri is not registered for this client"}
+	}
+
+	if request.ResponseType != "code" {
+		return domain.OAuthClient{}, nil, exception.OAuthError{Code: "unsupported_response_type", Description: "only response_type code is supported", RedirectUri: request.RedirectUri}
+	}
+
+	if len(request.CodeChallenge) != 43 || request.CodeChallengeMethod != "S256" {
+		return domain.OAuthClient{}, nil, exception.OAuthError{Code: "invalid_request", Description: "a PKCE code_challenge with code_challenge_method S256 is required", RedirectUri: request.RedirectUri}
+	}
+
+	if len(request.Nonce) > 255 {
+		return domain.OAuthClient{}, nil, exception.OAuthError{Code: "invalid_request", Description: "nonce is too long", RedirectUri: request.RedirectUri}
+	}
+
+	scopes, ok := grantedScopes(client, request.Scope)
+	if !ok {
+		return domain.OAuthClient{}, nil, exception.OAuthError{Code: "invalid_scope", Description: "the requested scope is not allowed for this client", RedirectUri: request.RedirectUri}
+	}
+	if err := oidcScopeError(scopes, request.RedirectUri); err != nil {
+		return domain.OAuthClient{}, nil, err
+	}
+
+	return client, scopes, nil
+}
+
+func (service *OAuthServiceImpl) Token(ctx context.Context, request web.OAuthTokenRequest) (web.OAuthTokenResponse, error) {
+	switch request.GrantType {
+	case "authorization_code":
+		return service.exchangeCode(ctx, request)
+	case "refresh_token":
+		return service.refresh(ctx, request)
+	case "client_credentials":
+		return service.clientCredentials(ctx, request)
+	case deviceCodeGrantType:
+		return service.exchangeDeviceCode(ctx, request)
+	default:
+		return web.OAuthTokenResponse{}, exception.OAuthError{Code: "unsupported_grant_type", Description: "unsupported grant_type"}
+	}
+}
+
+// exchangeCode redeems an authorization code once. A code used a second
+// time ends the session the first exchange started, as RFC 6749 section
+// 4.1.2 suggests.
+func (service *OAuthServiceImpl) exchangeCode(ctx context.Context, request web.OAuthTokenRequest) (web.OAuthTokenResponse, error) {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	now := time.Now()
+
+	client, err := service.authenticateClient(ctx, tx, request)
+	if err != nil {
+		return web.OAuthTokenResponse{}, err
+	}
+
+	code, err := service.OAuthAuthorizationCodeRepository.FindByCodeHash(ctx, tx, utils.HashToken(request.Code))
+	if err != nil || code.ClientId != client.ClientId {
+		return web.OAuthTokenResponse{}, invalidGrantError("invalid authorization code")
+	}
+
+	if code.UsedAt != nil {
+		if err := revokeSession(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, code.Id, now); err != nil {
+			return web.OAuthTokenResponse{}, err
+		}
+		return web.OAuthTokenResponse{}, invalidGrantError("invalid authorization code")
+	}
+
+	if now.After(code.ExpiresAt) {
+		return web.OAuthTokenResponse{}, invalidGrantError("authorization code expired")
+	}
+
+	if request.RedirectUri != code.RedirectUri {
+		return web.OAuthTokenResponse{}, invalidGrantError("redirect_uri does not match the authorization request")
+	}
+
+	if !utils.VerifyPKCE(request.CodeVerifier, code.CodeChallenge) {
+		return web.OAuthTokenResponse{}, invalidGrantError("code_verifier does not match the code_challenge")
+	}
+
+	used, err := service.OAuthAuthorizationCodeRepository.MarkUsed(ctx, tx, code.Id, now)
+	if err != nil {
+		return web.OAuthTokenResponse{}, err
+	}
+	if !used {
+		return web.OAuthTokenResponse{}, invalidGrantError("invalid authorization code")
+	}
+
+	user, err := service.UserRepository.FindById(ctx, tx, code.UserId.String())
+	if err != nil {
+		return web.OAuthTokenResponse{}, invalidGrantError("invalid authorization code")
+	}
+
+	return service.issueTokens(ctx, tx, user, TokenGrant{
+		SessionId: code.Id,
+		AuthTime:  code.AuthTime,
+		Amr:       strings.Fields(code.Amr),
+		ClientId:  client.ClientId,
+		Scope:     code.Scope,
+		ClientIp:  request.ClientIp,
+		UserAgent: request.UserAgent,
+		Device:    client.Name,
+	}, code.Nonce)
+}
+
+// issueTokens signs the user in like a normal login, adding an ID token
+// when the openid scope was granted.
+func (service *OAuthServiceImpl) issueTokens(ctx context.Context, tx *gorm.DB, user domain.User, grant TokenGrant, nonce string) (web.OAuthTokenResponse, error) {
+	tokens, err := service.AuthService.IssueTokens(ctx, tx, user, grant)
+	if err != nil {
+		return web.OAuthTokenResponse{}, invalidGrantError(err.Error())
+	}
+
+	response := toOAuthTokenResponse(tokens, grant.Scope)
+	if hasOIDCScope(strings.Fields(grant.Scope)) {
+		if response.IdToken, err = generateIdToken(user, grant, nonce); err != nil {
+			return web.OAuthTokenResponse{}, err
+		}
+	}
+
+	return response, nil
+}
+
+// refresh only rotates refresh tokens that were issued to the calling
+// client.
+func (service *OAuthServiceImpl) refresh(ctx context.Context, request web.OAuthTokenRequest) (web.OAuthTokenResponse, error) {
+	client, err := service.authenticateClient(ctx, service.DB, request)
+	if err != nil {
+		return web.OAuthTokenResponse{}, err
+	}
+
+	stored, err := service.RefreshTokenRepository.FindByTokenHash(ctx, service.DB, utils.HashToken(request.RefreshToken))
+	if err != nil || stored.ClientId != client.ClientId {
+		return web.OAuthTokenResponse{}, invalidGrantError("invalid refresh token")
+	}
+
+	tokens, err := service.AuthService.RefreshForClient(ctx, client.ClientId, web.AuthRefreshRequest{RefreshToken: request.RefreshToken})
+	if err != nil {
+		var oauthError exception.OAuthError
+		if errors.As(err, &oauthError) {
+			return web.OAuthTokenResponse{}, err
+		}
+		return web.OAuthTokenResponse{}, invalidGrantError(err.Error())
+	}
+
+	return toOAuthTokenResponse(tokens, stored.Scope), nil
+}
+
+// clientCredentials issues a token to a confidential client itself, for
+// calls it makes on its own behalf. There is no refresh token; the client
+// asks again when the token expires.
+func (service *OAuthServiceImpl) clientCredentials(ctx context.Context, request web.OAuthTokenRequest) (web.OAuthTokenResponse, error) {
+	client, err := service.authenticateClient(ctx, service.DB, request)
+	if err != nil {
+		return web.OAuthTokenResponse{}, err
+	}
+
+	if client.SecretHash == "" {
+		return web.OAuthTokenResponse{}, exception.OAuthError{Code: "unauthorized_client", Description: "only confidential clients can use client_credentials"}
+	}
+
+	scopes, ok := grantedScopes(client, request.Scope)
+	if !ok {
+		return web.OAuthTokenResponse{}, exception.OAuthError{Code: "invalid_scope", Description: "the requested scope is not allowed for this client"}
+	}
+
+	scope := strings.Join(scopes, " ")
+	ttl := clientTokenTTL()
+
+	accessToken, err := utils.GenerateClientJWT(client.ClientId, scope, ttl)
+	if err != nil {
+		return web.OAuthTokenResponse{}, err
+	}
+
+	return web.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// Introspect checks access tokens the same way JWTMiddleware does, so a
+// token is active here exactly when it would be accepted by this service.
+func (service *OAuthServiceImpl) Introspect(ctx context.Context, request web.OAuthIntrospectRequest) (web.OAuthIntrospectResponse, error) {
+	client, err := service.authenticateClient(ctx, service.DB, web.OAuthTokenRequest{ClientId: request.ClientId, ClientSecret: request.ClientSecret})
+	if err != nil {
+		return web.OAuthIntrospectResponse{}, err
+	}
+
+	// Public clients cannot prove who they are.
+	if client.SecretHash == "" {
+		return web.OAuthIntrospectResponse{}, invalidClientError()
+	}
+
+	if isAccessToken(request.Token) {
+		token, err := service.verifyAccessToken(ctx, request.Token)
+		if err != nil || token == nil {
+			return web.OAuthIntrospectResponse{}, err
+		}
+
+		sub := token.UserId
+		if sub == "" {
+			sub = token.ClientId
+		}
+
+		response := web.OAuthIntrospectResponse{
+			Active:    true,
+			Sub:       sub,
+			Role:      token.Role,
+			Scope:     strings.Join(token.Scopes, " "),
+			ClientId:  token.ClientId,
+			TokenType: "access_token",
+			Exp:       token.ExpiresAt.Unix(),
+		}
+		if iat, err := token.Claims.GetIssuedAt(); err == nil && iat != nil {
+			response.Iat = iat.Unix()
+		}
+
+		return response, nil
+	}
+
+	stored, err := service.RefreshTokenRepository.FindByTokenHash(ctx, service.DB, utils.HashToken(request.Token))
+	if err != nil || stored.RevokedAt != nil || stored.ReplacedById != nil || time.Now().After(stored.ExpiresAt) {
+		return web.OAuthIntrospectResponse{}, nil
+	}
+
+	user, err := service.UserRepository.FindById(ctx, service.DB, stored.UserId.String())
+	if err != nil {
+		return web.OAuthIntrospectResponse{}, nil
+	}
+
+	return web.OAuthIntrospectResponse{
+		Active:    true,
+		Sub:       user.Id.String(),
+		Role:      user.Role,
+		Scope:     stored.Scope,
+		ClientId:  stored.ClientId,
+		TokenType: "refresh_token",
+		Exp:       stored.ExpiresAt.Unix(),
+		Iat:       stored.CreatedAt.Unix(),
+	}, nil
+}
+
+// Revoke denylists an access token until it expires. Revoking a refresh
+// token ends its whole session, access tokens included, as RFC 7009
+// section 2.1 suggests.
+func (service *OAuthServiceImpl) Revoke(ctx context.Context, request web.OAuthRevokeRequest) error {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	client, err := service.authenticateClient(ctx, tx, web.OAuthTokenRequest{ClientId: request.ClientId, ClientSecret: request.ClientSecret})
+	if err != nil {
+		return err
+	}
+
+	if isAccessToken(request.Token) {
+		token, err := service.verifyAccessToken(ctx, request.Token)
+		if err != nil || token == nil {
+			return err
+		}
+
+		if token.ClientId != client.ClientId {
+			return notIssuedToClientError()
+		}
+
+		return service.RevocationStore.Revoke(ctx, token.Jti, token.ExpiresAt)
+	}
+
+	stored, err := service.RefreshTokenRepository.FindByTokenHash(ctx, tx, utils.HashToken(request.Token))
+	if err != nil {
+		return nil
+	}
+
+	if stored.ClientId != client.ClientId {
+		return notIssuedToClientError()
+	}
+
+	return revokeSession(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, stored.FamilyId, time.Now())
+}
+
+// verifyAccessToken returns nil without an error when the token is not
+// valid anymore.
+func (service *OAuthServiceImpl) verifyAccessToken(ctx context.Context, accessToken string) (*middleware.VerifiedToken, error) {
+	token, err := middleware.VerifyToken(ctx, service.JWTConfig, accessToken)
+	if err != nil {
+		var tokenError middleware.TokenError
+		if errors.As(err, &tokenError) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (service *OAuthServiceImpl) UserInfo(ctx context.Context, userId string, scopes []string) (web.OIDCUserInfoResponse, error) {
+	if !hasOIDCScope(scopes) {
+		return web.OIDCUserInfoResponse{}, exception.OAuthError{Code: "insufficient_scope", Description: "the access token was not granted the openid scope", Status: 403}
+	}
+
+	user, err := service.UserService.FindById(ctx, userId)
+	if err != nil {
+		return web.OIDCUserInfoResponse{}, exception.OAuthError{Code: "invalid_token", Description: "user not found", Status: 401}
+	}
+
+	return oidcUserClaims(user, scopes), nil
+}
+
+func (service *OAuthServiceImpl) OpenIDConfiguration() web.OpenIDConfigurationResponse {
+	issuer := oidcIssuer()
+
+	// Without an asymmetric key there are no ID tokens to advertise.
+	scopes, algorithms := []string{"profile", "email"}, []string{}
+	if key, ok := oidcSigningKey(); ok {
+		scopes, algorithms = []string{"openid", "profile", "email"}, []string{key.Algorithm}
+	}
+
+	return web.OpenIDConfigurationResponse{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		DeviceAuthorizationEndpoint:       issuer + "/oauth/device_authorization",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		UserinfoEndpoint:                  issuer + "/userinfo",
+		JwksUri:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials", deviceCodeGrantType},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "amr", "nonce", "email", "email_verified", "name"},
+	}
+}
+
+func (service *OAuthServiceImpl) DeviceAuthorization(ctx context.Context, request web.OAuthDeviceAuthorizationRequest) (web.OAuthDeviceAuthorizationResponse, error) {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	client, err := service.authenticateClient(ctx, tx, web.OAuthTokenRequest{ClientId: request.ClientId, ClientSecret: request.ClientSecret})
+	if err != nil {
+		return web.OAuthDeviceAuthorizationResponse{}, err
+	}
+
+	scopes, ok := grantedScopes(client, request.Scope)
+	if !ok {
+		return web.OAuthDeviceAuthorizationResponse{}, exception.OAuthError{Code: "invalid_scope", Description: "the requested scope is not allowed for this client"}
+	}
+	if err := oidcScopeError(scopes, ""); err != nil {
+		return web.OAuthDeviceAuthorizationResponse{}, err
+	}
+
+	deviceCode, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return web.OAuthDeviceAuthorizationResponse{}, err
+	}
+	userCode, err := utils.GenerateUserCode()
+	if err != nil {
+		return web.OAuthDeviceAuthorizationResponse{}, err
+	}
+
+	ttl := utils.GetEnvDuration("OAUTH_DEVICE_CODE_TTL", 10*time.Minute)
+	interval := int(utils.GetEnvDuration("OAUTH_DEVICE_POLL_INTERVAL", 5*time.Second).Seconds())
+
+	_, err = service.OAuthDeviceCodeRepository.Save(ctx, tx, domain.OAuthDeviceCode{
+		DeviceCodeHash: utils.HashToken(deviceCode),
+		UserCodeHash:   utils.HashToken(utils.NormalizeUserCode(userCode)),
+		ClientId:       client.ClientId,
+		Scope:          strings.Join(scopes, " "),
+		PollInterval:   interval,
+		ExpiresAt:      time.Now().Add(ttl),
+	})
+	if err != nil {
+		return web.OAuthDeviceAuthorizationResponse{}, err
+	}
+
+	verificationUri := oidcIssuer() + "/device"
+
+	return web.OAuthDeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationUri:         verificationUri,
+		VerificationUriComplete: utils.AppendQuery(verificationUri, url.Values{"user_code": {userCode}}),
+		ExpiresIn:               int64(ttl.Seconds()),
+		Interval:                interval,
+	}, nil
+}
+
+func (service *OAuthServiceImpl) ValidateUserCode(ctx context.Context, request web.OAuthDeviceVerifyRequest) (web.OAuthConsentResponse, error) {
+	code, client, err := service.pendingDeviceCode(ctx, service.DB, request)
+	if err != nil {
+		return web.OAuthConsentResponse{}, err
+	}
+
+	return web.OAuthConsentResponse{ClientId: client.ClientId, ClientName: client.Name, Scopes: strings.Fields(code.Scope)}, nil
+}
+
+// VerifyDevice needs the user to sign in for both answers, so someone who
+// only knows the code can neither approve nor deny the request.
+func (service *OAuthServiceImpl) VerifyDevice(ctx context.Context, request web.OAuthDeviceVerifyRequest) error {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	code, _, err := service.pendingDeviceCode(ctx, tx, request)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	throttleKeys := deviceSignInThrottleKeys(code.UserCodeHash)
+	if err := checkLoginThrottle(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+		return err
+	}
+
+	user, amr, err := service.AuthService.Authenticate(ctx, tx, web.AuthLoginRequest{Email: request.Email, Password: request.Password, ClientIp: request.ClientIp}, request.Code)
+	if err != nil {
+		if !errors.Is(err, ErrMfaCodeRequired) {
+			if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	if request.Decision != "allow" {
+		if _, err := service.OAuthDeviceCodeRepository.Deny(ctx, tx, code.Id, now); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	approved, err := service.OAuthDeviceCodeRepository.Approve(ctx, tx, code.Id, user.Id, strings.Join(amr, " "), now)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return ErrInvalidUserCode
+	}
+
+	return nil
+}
+
+// pendingDeviceCode finds the device request of a user code that hasn't
+// been answered yet. Failed lookups count against the client IP like
+// failed logins, since user codes are short enough to guess.
+func (service *OAuthServiceImpl) pendingDeviceCode(ctx context.Context, tx *gorm.DB, request web.OAuthDeviceVerifyRequest) (domain.OAuthDeviceCode, domain.OAuthClient, error) {
+	now := time.Now()
+	throttleKeys := userCodeThrottleKeys(request.ClientIp)
+	if err := checkLoginThrottle(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+		return domain.OAuthDeviceCode{}, domain.OAuthClient{}, err
+	}
+
+	code, err := service.OAuthDeviceCodeRepository.FindByUserCodeHash(ctx, tx, utils.HashToken(utils.NormalizeUserCode(request.UserCode)))
+	if err != nil || code.ApprovedAt != nil || code.DeniedAt != nil || now.After(code.ExpiresAt) {
+		if err := recordLoginFailure(ctx, tx, service.LoginAttemptRepository, throttleKeys, now); err != nil {
+			return domain.OAuthDeviceCode{}, domain.OAuthClient{}, err
+		}
+		return domain.OAuthDeviceCode{}, domain.OAuthClient{}, ErrInvalidUserCode
+	}
+
+	client, err := service.OAuthClientRepository.FindByClientId(ctx, tx, code.ClientId)
+	if err != nil {
+		return domain.OAuthDeviceCode{}, domain.OAuthClient{}, ErrInvalidUserCode
+	}
+
+	return code, client, nil
+}
+
+// exchangeDeviceCode answers a poll of the device. Until the user answers
+// it gets authorization_pending, or slow_down when it polls more often
+// than the interval allows, which also makes the interval 5 seconds
+// longer (RFC 8628 section 3.5).
+func (service *OAuthServiceImpl) exchangeDeviceCode(ctx context.Context, request web.OAuthTokenRequest) (web.OAuthTokenResponse, error) {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	now := time.Now()
+
+	client, err := service.authenticateClient(ctx, tx, request)
+	if err != nil {
+		return web.OAuthTokenResponse{}, err
+	}
+
+	code, err := service.OAuthDeviceCodeRepository.FindByDeviceCodeHash(ctx, tx, utils.HashToken(request.DeviceCode))
+	if err != nil || code.ClientId != client.ClientId || code.UsedAt != nil {
+		return web.OAuthTokenResponse{}, invalidGrantError("invalid device code")
+	}
+
+	if now.After(code.ExpiresAt) {
+		return web.OAuthTokenResponse{}, exception.OAuthError{Code: "expired_token", Description: "the device code has expired"}
+	}
+
+	if code.DeniedAt != nil {
+		return web.OAuthTokenResponse{}, exception.OAuthError{Code: "access_denied", Description: "the user denied the request"}
+	}
+
+	if code.ApprovedAt == nil {
+		interval := code.PollInterval
+		tooFast := code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < time.Duration(interval)*time.Second
+		if tooFast {
+			interval += 5
+		}
+
+		if err := service.OAuthDeviceCodeRepository.MarkPolled(ctx, tx, code.Id, interval, now); err != nil {
+			return web.OAuthTokenResponse{}, err
+		}
+
+		if tooFast {
+			return web.OAuthTokenResponse{}, exception.OAuthError{Code: "slow_down", Description: "polling too fast, wait longer between requests"}
+		}
+		return web.OAuthTokenResponse{}, exception.OAuthError{Code: "authorization_pending", Description: "the user has not approved the request yet"}
+	}
+
+	used, err := service.OAuthDeviceCodeRepository.MarkUsed(ctx, tx, code.Id, now)
+	if err != nil {
+		return web.OAuthTokenResponse{}, err
+	}
+	if !used {
+		return web.OAuthTokenResponse{}, invalidGrantError("invalid device code")
+	}
+
+	user, err := service.UserRepository.FindById(ctx, tx, code.UserId.String())
+	if err != nil {
+		return web.OAuthTokenResponse{}, invalidGrantError("invalid device code")
+	}
+
+	return service.issueTokens(ctx, tx, user, TokenGrant{
+		SessionId: code.Id,
+		AuthTime:  code.AuthTime,
+		Amr:       strings.Fields(code.Amr),
+		ClientId:  client.ClientId,
+		Scope:     code.Scope,
+		ClientIp:  request.ClientIp,
+		UserAgent: request.UserAgent,
+		Device:    client.Name,
+	}, "")
+}
+
+// authenticateClient finds the calling client. Confidential clients must
+// also send their secret; public clients rely on PKCE instead.
+func (service *OAuthServiceImpl) authenticateClient(ctx context.Context, tx *gorm.DB, request web.OAuthTokenRequest) (domain.OAuthClient, error) {
+	client, err := service.OAuthClientRepository.FindByClientId(ctx, tx, request.ClientId)
+	if err != nil {
+		return domain.OAuthClient{}, invalidClientError()
+	}
+
+	if client.SecretHash != "" && subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(utils.HashToken(request.ClientSecret))) != 1 {
+		return domain.OAuthClient{}, invalidClientError()
+	}
+
+	return client, nil
+}
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+func clientTokenTTL() time.Duration {
+	return utils.GetEnvDuration("OAUTH_CLIENT_TOKEN_TTL", utils.AccessTokenTTL())
+}
+
+func redirectUriRegistered(client domain.OAuthClient, redirectUri string) bool {
+	if redirectUri == "" {
+		return false
+	}
+
+	for _, registered := range strings.Split(client.RedirectUris, "\n") {
+		if registered == redirectUri {
+			return true
+		}
+	}
+
+	return false
+}
+
+// grantedScopes returns the scopes of the request, or every scope of the
+// client when none were asked for. ok is false if one isn't allowed.
+func grantedScopes(client domain.OAuthClient, scope string) ([]string, bool) {
+	allowed := strings.Fields(client.Scopes)
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return allowed, true
+	}
+
+	for _, name := range requested {
+		found := false
+		for _, candidate := range allowed {
+			if candidate == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+
+	return requested, true
+}
+
+func invalidClientError() exception.OAuthError {
+	return exception.OAuthError{Code: "invalid_client", Description: "client authentication failed", Status: 401}
+}
+
+func notIssuedToClientError() exception.OAuthError {
+	return exception.OAuthError{Code: "unauthorized_client", Description: "the token was not issued to this client"}
+}
+
+// isAccessToken tells our JWT access tokens apart from opaque refresh
+// tokens, which are base64url and have no dots.
+func isAccessToken(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func invalidGrantError(description string) exception.OAuthError {
+	return exception.OAuthError{Code: "invalid_grant", Description: description}
+}
+
+func toOAuthClientResponse(client domain.OAuthClient) web.OAuthClientResponse {
+	redirectUris := strings.Fields(client.RedirectUris)
+	if redirectUris == nil {
+		redirectUris = []string{}
+	}
+	scopes := strings.Fields(client.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return web.OAuthClientResponse{
+		ClientId:     client.ClientId,
+		Confidential: client.SecretHash != "",
+		Name:         client.Name,
+		RedirectUris: redirectUris,
+		Scopes:       scopes,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+func toOAuthTokenResponse(tokens web.AuthTokenResponse, scope string) web.OAuthTokenResponse {
+	return web.OAuthTokenResponse{
+		AccessToken:  tokens.Token,
+		TokenType:    tokens.TokenType,
+		ExpiresIn:    tokens.ExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+		Scope:        scope,
+	}
+}
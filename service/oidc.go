@@ -0,0 +1,110 @@
+package service
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/utils"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcIssuer is the "iss" of ID tokens and the base of the URLs in the
+// discovery document. It must be the public URL clients reach us at.
+func oidcIssuer() string {
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		return strings.TrimSuffix(issuer, "/")
+	}
+
+	return "http://localhost:3000"
+}
+
+// oidcSigningKey is the key ID tokens are signed with. ID tokens are only
+// issued with an asymmetric key: an HS256 one would be signed with
+// JWT_SECRET, which clients cannot verify without being able to mint
+// access tokens too.
+func oidcSigningKey() (utils.SigningKey, bool) {
+	if keySet := utils.CurrentKeySet(); keySet != nil {
+		return keySet.Active()
+	}
+
+	return utils.SigningKey{}, false
+}
+
+// oidcScopeError rejects the openid scope while no asymmetric key is
+// configured.
+func oidcScopeError(scopes []string, redirectUri string) error {
+	if _, ok := oidcSigningKey(); ok || !hasOIDCScope(scopes) {
+		return nil
+	}
+
+	return exception.OAuthError{Code: "invalid_scope", Description: "the openid scope requires an asymmetric JWT_ALGORITHM", RedirectUri: redirectUri}
+}
+
+// oidcUserClaims returns the claims of user the scopes allow: email and
+// email_verified for "email", name for "profile". sub is always set.
+func oidcUserClaims(user domain.User, scopes []string) web.OIDCUserInfoResponse {
+	claims := web.OIDCUserInfoResponse{Sub: user.Id.String()}
+
+	for _, scope := range scopes {
+		switch scope {
+		case "email":
+			verified := user.IsVerified
+			claims.Email = user.Email
+			claims.EmailVerified = &verified
+		case "profile":
+			claims.Name = user.FullName
+		}
+	}
+
+	return claims
+}
+
+// generateIdToken signs the ID token of the grant. Its audience is the
+// client, so it is never accepted as an access token.
+func generateIdToken(user domain.User, grant TokenGrant, nonce string) (string, error) {
+	key, ok := oidcSigningKey()
+	if !ok {
+		return "", errors.New("id tokens require an asymmetric signing key")
+	}
+
+	now := time.Now()
+	userClaims := oidcUserClaims(user, strings.Fields(grant.Scope))
+
+	claims := jwt.MapClaims{
+		"iss":       oidcIssuer(),
+		"sub":       userClaims.Sub,
+		"aud":       grant.ClientId,
+		"exp":       now.Add(utils.AccessTokenTTL()).Unix(),
+		"iat":       now.Unix(),
+		"auth_time": grant.AuthTime.Unix(),
+	}
+	if len(grant.Amr) > 0 {
+		claims["amr"] = grant.Amr
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if userClaims.Email != "" {
+		claims["email"] = userClaims.Email
+		claims["email_verified"] = *userClaims.EmailVerified
+	}
+	if userClaims.Name != "" {
+		claims["name"] = userClaims.Name
+	}
+
+	return utils.SignClaimsWithKey(key, claims)
+}
+
+func hasOIDCScope(scopes []string) bool {
+	for _, scope := range scopes {
+		if scope == "openid" {
+			return true
+		}
+	}
+	return false
+}
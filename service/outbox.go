@@ -0,0 +1,32 @@
+package service
+
+import (
+	"auth-api-jwt/mailer"
+	"context"
+	"log"
+)
+
+// outbox holds the emails a transaction produces until it has committed,
+// so no email links to a token that was rolled back or that other
+// connections cannot see yet.
+type outbox struct {
+	mailer   mailer.Mailer
+	messages []mailer.Message
+}
+
+func newOutbox(mailer mailer.Mailer) *outbox {
+	return &outbox{mailer: mailer}
+}
+
+func (box *outbox) add(message mailer.Message) {
+	box.messages = append(box.messages, message)
+}
+
+// flush sends the queued emails. Pass it to helper.CommitOrRollbackThen.
+func (box *outbox) flush(ctx context.Context) {
+	for _, message := range box.messages {
+		if err := box.mailer.Send(ctx, message); err != nil {
+			log.Printf("Send %q email fail: %v\n", message.Subject, err)
+		}
+	}
+}
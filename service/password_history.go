@@ -0,0 +1,91 @@
+package service
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// passwordHistorySize is how many of the user's latest passwords, the
+// current one included, can't be chosen again. Zero turns the check off.
+func passwordHistorySize() int {
+	return utils.GetEnvInt("PASSWORD_HISTORY_SIZE", 5)
+}
+
+// checkPasswordReuse rejects password when it matches the user's current
+// password or one of the hashes kept in the history.
+func checkPasswordReuse(ctx context.Context, tx *gorm.DB, historyRepository repository.PasswordHistoryRepository, user domain.User, field string, password string) error {
+	size := passwordHistorySize()
+	if size <= 0 {
+		return nil
+	}
+
+	hashes := []string{user.PasswordHash}
+	if size > 1 {
+		entries, err := historyRepository.FindRecentByUser(ctx, tx, user.Id, size-1)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			hashes = append(hashes, entry.PasswordHash)
+		}
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && utils.CheckPassword(password, hash) {
+			return exception.ValidationError{Errors: []exception.FieldError{{
+				Field:   field,
+				Code:    "reused",
+				Message: fmt.Sprintf("must not be one of your last %d passwords", size),
+			}}}
+		}
+	}
+
+	return nil
+}
+
+// rememberPassword moves the user's current hash into the history before
+// it is replaced, dropping entries that no longer count.
+func rememberPassword(ctx context.Context, tx *gorm.DB, historyRepository repository.PasswordHistoryRepository, user domain.User) error {
+	keep := passwordHistorySize() - 1
+	if keep <= 0 || user.PasswordHash == "" {
+		return nil
+	}
+
+	if _, err := historyRepository.Save(ctx, tx, domain.PasswordHistory{
+		UserId:       user.Id,
+		PasswordHash: user.PasswordHash,
+	}); err != nil {
+		return err
+	}
+
+	return historyRepository.PruneByUser(ctx, tx, user.Id, keep)
+}
+
+// passwordChangeRequired reports whether the user has to pick a new
+// password before getting a full session: an admin asked for it, or the
+// password is older than PASSWORD_MAX_AGE. Accounts without a password,
+// like passkey-only ones, never expire.
+func passwordChangeRequired(user domain.User, now time.Time) bool {
+	if user.MustChangePassword {
+		return true
+	}
+
+	maxAge := utils.GetEnvDuration("PASSWORD_MAX_AGE", 0)
+	if maxAge <= 0 || user.PasswordHash == "" {
+		return false
+	}
+
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+
+	return now.Sub(changedAt) > maxAge
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/utils"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// bcryptMaxPasswordBytes is the longest password bcrypt hashes in full.
+const bcryptMaxPasswordBytes = 72
+
+// checkPasswordPolicy applies the password rules shared by every flow that
+// sets a password. field names the request field the password came from,
+// and email and fullName belong to the account it is for. All violations
+// are reported at once.
+func checkPasswordPolicy(field string, password string, email string, fullName string) error {
+	errs := []exception.FieldError{}
+	violation := func(code string, message string) {
+		errs = append(errs, exception.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	minLength := utils.GetEnvInt("PASSWORD_MIN_LENGTH", 8)
+	maxLength := utils.GetEnvInt("PASSWORD_MAX_LENGTH", 72)
+
+	if length < minLength {
+		violation("too_short", fmt.Sprintf("must be at least %d characters", minLength))
+	}
+	if length > maxLength {
+		violation("too_long", fmt.Sprintf("must be at most %d characters", maxLength))
+		return exception.ValidationError{Errors: errs}
+	}
+
+	// bcrypt only reads the first 72 bytes, and characters outside ASCII
+	// take several bytes each, so the limit is counted in bytes for it
+	if utils.PreferredPasswordHasher().Name() == "bcrypt" {
+		if maxBytes := min(maxLength, bcryptMaxPasswordBytes); len(password) > maxBytes {
+			violation("too_long", fmt.Sprintf("must be at most %d bytes", maxBytes))
+			return exception.ValidationError{Errors: errs}
+		}
+	}
+
+	if containsPersonalInfo(password, email, fullName) {
+		violation("contains_personal_info", "must not contain your email or name")
+	}
+
+	userInputs := []string{email, fullName}
+	if strength := utils.PasswordStrength(password, userInputs...); strength < utils.GetEnvInt("PASSWORD_MIN_STRENGTH", 2) {
+		violation("too_weak", "is too easy to guess, add more words or characters")
+	}
+
+	if utils.IsBreachedPassword(password) {
+		violation("breached", "appears in a known data breach, choose another one")
+	}
+
+	if len(errs) > 0 {
+		return exception.ValidationError{Errors: errs}
+	}
+
+	return nil
+}
+
+// containsPersonalInfo reports whether password contains the local part of
+// email or any part of fullName of at least three characters.
+func containsPersonalInfo(password string, email string, fullName string) bool {
+	password = strings.ToLower(password)
+
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	parts := append([]string{localPart}, strings.Fields(strings.ToLower(fullName))...)
+
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= 3 && strings.Contains(password, part) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// sessionClient describes the device a login comes from.
+type sessionClient struct {
+	Ip        string
+	UserAgent string
+	Device    string
+	// RememberMe and DeviceId come from the login request.
+	RememberMe bool
+	DeviceId   string
+}
+
+// newSession prepares the session a sign-in from client starts. remember_me
+// is ignored for roles without a remembered session lifetime.
+func (client sessionClient) newSession(user domain.User) domain.Session {
+	session := client.describe(domain.Session{Id: uuid.New(), UserId: user.Id})
+
+	if client.RememberMe && rememberMeTTL(user.Role) > 0 {
+		session.RememberMe = true
+		session.DeviceId = client.DeviceId
+		if session.DeviceId == "" {
+			session.DeviceId = uuid.NewString()
+		}
+	}
+
+	return session
+}
+
+// describe fills in where session was started from.
+func (client sessionClient) describe(session domain.Session) domain.Session {
+	session.Device = client.Device
+	if session.Device == "" {
+		session.Device = utils.DeviceName(client.UserAgent)
+	}
+
+	session.UserAgent = client.UserAgent
+	if len(session.UserAgent) > 255 {
+		session.UserAgent = session.UserAgent[:255]
+	}
+
+	session.IpAddress = client.Ip
+	return session
+}
+
+// revokeSession ends one session: its refresh tokens can no longer be
+// rotated and its sid is denylisted, so JWTMiddleware rejects the access
+// tokens already handed out to it.
+func revokeSession(ctx context.Context, tx *gorm.DB, sessionRepository repository.SessionRepository, refreshTokenRepository repository.RefreshTokenRepository, revocationStore repository.RevocationStore, sessionId uuid.UUID, now time.Time) error {
+	if _, err := sessionRepository.Revoke(ctx, tx, sessionId, now); err != nil {
+		return err
+	}
+
+	if err := refreshTokenRepository.RevokeFamily(ctx, tx, sessionId, now); err != nil {
+		return err
+	}
+
+	return revocationStore.Revoke(ctx, sessionId.String(), now.Add(utils.AccessTokenTTL()))
+}
+
+// revokeAllSessions signs the user out everywhere: refresh tokens can no
+// longer be rotated and access tokens issued until now are rejected. The
+// user's token version is bumped, so tokens issued to user afterwards carry
+// the new one.
+func revokeAllSessions(ctx context.Context, tx *gorm.DB, sessionRepository repository.SessionRepository, refreshTokenRepository repository.RefreshTokenRepository, revocationStore repository.RevocationStore, tokenVersionStore repository.TokenVersionStore, user *domain.User, now time.Time) error {
+	if err := refreshTokenRepository.RevokeByUser(ctx, tx, user.Id, now); err != nil {
+		return err
+	}
+
+	if err := sessionRepository.RevokeByUser(ctx, tx, user.Id, now); err != nil {
+		return err
+	}
+
+	return revokeAccessTokens(ctx, tx, revocationStore, tokenVersionStore, user, now)
+}
+
+// revokeAccessTokens rejects every access token issued to user before now.
+// The new token version only reaches other instances once their cached
+// version expires, the revocation store covers the gap.
+func revokeAccessTokens(ctx context.Context, tx *gorm.DB, revocationStore repository.RevocationStore, tokenVersionStore repository.TokenVersionStore, user *domain.User, now time.Time) error {
+	version, err := tokenVersionStore.Bump(ctx, tx, user.Id.String())
+	if err != nil {
+		return err
+	}
+	user.TokenVersion = version
+
+	return revocationStore.RevokeUser(ctx, user.Id.String(), now, now.Add(utils.AccessTokenTTL()))
+}
+
+// sessionIdleTimeout ends sessions that were not used for this long, even
+// if their tokens are still valid. Zero turns it off.
+func sessionIdleTimeout() time.Duration {
+	return utils.GetEnvDuration("SESSION_IDLE_TIMEOUT", 0)
+}
+
+// sessionIdle never ends remembered sessions, they are only bounded by
+// their lifetime.
+func sessionIdle(session domain.Session, now time.Time) bool {
+	idleTimeout := sessionIdleTimeout()
+	return idleTimeout > 0 && !session.RememberMe && now.Sub(session.LastSeenAt) > idleTimeout
+}
+
+// rememberMeTTL is the refresh token lifetime of remembered sessions for
+// role. REMEMBER_ME_TTL_<ROLE> overrides REMEMBER_ME_TTL, and zero turns
+// remember me off for that role.
+func rememberMeTTL(role string) time.Duration {
+	ttl := utils.GetEnvDuration("REMEMBER_ME_TTL", 30*24*time.Hour)
+	return utils.GetEnvDuration("REMEMBER_ME_TTL_"+strings.ToUpper(role), ttl)
+}
+
+// refreshTokenTTL is how long refresh tokens of session stay valid. It is
+// read again on every refresh, so a role that loses remember me falls back
+// to the normal lifetime.
+func refreshTokenTTL(session domain.Session, role string) time.Duration {
+	if session.RememberMe {
+		if ttl := rememberMeTTL(role); ttl > 0 {
+			return ttl
+		}
+	}
+	return utils.RefreshTokenTTL()
+}
+
+// forgetDevice ends the remembered sessions of deviceId, so a device keeps
+// at most one of them.
+func forgetDevice(ctx context.Context, tx *gorm.DB, sessionRepository repository.SessionRepository, refreshTokenRepository repository.RefreshTokenRepository, revocationStore repository.RevocationStore, userId uuid.UUID, deviceId string, now time.Time) error {
+	sessions, err := sessionRepository.FindActiveByUser(ctx, tx, userId, now)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if !session.RememberMe || session.DeviceId != deviceId {
+			continue
+		}
+		if err := revokeSession(ctx, tx, sessionRepository, refreshTokenRepository, revocationStore, session.Id, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enforceSessionLimit makes room for a new session of the user when
+// SESSION_MAX_CONCURRENT is set. Depending on SESSION_LIMIT_POLICY the
+// oldest sessions are signed out ("evict_oldest", the default) or the new
+// sign-in is refused ("reject"). Idle sessions are ended first and don't
+// count.
+func enforceSessionLimit(ctx context.Context, tx *gorm.DB, sessionRepository repository.SessionRepository, refreshTokenRepository repository.RefreshTokenRepository, revocationStore repository.RevocationStore, userId uuid.UUID, now time.Time) error {
+	maxSessions := utils.GetEnvInt("SESSION_MAX_CONCURRENT", 0)
+	if maxSessions <= 0 {
+		return nil
+	}
+
+	sessions, err := sessionRepository.FindActiveByUser(ctx, tx, userId, now)
+	if err != nil {
+		return err
+	}
+
+	active := make([]domain.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if sessionIdle(session, now) {
+			if err := revokeSession(ctx, tx, sessionRepository, refreshTokenRepository, revocationStore, session.Id, now); err != nil {
+				return err
+			}
+			continue
+		}
+		active = append(active, session)
+	}
+
+	if len(active) < maxSessions {
+		return nil
+	}
+
+	if os.Getenv("SESSION_LIMIT_POLICY") == "reject" {
+		return errors.New("too many active sessions, sign out of another device first")
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].CreatedAt.Before(active[j].CreatedAt)
+	})
+	for _, session := range active[:len(active)-maxSessions+1] {
+		if err := revokeSession(ctx, tx, sessionRepository, refreshTokenRepository, revocationStore, session.Id, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package service
+
+import (
+	"auth-api-jwt/models/web"
+	"context"
+)
+
+type SessionService interface {
+	// FindByUser lists the user's active sessions, flagging the one with
+	// currentSessionId.
+	FindByUser(ctx context.Context, userId string, currentSessionId string) ([]web.SessionResponse, error)
+	Revoke(ctx context.Context, userId string, sessionId string) error
+	// RevokeOthers signs the user out of every session except
+	// currentSessionId, or out of all of them when it is empty.
+	RevokeOthers(ctx context.Context, userId string, currentSessionId string) error
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionServiceImpl struct {
+	SessionRepository      repository.SessionRepository
+	RefreshTokenRepository repository.RefreshTokenRepository
+	RevocationStore        repository.RevocationStore
+	TokenVersionStore      repository.TokenVersionStore
+	DB                     *gorm.DB
+}
+
+func NewSessionService(sessionRepository repository.SessionRepository, refreshTokenRepository repository.RefreshTokenRepository, revocationStore repository.RevocationStore, tokenVersionStore repository.TokenVersionStore, DB *gorm.DB) SessionService {
+	return &SessionServiceImpl{
+		SessionRepository:      sessionRepository,
+		RefreshTokenRepository: refreshTokenRepository,
+		RevocationStore:        revocationStore,
+		TokenVersionStore:      tokenVersionStore,
+		DB:                     DB,
+	}
+}
+
+func (service *SessionServiceImpl) FindByUser(ctx context.Context, userId string, currentSessionId string) ([]web.SessionResponse, error) {
+	id, err := uuid.Parse(userId)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	now := time.Now()
+
+	sessions, err := service.SessionRepository.FindActiveByUser(ctx, tx, id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]web.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		if sessionIdle(session, now) {
+			continue
+		}
+		responses = append(responses, web.SessionResponse{
+			Id:         session.Id,
+			Device:     session.Device,
+			UserAgent:  session.UserAgent,
+			IpAddress:  session.IpAddress,
+			RememberMe: session.RememberMe,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.Id.String() == currentSessionId,
+		})
+	}
+
+	return responses, nil
+}
+
+// Revoke answers "session not found" for sessions of other users, so ids
+// cannot be probed.
+func (service *SessionServiceImpl) Revoke(ctx context.Context, userId string, sessionId string) error {
+	id, err := uuid.Parse(sessionId)
+	if err != nil {
+		return errors.New("session not found")
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	now := time.Now()
+
+	session, err := service.SessionRepository.FindById(ctx, tx, id)
+	if err != nil || session.UserId.String() != userId || session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+		return errors.New("session not found")
+	}
+
+	return revokeSession(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, session.Id, now)
+}
+
+func (service *SessionServiceImpl) RevokeOthers(ctx context.Context, userId string, currentSessionId string) error {
+	id, err := uuid.Parse(userId)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	now := time.Now()
+
+	sessions, err := service.SessionRepository.FindActiveByUser(ctx, tx, id, now)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.Id.String() == currentSessionId {
+			continue
+		}
+		if err := revokeSession(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, session.Id, now); err != nil {
+			return err
+		}
+	}
+
+	// Signing out of everything also catches tokens that belong to no
+	// session, like restricted ones.
+	if currentSessionId == "" {
+		if _, err := service.TokenVersionStore.Bump(ctx, tx, userId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"auth-api-jwt/utils"
+	"context"
+	"time"
+)
+
+type SigningKeyService interface {
+	Initialize(ctx context.Context) error
+	Reload(ctx context.Context) error
+	Rotate(ctx context.Context) error
+	StartRotation(ctx context.Context, interval time.Duration)
+	JWKS() utils.JWKS
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// keyRefreshInterval is how often keys are reloaded from the database, so
+// that every instance picks up a rotation performed by another one.
+const keyRefreshInterval = time.Minute
+
+// keyActivationDelay is how long a rotated key is only published before it
+// starts signing. It spans two reloads, so every instance accepts the new
+// key by the time any of them signs with it.
+const keyActivationDelay = 2 * keyRefreshInterval
+
+type SigningKeyServiceImpl struct {
+	SigningKeyRepository repository.SigningKeyRepository
+	DB                   *gorm.DB
+	Algorithm            string
+	ActivationDelay      time.Duration
+	KeySet               *utils.KeySet
+}
+
+func NewSigningKeyService(signingKeyRepository repository.SigningKeyRepository, DB *gorm.DB, algorithm string) SigningKeyService {
+	return &SigningKeyServiceImpl{
+		SigningKeyRepository: signingKeyRepository,
+		DB:                   DB,
+		Algorithm:            algorithm,
+		ActivationDelay:      keyActivationDelay,
+		KeySet:               utils.NewKeySet(nil),
+	}
+}
+
+// Initialize loads the published keys, makes sure a key for the configured
+// algorithm exists and switches token signing over to the key set. The
+// first key signs right away; a key for a changed algorithm is rotated in
+// like any other.
+func (service *SigningKeyServiceImpl) Initialize(ctx context.Context) error {
+	if err := service.Reload(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := service.KeySet.Active(); !ok {
+		if err := service.rotate(ctx, 0); err != nil {
+			return err
+		}
+	} else if latest, _ := service.KeySet.Latest(); latest.Algorithm != service.Algorithm {
+		if err := service.Rotate(ctx); err != nil {
+			return err
+		}
+	}
+
+	utils.UseKeySet(service.KeySet)
+	return nil
+}
+
+func (service *SigningKeyServiceImpl) Reload(ctx context.Context) error {
+	stored, err := service.SigningKeyRepository.FindPublished(ctx, service.DB, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var keys []utils.SigningKey
+	for _, key := range stored {
+		privateKey, err := utils.DecodePrivateKey(key.PrivateKeyPem)
+		if err != nil {
+			log.Println("Skipping unreadable signing key", key.Kid, ":", err)
+			continue
+		}
+
+		keys = append(keys, utils.SigningKey{
+			Kid:         key.Kid,
+			Algorithm:   key.Algorithm,
+			PrivateKey:  privateKey,
+			CreatedAt:   key.CreatedAt,
+			ActivatesAt: key.ActivatesAt,
+			RetiredAt:   key.RetiredAt,
+		})
+	}
+
+	service.KeySet.Replace(keys)
+	return nil
+}
+
+// Rotate publishes a new signing key that replaces the current one after
+// ActivationDelay, once every instance has loaded it. The retired key stays
+// published until every token it signed has expired.
+func (service *SigningKeyServiceImpl) Rotate(ctx context.Context) error {
+	return service.rotate(ctx, service.ActivationDelay)
+}
+
+func (service *SigningKeyServiceImpl) rotate(ctx context.Context, activationDelay time.Duration) error {
+	key, err := utils.GenerateSigningKey(service.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := utils.EncodePrivateKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	err = func() error {
+		tx := service.DB.Begin()
+		defer helper.CommitOrRollback(tx)
+
+		activatesAt := time.Now().Add(activationDelay)
+		publishUntil := activatesAt.Add(longestSignedTokenTTL() + time.Minute)
+
+		if err := service.SigningKeyRepository.RetireActive(ctx, tx, activatesAt, publishUntil); err != nil {
+			return err
+		}
+
+		_, err := service.SigningKeyRepository.Save(ctx, tx, domain.SigningKey{
+			Kid:           key.Kid,
+			Algorithm:     key.Algorithm,
+			PrivateKeyPem: encoded,
+			CreatedAt:     key.CreatedAt,
+			ActivatesAt:   activatesAt,
+		})
+		return err
+	}()
+	if err != nil {
+		return err
+	}
+
+	return service.Reload(ctx)
+}
+
+func (service *SigningKeyServiceImpl) StartRotation(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(keyRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				service.rotateIfDue(ctx, interval)
+			}
+		}
+	}()
+}
+
+func (service *SigningKeyServiceImpl) rotateIfDue(ctx context.Context, interval time.Duration) {
+	if err := service.Reload(ctx); err != nil {
+		log.Println("Reload signing keys fail:", err)
+		return
+	}
+
+	latest, ok := service.KeySet.Latest()
+	if !ok || time.Since(latest.CreatedAt) >= interval {
+		if err := service.Rotate(ctx); err != nil {
+			log.Println("Rotate signing key fail:", err)
+		}
+	}
+
+	if err := service.SigningKeyRepository.DeleteExpired(ctx, service.DB, time.Now()); err != nil {
+		log.Println("Delete expired signing keys fail:", err)
+	}
+}
+
+func (service *SigningKeyServiceImpl) JWKS() utils.JWKS {
+	return service.KeySet.JWKS()
+}
+
+// longestSignedTokenTTL is the lifetime of the longest-lived token the
+// signing key signs: access and ID tokens, or client_credentials tokens.
+func longestSignedTokenTTL() time.Duration {
+	ttl := utils.AccessTokenTTL()
+	if clientTTL := clientTokenTTL(); clientTTL > ttl {
+		ttl = clientTTL
+	}
+
+	return ttl
+}
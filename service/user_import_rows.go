@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// importRow is one line of an import file. Err is set when the line could
+// not be parsed, which only fails that row.
+type importRow struct {
+	Line   int
+	Fields map[string]string
+	Err    error
+}
+
+// field returns the first non-empty value among names. Exports use their
+// own key names, like passwordHash in Auth0 and Firebase.
+func (row importRow) field(names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(row.Fields[name]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func importFormat(format string, filename string) (string, error) {
+	if format != "" {
+		return format, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return "csv", nil
+	case ".ndjson", ".jsonl", ".json":
+		return "ndjson", nil
+	}
+
+	return "", errors.New("format is required")
+}
+
+func readImportRows(format string, data io.Reader) ([]importRow, error) {
+	if format == "csv" {
+		return readCSVRows(data)
+	}
+	return readNDJSONRows(data)
+}
+
+func readNDJSONRows(data io.Reader) ([]importRow, error) {
+	rows := []importRow{}
+
+	scanner := bufio.NewScanner(data)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		values := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(text), &values); err != nil {
+			rows = append(rows, importRow{Line: line, Err: errors.New("invalid json")})
+			continue
+		}
+
+		fields := map[string]string{}
+		for key, value := range values {
+			fields[key] = importValueString(value)
+		}
+		rows = append(rows, importRow{Line: line, Fields: fields})
+	}
+
+	return rows, scanner.Err()
+}
+
+func importValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// readCSVRows expects a header line naming the columns. A malformed quote
+// aborts the whole file because the following lines can no longer be
+// trusted.
+func readCSVRows(data io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []importRow{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	rows := []importRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			rows = append(rows, importRow{Line: line, Err: fmt.Errorf("expected %d columns, got %d", len(header), len(record))})
+			continue
+		}
+
+		fields := map[string]string{}
+		for i, name := range header {
+			fields[name] = record[i]
+		}
+		rows = append(rows, importRow{Line: line, Fields: fields})
+	}
+}
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"auth-api-jwt/models/web"
+	"context"
+)
+
+type UserImportService interface {
+	// Import creates one user per row and reports the rows it rejected.
+	// Each row is saved on its own, so a bad row never blocks the others.
+	Import(ctx context.Context, request web.UserImportRequest) (web.UserImportResponse, error)
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserImportServiceImpl struct {
+	AuthRepository repository.AuthRepository
+	DB             *gorm.DB
+	Validate       *validator.Validate
+}
+
+func NewUserImportService(authRepository repository.AuthRepository, DB *gorm.DB, validate *validator.Validate) UserImportService {
+	return &UserImportServiceImpl{
+		AuthRepository: authRepository,
+		DB:             DB,
+		Validate:       validate,
+	}
+}
+
+func (service *UserImportServiceImpl) Import(ctx context.Context, request web.UserImportRequest) (web.UserImportResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.UserImportResponse{}, err
+	}
+
+	format, err := importFormat(request.Format, request.Filename)
+	if err != nil {
+		return web.UserImportResponse{}, err
+	}
+
+	rows, err := readImportRows(format, request.Data)
+	if err != nil {
+		return web.UserImportResponse{}, err
+	}
+
+	response := web.UserImportResponse{
+		DryRun: request.DryRun,
+		Errors: []web.UserImportRowError{},
+	}
+	seen := map[string]bool{}
+
+	for _, row := range rows {
+		response.Total++
+
+		err := row.Err
+		if err == nil {
+			err = service.importRow(ctx, request, row, seen)
+		}
+
+		if err != nil {
+			response.Failed++
+			response.Errors = append(response.Errors, web.UserImportRowError{
+				Row:   row.Line,
+				Email: row.field("email"),
+				Error: err.Error(),
+			})
+			continue
+		}
+
+		response.Imported++
+	}
+
+	return response, nil
+}
+
+func (service *UserImportServiceImpl) importRow(ctx context.Context, request web.UserImportRequest, row importRow, seen map[string]bool) error {
+	user, err := service.importedUser(request, row)
+	if err != nil {
+		return err
+	}
+
+	key := strings.ToLower(user.Email)
+	if seen[key] {
+		return errors.New("duplicate email in import")
+	}
+	seen[key] = true
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	_, err = service.AuthRepository.FindByEmail(ctx, tx, user.Email)
+	if err == nil {
+		return errors.New("email already registered")
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if request.DryRun {
+		return nil
+	}
+
+	_, err = service.AuthRepository.Create(ctx, tx, user)
+	return err
+}
+
+func (service *UserImportServiceImpl) importedUser(request web.UserImportRequest, row importRow) (domain.User, error) {
+	imported := web.UserImportRow{
+		Email:         row.field("email"),
+		FullName:      row.field("full_name", "name", "displayName"),
+		Role:          row.field("role"),
+		PasswordHash:  row.field("password_hash", "passwordHash"),
+		PasswordSalt:  row.field("password_salt", "salt"),
+		HashAlgorithm: strings.ToLower(row.field("hash_algorithm")),
+	}
+
+	if imported.Role == "" {
+		imported.Role = "user"
+	}
+	if imported.FullName == "" {
+		imported.FullName, _, _ = strings.Cut(imported.Email, "@")
+	}
+
+	if verified := row.field("email_verified", "emailVerified"); verified != "" {
+		parsed, err := strconv.ParseBool(verified)
+		if err != nil {
+			return domain.User{}, errors.New("invalid email_verified value")
+		}
+		imported.EmailVerified = parsed
+	}
+
+	if err := service.Validate.Struct(imported); err != nil {
+		return domain.User{}, err
+	}
+
+	passwordHash, err := importedPasswordHash(request, imported)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	return domain.User{
+		Id:           uuid.New(),
+		Email:        imported.Email,
+		PasswordHash: passwordHash,
+		FullName:     imported.FullName,
+		Role:         imported.Role,
+		IsVerified:   imported.EmailVerified,
+	}, nil
+}
+
+// importedPasswordHash turns the exported hash into a format CheckPassword
+// understands. Rows without a hash become accounts that can only sign in
+// after a password reset.
+func importedPasswordHash(request web.UserImportRequest, row web.UserImportRow) (string, error) {
+	if row.PasswordHash == "" {
+		return "", nil
+	}
+
+	algorithm := row.HashAlgorithm
+	if algorithm == "" && row.PasswordSalt != "" && request.FirebaseSignerKey != "" {
+		algorithm = "firebase-scrypt"
+	}
+
+	passwordHash := row.PasswordHash
+	switch algorithm {
+	case "firebase-scrypt", "firebase":
+		if request.FirebaseSignerKey == "" {
+			return "", errors.New("firebase hash parameters are required")
+		}
+		encoded, err := utils.EncodeFirebaseScryptHash(utils.FirebaseScryptConfig{
+			SignerKey:     request.FirebaseSignerKey,
+			SaltSeparator: request.FirebaseSaltSeparator,
+			Rounds:        request.FirebaseRounds,
+			MemCost:       request.FirebaseMemCost,
+		}, row.PasswordSalt, row.PasswordHash)
+		if err != nil {
+			return "", err
+		}
+		algorithm, passwordHash = "firebase-scrypt", encoded
+	case "auth0":
+		algorithm = "bcrypt"
+	case "django":
+		algorithm = "pbkdf2"
+	case "", "bcrypt", "argon2id", "scrypt", "pbkdf2":
+	default:
+		return "", errors.New("unknown hash_algorithm " + algorithm)
+	}
+
+	// the hash must parse with bounded parameters, so a crafted row cannot
+	// make every later login compute an arbitrarily expensive hash
+	detected, ok := utils.PasswordHashAlgorithm(passwordHash)
+	if !ok {
+		return "", errors.New("unsupported password hash format")
+	}
+	if algorithm != "" && detected != algorithm {
+		return "", errors.New("password hash is not a " + algorithm + " hash")
+	}
+
+	return passwordHash, nil
+}
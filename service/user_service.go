@@ -14,4 +14,5 @@ type UserService interface {
 	FindById(ctx context.Context, targetUserId string) (domain.User, error)
 	Me(ctx context.Context, targetUserId string) (domain.User, error)
 	FindAll(ctx context.Context) ([]domain.User, error)
+	Unlock(ctx context.Context, targetUserId string) error
 }
@@ -2,28 +2,49 @@ package service
 
 import (
 	"auth-api-jwt/helper"
+	"auth-api-jwt/mailer"
 	"auth-api-jwt/models/domain"
 	"auth-api-jwt/models/web"
 	"auth-api-jwt/repository"
 	"auth-api-jwt/utils"
 
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserServiceImpl struct {
-	UserRepository repository.UserRepository
-	DB             *gorm.DB
-	Validate       *validator.Validate
+	UserRepository            repository.UserRepository
+	LoginAttemptRepository    repository.LoginAttemptRepository
+	PasswordHistoryRepository repository.PasswordHistoryRepository
+	RefreshTokenRepository    repository.RefreshTokenRepository
+	SessionRepository         repository.SessionRepository
+	UserTokenRepository       repository.UserTokenRepository
+	RevocationStore           repository.RevocationStore
+	TokenVersionStore         repository.TokenVersionStore
+	Mailer                    mailer.Mailer
+	DB                        *gorm.DB
+	Validate                  *validator.Validate
 }
 
-func NewUserService(userRepository repository.UserRepository, DB *gorm.DB, validate *validator.Validate) UserService {
+func NewUserService(userRepository repository.UserRepository, loginAttemptRepository repository.LoginAttemptRepository, passwordHistoryRepository repository.PasswordHistoryRepository, refreshTokenRepository repository.RefreshTokenRepository, sessionRepository repository.SessionRepository, userTokenRepository repository.UserTokenRepository, revocationStore repository.RevocationStore, tokenVersionStore repository.TokenVersionStore, mailer mailer.Mailer, DB *gorm.DB, validate *validator.Validate) UserService {
 	return &UserServiceImpl{
-		UserRepository: userRepository,
-		DB:             DB,
-		Validate:       validate,
+		UserRepository:            userRepository,
+		LoginAttemptRepository:    loginAttemptRepository,
+		PasswordHistoryRepository: passwordHistoryRepository,
+		RefreshTokenRepository:    refreshTokenRepository,
+		SessionRepository:         sessionRepository,
+		UserTokenRepository:       userTokenRepository,
+		RevocationStore:           revocationStore,
+		TokenVersionStore:         tokenVersionStore,
+		Mailer:                    mailer,
+		DB:                        DB,
+		Validate:                  validate,
 	}
 }
 
@@ -31,14 +52,27 @@ func (service *UserServiceImpl) Create(ctx context.Context, request web.UserCrea
 	err := service.Validate.Struct(request)
 	helper.PanicIfError(err)
 
+	if err := checkPasswordPolicy("password", request.Password, request.Email, request.FullName); err != nil {
+		return domain.User{}, err
+	}
+
+	hashed, err := utils.HashPassword(request.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
+
 	tx := service.DB.Begin()
 	defer helper.CommitOrRollback(tx)
 
+	now := time.Now()
 	user := domain.User{
-		Email:        request.Email,
-		PasswordHash: request.Password,
-		FullName:     request.FullName,
-		Role:         request.Role,
+		Id:                 uuid.New(),
+		Email:              request.Email,
+		PasswordHash:       hashed,
+		FullName:           request.FullName,
+		Role:               request.Role,
+		MustChangePassword: request.MustChangePassword,
+		PasswordChangedAt:  &now,
 	}
 
 	if user.Role == "" {
@@ -58,21 +92,41 @@ func (service *UserServiceImpl) Update(ctx context.Context, request web.UserUpda
 		return domain.User{}, err
 	}
 
+	mails := newOutbox(service.Mailer)
 	tx := service.DB.Begin()
-	defer helper.CommitOrRollback(tx)
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
 
 	user, err := service.UserRepository.FindById(ctx, tx, request.Id.String())
 	if err != nil {
 		return domain.User{}, err
 	}
 
+	// A new role must not leave the old tokens working until they expire.
+	// A new password signs the user out everywhere, like a password reset.
+	roleChanged := user.Role != request.Role
+
 	user.FullName = request.FullName
-	user.Email = request.Email
 	user.Role = request.Role
+	if err := service.changeEmail(ctx, tx, mails, &user, request.Email); err != nil {
+		return domain.User{}, err
+	}
 
 	if request.PasswordHash != "" {
-		hashed, _ := utils.HashPassword(request.PasswordHash)
-		user.PasswordHash = hashed
+		if err := service.setPassword(ctx, tx, &user, "PasswordHash", request.PasswordHash); err != nil {
+			return domain.User{}, err
+		}
+
+		if err := revokeAllSessions(ctx, tx, service.SessionRepository, service.RefreshTokenRepository, service.RevocationStore, service.TokenVersionStore, &user, time.Now()); err != nil {
+			return domain.User{}, err
+		}
+	} else if roleChanged {
+		if err := revokeAccessTokens(ctx, tx, service.RevocationStore, service.TokenVersionStore, &user, time.Now()); err != nil {
+			return domain.User{}, err
+		}
+	}
+
+	if request.MustChangePassword != nil {
+		user.MustChangePassword = *request.MustChangePassword
 	}
 
 	updated, err := service.UserRepository.Update(ctx, tx, user)
@@ -83,13 +137,21 @@ func (service *UserServiceImpl) Update(ctx context.Context, request web.UserUpda
 	return updated, nil
 }
 
+// UpdateMe changes the profile of the signed-in user. The password can't be
+// changed here because that needs the current password, see
+// AuthService.ChangePassword.
 func (service *UserServiceImpl) UpdateMe(ctx context.Context, request web.UserUpdateRequest) (domain.User, error) {
 	if err := service.Validate.Struct(request); err != nil {
 		return domain.User{}, err
 	}
 
+	if request.PasswordHash != "" {
+		return domain.User{}, errors.New("use POST /users/me/password to change the password")
+	}
+
+	mails := newOutbox(service.Mailer)
 	tx := service.DB.Begin()
-	defer helper.CommitOrRollback(tx)
+	defer helper.CommitOrRollbackThen(tx, func() { mails.flush(ctx) })
 
 	user, err := service.UserRepository.FindById(ctx, tx, request.Id.String())
 	if err != nil {
@@ -97,11 +159,8 @@ func (service *UserServiceImpl) UpdateMe(ctx context.Context, request web.UserUp
 	}
 
 	user.FullName = request.FullName
-	user.Email = request.Email
-
-	if request.PasswordHash != "" {
-		hashed, _ := utils.HashPassword(request.PasswordHash)
-		user.PasswordHash = hashed
+	if err := service.changeEmail(ctx, tx, mails, &user, request.Email); err != nil {
+		return domain.User{}, err
 	}
 
 	updated, err := service.UserRepository.Update(ctx, tx, user)
@@ -112,15 +171,62 @@ func (service *UserServiceImpl) UpdateMe(ctx context.Context, request web.UserUp
 	return updated, nil
 }
 
+// changeEmail sets the email of user. A new address is unverified until the
+// link queued to it is opened, since the old verification says nothing
+// about who owns the new one. The caller saves user.
+func (service *UserServiceImpl) changeEmail(ctx context.Context, tx *gorm.DB, mails *outbox, user *domain.User, email string) error {
+	if strings.EqualFold(user.Email, email) {
+		user.Email = email
+		return nil
+	}
+
+	user.Email = email
+	user.IsVerified = false
+
+	return queueVerificationEmail(ctx, tx, service.UserTokenRepository, mails, *user, false)
+}
+
+// setPassword checks password against the policy and the user's previous
+// passwords, then replaces the hash on user. The caller saves user.
+func (service *UserServiceImpl) setPassword(ctx context.Context, tx *gorm.DB, user *domain.User, field string, password string) error {
+	if err := checkPasswordPolicy(field, password, user.Email, user.FullName); err != nil {
+		return err
+	}
+
+	if err := checkPasswordReuse(ctx, tx, service.PasswordHistoryRepository, *user, field, password); err != nil {
+		return err
+	}
+
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := rememberPassword(ctx, tx, service.PasswordHistoryRepository, *user); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.PasswordHash = hashed
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = false
+
+	return nil
+}
+
 func (service *UserServiceImpl) Delete(ctx context.Context, targetUserId string) error {
 	tx := service.DB.Begin()
 	defer helper.CommitOrRollback(tx)
 
-	_, err := service.UserRepository.FindById(ctx, tx, targetUserId)
+	user, err := service.UserRepository.FindById(ctx, tx, targetUserId)
 	if err != nil {
 		return err
 	}
 
+	if err := revokeAccessTokens(ctx, tx, service.RevocationStore, service.TokenVersionStore, &user, time.Now()); err != nil {
+		return err
+	}
+
 	if err := service.UserRepository.Delete(ctx, tx, targetUserId); err != nil {
 		return err
 	}
@@ -155,3 +261,16 @@ func (service *UserServiceImpl) FindAll(ctx context.Context) ([]domain.User, err
 
 	return user, nil
 }
+
+// Unlock clears the failed login counter and lockout of the user's email.
+func (service *UserServiceImpl) Unlock(ctx context.Context, targetUserId string) error {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	user, err := service.UserRepository.FindById(ctx, tx, targetUserId)
+	if err != nil {
+		return err
+	}
+
+	return service.LoginAttemptRepository.Reset(ctx, tx, emailAttemptKey(user.Email))
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type WebAuthnService interface {
+	RegistrationOptions(ctx context.Context, userId string) (web.WebAuthnCreationOptions, error)
+	Register(ctx context.Context, request web.WebAuthnRegisterRequest) (web.WebAuthnCredentialResponse, error)
+	SignupOptions(ctx context.Context, request web.WebAuthnSignupOptionsRequest) (web.WebAuthnCreationOptions, error)
+	LoginOptions(ctx context.Context, request web.WebAuthnLoginOptionsRequest) (web.WebAuthnRequestOptions, error)
+	// VerifySignup, SaveCredential and VerifyLogin run inside the caller's
+	// transaction. Challenges are burned on first use.
+	VerifySignup(ctx context.Context, tx *gorm.DB, email string, attestation web.WebAuthnAttestation) (domain.WebAuthnCredential, error)
+	SaveCredential(ctx context.Context, tx *gorm.DB, credential domain.WebAuthnCredential, name string) (web.WebAuthnCredentialResponse, error)
+	VerifyLogin(ctx context.Context, tx *gorm.DB, assertion web.WebAuthnAssertion) (domain.WebAuthnCredential, bool, error)
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/helper"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/utils"
+	"auth-api-jwt/webauthn"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebAuthnServiceImpl struct {
+	UserRepository               repository.UserRepository
+	WebAuthnCredentialRepository repository.WebAuthnCredentialRepository
+	UserTokenRepository          repository.UserTokenRepository
+	DB                           *gorm.DB
+	Validate                     *validator.Validate
+}
+
+func NewWebAuthnService(userRepository repository.UserRepository, webAuthnCredentialRepository repository.WebAuthnCredentialRepository, userTokenRepository repository.UserTokenRepository, DB *gorm.DB, validate *validator.Validate) WebAuthnService {
+	return &WebAuthnServiceImpl{
+		UserRepository:               userRepository,
+		WebAuthnCredentialRepository: webAuthnCredentialRepository,
+		UserTokenRepository:          userTokenRepository,
+		DB:                           DB,
+		Validate:                     validate,
+	}
+}
+
+func (service *WebAuthnServiceImpl) RegistrationOptions(ctx context.Context, userId string) (web.WebAuthnCreationOptions, error) {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	user, err := service.UserRepository.FindById(ctx, tx, userId)
+	if err != nil {
+		return web.WebAuthnCreationOptions{}, errors.New("user not found")
+	}
+
+	credentials, err := service.WebAuthnCredentialRepository.FindByUser(ctx, tx, user.Id)
+	if err != nil {
+		return web.WebAuthnCreationOptions{}, err
+	}
+
+	challenge, err := service.createChallenge(ctx, tx, domain.UserToken{UserId: user.Id, Purpose: domain.UserTokenWebAuthnRegister})
+	if err != nil {
+		return web.WebAuthnCreationOptions{}, err
+	}
+
+	return creationOptions(challenge, user.Id, user.Email, user.FullName, credentials), nil
+}
+
+func (service *WebAuthnServiceImpl) Register(ctx context.Context, request web.WebAuthnRegisterRequest) (web.WebAuthnCredentialResponse, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.WebAuthnCredentialResponse{}, err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	credential, _, err := service.verifyRegistration(ctx, tx, domain.UserTokenWebAuthnRegister, request.Credential)
+	if err != nil {
+		return web.WebAuthnCredentialResponse{}, err
+	}
+
+	if credential.UserId.String() != request.UserId {
+		return web.WebAuthnCredentialResponse{}, errors.New("invalid or expired challenge")
+	}
+
+	return service.SaveCredential(ctx, tx, credential, request.Name)
+}
+
+// SignupOptions starts registration of a passkey-only account. The user id
+// is chosen now because the authenticator stores it as the user handle;
+// the account itself is only created once the ceremony succeeds, and only
+// for the email the challenge was issued for.
+func (service *WebAuthnServiceImpl) SignupOptions(ctx context.Context, request web.WebAuthnSignupOptionsRequest) (web.WebAuthnCreationOptions, error) {
+	if err := service.Validate.Struct(request); err != nil {
+		return web.WebAuthnCreationOptions{}, err
+	}
+
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	if err := service.allowAnonymousChallenge(ctx, tx, domain.UserTokenWebAuthnSignup, request.ClientIp); err != nil {
+		return web.WebAuthnCreationOptions{}, err
+	}
+
+	userId := uuid.New()
+	challenge, err := service.createChallenge(ctx, tx, domain.UserToken{
+		UserId:   userId,
+		Purpose:  domain.UserTokenWebAuthnSignup,
+		Email:    request.Email,
+		ClientIp: request.ClientIp,
+	})
+	if err != nil {
+		return web.WebAuthnCreationOptions{}, err
+	}
+
+	return creationOptions(challenge, userId, request.Email, request.FullName, nil), nil
+}
+
+// LoginOptions always asks for a discoverable credential, so the response
+// does not depend on, or reveal, which accounts exist.
+func (service *WebAuthnServiceImpl) LoginOptions(ctx context.Context, request web.WebAuthnLoginOptionsRequest) (web.WebAuthnRequestOptions, error) {
+	tx := service.DB.Begin()
+	defer helper.CommitOrRollback(tx)
+
+	if err := service.allowAnonymousChallenge(ctx, tx, domain.UserTokenWebAuthnLogin, request.ClientIp); err != nil {
+		return web.WebAuthnRequestOptions{}, err
+	}
+
+	challenge, err := service.createChallenge(ctx, tx, domain.UserToken{
+		UserId:   uuid.Nil,
+		Purpose:  domain.UserTokenWebAuthnLogin,
+		ClientIp: request.ClientIp,
+	})
+	if err != nil {
+		return web.WebAuthnRequestOptions{}, err
+	}
+
+	return web.WebAuthnRequestOptions{
+		Challenge:        challenge,
+		RpId:             relyingParty().ID,
+		Timeout:          webAuthnTimeout().Milliseconds(),
+		AllowCredentials: []web.WebAuthnCredentialDescriptor{},
+		UserVerification: "preferred",
+	}, nil
+}
+
+// VerifySignup checks the attestation against a signup challenge issued
+// for email.
+func (service *WebAuthnServiceImpl) VerifySignup(ctx context.Context, tx *gorm.DB, email string, attestation web.WebAuthnAttestation) (domain.WebAuthnCredential, error) {
+	credential, token, err := service.verifyRegistration(ctx, tx, domain.UserTokenWebAuthnSignup, attestation)
+	if err != nil {
+		return domain.WebAuthnCredential{}, err
+	}
+
+	if !strings.EqualFold(token.Email, email) {
+		return domain.WebAuthnCredential{}, errors.New("invalid or expired challenge")
+	}
+
+	return credential, nil
+}
+
+func (service *WebAuthnServiceImpl) verifyRegistration(ctx context.Context, tx *gorm.DB, purpose string, attestation web.WebAuthnAttestation) (domain.WebAuthnCredential, domain.UserToken, error) {
+	if err := service.Validate.Struct(attestation); err != nil {
+		return domain.WebAuthnCredential{}, domain.UserToken{}, err
+	}
+
+	clientDataJSON, err := webauthn.DecodeBase64URL(attestation.Response.ClientDataJSON)
+	if err != nil {
+		return domain.WebAuthnCredential{}, domain.UserToken{}, errors.New("invalid client data")
+	}
+
+	attestationObject, err := webauthn.DecodeBase64URL(attestation.Response.AttestationObject)
+	if err != nil {
+		return domain.WebAuthnCredential{}, domain.UserToken{}, errors.New("invalid attestation object")
+	}
+
+	challenge, err := service.consumeChallenge(ctx, tx, purpose, clientDataJSON)
+	if err != nil {
+		return domain.WebAuthnCredential{}, domain.UserToken{}, err
+	}
+
+	authData, err := relyingParty().VerifyRegistration(clientDataJSON, attestationObject, challenge.Challenge)
+	if err != nil {
+		return domain.WebAuthnCredential{}, domain.UserToken{}, err
+	}
+
+	credentialId := webauthn.EncodeBase64URL(authData.CredentialID)
+	if credentialId != strings.TrimRight(attestation.Id, "=") {
+		return domain.WebAuthnCredential{}, domain.UserToken{}, errors.New("credential id mismatch")
+	}
+
+	if _, err := service.WebAuthnCredentialRepository.FindByCredentialId(ctx, tx, credentialId); err == nil {
+		return domain.WebAuthnCredential{}, domain.UserToken{}, errors.New("credential already registered")
+	}
+
+	return domain.WebAuthnCredential{
+		UserId:       challenge.Token.UserId,
+		CredentialId: credentialId,
+		PublicKey:    authData.PublicKey,
+		SignCount:    int64(authData.SignCount),
+	}, challenge.Token, nil
+}
+
+func (service *WebAuthnServiceImpl) SaveCredential(ctx context.Context, tx *gorm.DB, credential domain.WebAuthnCredential, name string) (web.WebAuthnCredentialResponse, error) {
+	credential.Name = name
+	if credential.Name == "" {
+		credential.Name = "Passkey"
+	}
+
+	saved, err := service.WebAuthnCredentialRepository.Save(ctx, tx, credential)
+	if err != nil {
+		return web.WebAuthnCredentialResponse{}, err
+	}
+
+	return web.WebAuthnCredentialResponse{
+		Id:           saved.Id,
+		CredentialId: saved.CredentialId,
+		Name:         saved.Name,
+		LastUsedAt:   saved.LastUsedAt,
+		CreatedAt:    saved.CreatedAt,
+	}, nil
+}
+
+// VerifyLogin also reports whether the authenticator verified the user
+// (PIN or biometric), which makes the passkey count as two factors.
+func (service *WebAuthnServiceImpl) VerifyLogin(ctx context.Context, tx *gorm.DB, assertion web.WebAuthnAssertion) (domain.WebAuthnCredential, bool, error) {
+	if err := service.Validate.Struct(assertion); err != nil {
+		return domain.WebAuthnCredential{}, false, err
+	}
+
+	clientDataJSON, err := webauthn.DecodeBase64URL(assertion.Response.ClientDataJSON)
+	if err != nil {
+		return domain.WebAuthnCredential{}, false, errors.New("invalid client data")
+	}
+
+	rawAuthData, err := webauthn.DecodeBase64URL(assertion.Response.AuthenticatorData)
+	if err != nil {
+		return domain.WebAuthnCredential{}, false, errors.New("invalid authenticator data")
+	}
+
+	signature, err := webauthn.DecodeBase64URL(assertion.Response.Signature)
+	if err != nil {
+		return domain.WebAuthnCredential{}, false, errors.New("invalid signature")
+	}
+
+	challenge, err := service.consumeChallenge(ctx, tx, domain.UserTokenWebAuthnLogin, clientDataJSON)
+	if err != nil {
+		return domain.WebAuthnCredential{}, false, err
+	}
+
+	credential, err := service.WebAuthnCredentialRepository.FindByCredentialId(ctx, tx, strings.TrimRight(assertion.Id, "="))
+	if err != nil {
+		return domain.WebAuthnCredential{}, false, errors.New("unknown credential")
+	}
+
+	if assertion.Response.UserHandle != "" {
+		userHandle, err := webauthn.DecodeBase64URL(assertion.Response.UserHandle)
+		if err != nil || string(userHandle) != string(credential.UserId[:]) {
+			return domain.WebAuthnCredential{}, false, errors.New("user handle mismatch")
+		}
+	}
+
+	authData, err := relyingParty().VerifyAssertion(clientDataJSON, rawAuthData, signature, credential.PublicKey, challenge.Challenge)
+	if err != nil {
+		return domain.WebAuthnCredential{}, false, err
+	}
+
+	// Authenticators that keep a counter must increase it on every use. A
+	// counter that goes backwards means the key material was copied.
+	signCount := int64(authData.SignCount)
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return domain.WebAuthnCredential{}, false, errors.New("credential sign count did not increase")
+	}
+
+	if err := service.WebAuthnCredentialRepository.UpdateSignCount(ctx, tx, credential.Id, signCount, time.Now()); err != nil {
+		return domain.WebAuthnCredential{}, false, err
+	}
+
+	return credential, authData.UserVerified(), nil
+}
+
+type webAuthnChallenge struct {
+	Token     domain.UserToken
+	Challenge string
+}
+
+func (service *WebAuthnServiceImpl) createChallenge(ctx context.Context, tx *gorm.DB, token domain.UserToken) (string, error) {
+	challenge, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	token.TokenHash = utils.HashToken(challenge)
+	token.ExpiresAt = time.Now().Add(webAuthnTimeout())
+
+	if _, err := service.UserTokenRepository.Save(ctx, tx, token); err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// allowAnonymousChallenge guards the options endpoints that need no
+// sign-in. Expired challenges are purged first, then each address may hold
+// at most WEBAUTHN_MAX_PENDING_CHALLENGES unused ones per purpose, so the
+// table cannot be filled faster than challenges expire.
+func (service *WebAuthnServiceImpl) allowAnonymousChallenge(ctx context.Context, tx *gorm.DB, purpose string, clientIp string) error {
+	now := time.Now()
+
+	if err := service.UserTokenRepository.DeleteExpired(ctx, tx, purpose, now); err != nil {
+		return err
+	}
+
+	pending, err := service.UserTokenRepository.CountPendingByClientIp(ctx, tx, purpose, clientIp, now)
+	if err != nil {
+		return err
+	}
+
+	if pending >= int64(utils.GetEnvInt("WEBAUTHN_MAX_PENDING_CHALLENGES", 10)) {
+		return exception.TooManyRequestsError{Message: "too many passkey challenges, try again later", RetryAfter: webAuthnTimeout()}
+	}
+
+	return nil
+}
+
+func (service *WebAuthnServiceImpl) consumeChallenge(ctx context.Context, tx *gorm.DB, purpose string, clientDataJSON []byte) (webAuthnChallenge, error) {
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return webAuthnChallenge{}, err
+	}
+
+	now := time.Now()
+
+	token, err := service.UserTokenRepository.FindByTokenHash(ctx, tx, purpose, utils.HashToken(clientData.Challenge))
+	if err != nil || token.UsedAt != nil || now.After(token.ExpiresAt) {
+		return webAuthnChallenge{}, errors.New("invalid or expired challenge")
+	}
+
+	used, err := service.UserTokenRepository.MarkUsed(ctx, tx, token.Id, now)
+	if err != nil {
+		return webAuthnChallenge{}, err
+	}
+	if !used {
+		return webAuthnChallenge{}, errors.New("invalid or expired challenge")
+	}
+
+	return webAuthnChallenge{Token: token, Challenge: clientData.Challenge}, nil
+}
+
+func creationOptions(challenge string, userId uuid.UUID, email string, fullName string, existing []domain.WebAuthnCredential) web.WebAuthnCreationOptions {
+	rp := relyingParty()
+
+	params := make([]web.WebAuthnCredentialParameter, 0, len(webauthn.SupportedAlgorithms))
+	for _, alg := range webauthn.SupportedAlgorithms {
+		params = append(params, web.WebAuthnCredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	exclude := make([]web.WebAuthnCredentialDescriptor, 0, len(existing))
+	for _, credential := range existing {
+		exclude = append(exclude, web.WebAuthnCredentialDescriptor{Type: "public-key", Id: credential.CredentialId})
+	}
+
+	return web.WebAuthnCreationOptions{
+		Challenge: challenge,
+		Rp:        web.WebAuthnRelyingParty{Id: rp.ID, Name: rp.Name},
+		User: web.WebAuthnUserEntity{
+			Id:          webauthn.EncodeBase64URL(userId[:]),
+			Name:        email,
+			DisplayName: fullName,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            webAuthnTimeout().Milliseconds(),
+		Attestation:        "none",
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: web.WebAuthnAuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "preferred",
+		},
+	}
+}
+
+func relyingParty() webauthn.RelyingParty {
+	rp := webauthn.RelyingParty{
+		ID:      os.Getenv("WEBAUTHN_RP_ID"),
+		Name:    os.Getenv("WEBAUTHN_RP_NAME"),
+		Origins: utils.GetEnvList("WEBAUTHN_ORIGINS"),
+	}
+
+	if rp.ID == "" {
+		rp.ID = "localhost"
+	}
+	if rp.Name == "" {
+		rp.Name = "Auth API"
+	}
+	if len(rp.Origins) == 0 {
+		rp.Origins = []string{"http://localhost:3000"}
+	}
+
+	return rp
+}
+
+func webAuthnTimeout() time.Duration {
+	return utils.GetEnvDuration("WEBAUTHN_TIMEOUT", 5*time.Minute)
+}
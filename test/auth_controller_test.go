@@ -2,32 +2,117 @@ package test
 
 import (
 	"auth-api-jwt/controller"
+	"auth-api-jwt/exception"
 	"auth-api-jwt/models/domain"
 	"auth-api-jwt/models/web"
+	"auth-api-jwt/service"
 	"bytes"
 	"context"
 	"encoding/json"
 	"io"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 type AuthServiceMock struct {
 	mock.Mock
 }
 
-func (m *AuthServiceMock) Register(ctx context.Context, request web.AuthRegisterRequest) (domain.User, error) {
+func (m *AuthServiceMock) Register(ctx context.Context, request web.AuthRegisterRequest) error {
 	args := m.Called(ctx, request)
-	return args.Get(0).(domain.User), args.Error(1)
+	return args.Error(0)
 }
 
-func (m *AuthServiceMock) Login(ctx context.Context, request web.AuthLoginRequest) (string, error) {
+func (m *AuthServiceMock) Login(ctx context.Context, request web.AuthLoginRequest) (web.AuthTokenResponse, error) {
 	args := m.Called(ctx, request)
-	return args.String(0), args.Error(1)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
+}
+
+func (m *AuthServiceMock) SignupWithPasskey(ctx context.Context, request web.AuthPasskeySignupRequest) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (m *AuthServiceMock) LoginWithPasskey(ctx context.Context, request web.AuthPasskeyLoginRequest) (web.AuthTokenResponse, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
+}
+
+func (m *AuthServiceMock) VerifyMfa(ctx context.Context, request web.AuthMfaVerifyRequest) (web.AuthTokenResponse, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
+}
+
+func (m *AuthServiceMock) Refresh(ctx context.Context, request web.AuthRefreshRequest) (web.AuthTokenResponse, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
+}
+
+func (m *AuthServiceMock) RefreshForClient(ctx context.Context, clientId string, request web.AuthRefreshRequest) (web.AuthTokenResponse, error) {
+	args := m.Called(ctx, clientId, request)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
+}
+
+func (m *AuthServiceMock) Logout(ctx context.Context, request web.AuthLogoutRequest) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (m *AuthServiceMock) VerifyEmail(ctx context.Context, request web.AuthVerifyEmailRequest) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (m *AuthServiceMock) ResendVerification(ctx context.Context, request web.AuthResendVerificationRequest) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (m *AuthServiceMock) ForgotPassword(ctx context.Context, request web.AuthForgotPasswordRequest) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (m *AuthServiceMock) ResetPassword(ctx context.Context, request web.AuthResetPasswordRequest) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (m *AuthServiceMock) ChangePassword(ctx context.Context, request web.AuthChangePasswordRequest) (web.AuthTokenResponse, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
+}
+
+func (m *AuthServiceMock) Reauthenticate(ctx context.Context, request web.AuthReauthenticateRequest) (web.AuthTokenResponse, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
+}
+
+func (m *AuthServiceMock) StartPasswordless(ctx context.Context, request web.AuthPasswordlessStartRequest) error {
+	args := m.Called(ctx, request)
+	return args.Error(0)
+}
+
+func (m *AuthServiceMock) VerifyPasswordless(ctx context.Context, request web.AuthPasswordlessVerifyRequest) (web.AuthTokenResponse, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
+}
+
+func (m *AuthServiceMock) Authenticate(ctx context.Context, tx *gorm.DB, request web.AuthLoginRequest, code string) (domain.User, []string, error) {
+	args := m.Called(ctx, tx, request, code)
+	return args.Get(0).(domain.User), args.Get(1).([]string), args.Error(2)
+}
+
+func (m *AuthServiceMock) IssueTokens(ctx context.Context, tx *gorm.DB, user domain.User, grant service.TokenGrant) (web.AuthTokenResponse, error) {
+	args := m.Called(ctx, tx, user, grant)
+	return args.Get(0).(web.AuthTokenResponse), args.Error(1)
 }
 
 func TestAuthController_Register_Success(t *testing.T) {
@@ -39,12 +124,7 @@ func TestAuthController_Register_Success(t *testing.T) {
 		FullName: "Test Name",
 	}
 
-	expectedUser := domain.User{
-		Email:    requestBody.Email,
-		FullName: requestBody.FullName,
-	}
-
-	mockService.On("Register", mock.Anything, mock.Anything).Return(expectedUser, nil)
+	mockService.On("Register", mock.Anything, mock.Anything).Return(nil)
 
 	app := fiber.New()
 	ctrl := controller.NewAuthController(mockService)
@@ -59,7 +139,7 @@ func TestAuthController_Register_Success(t *testing.T) {
 	assert.Equal(t, 200, resp.StatusCode)
 
 	raw, _ := io.ReadAll(resp.Body)
-	assert.Contains(t, string(raw), requestBody.Email)
+	assert.NotContains(t, string(raw), requestBody.Email)
 	mockService.AssertExpectations(t)
 }
 
@@ -67,7 +147,7 @@ func TestUserController_Register_InvalidJSON(t *testing.T) {
 	mockService := new(UserServiceMock)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Post("/register", ctrl.Create)
 
 	body := []byte(`{ invalid json }`)
@@ -83,6 +163,43 @@ func TestUserController_Register_InvalidJSON(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestAuthController_Register_ExistingEmailIndistinguishable(t *testing.T) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+
+	existing := domain.User{Id: uuid.New(), Email: "dup@example.com", FullName: "Dup", Role: "user"}
+	created := domain.User{Id: uuid.New(), Email: "new@example.com", FullName: "New", Role: "user"}
+
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, existing.Email).Return(existing, nil)
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, created.Email).Return(domain.User{}, gorm.ErrRecordNotFound)
+	authMock.On("Create", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(created, nil)
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(svc)
+	app.Post("/register", ctrl.Register)
+
+	register := func(email string) (int, string) {
+		bodyBytes, _ := json.Marshal(web.AuthRegisterRequest{Email: email, Password: "Tr0ub4dor-cobalt-meadow", FullName: "Someone"})
+		req := httptest.NewRequest("POST", "/register", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		raw, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(raw)
+	}
+
+	freshStatus, freshBody := register(created.Email)
+	takenStatus, takenBody := register(existing.Email)
+
+	assert.Equal(t, 200, freshStatus)
+	assert.Equal(t, freshStatus, takenStatus)
+	assert.Equal(t, freshBody, takenBody)
+}
+
 func TestAuthController_Register_ServiceError(t *testing.T) {
 	mockService := new(AuthServiceMock)
 
@@ -92,7 +209,7 @@ func TestAuthController_Register_ServiceError(t *testing.T) {
 		FullName: "Err",
 	}
 
-	mockService.On("Register", mock.Anything, mock.Anything).Return(domain.User{}, assert.AnError)
+	mockService.On("Register", mock.Anything, mock.Anything).Return(assert.AnError)
 
 	app := fiber.New()
 	ctrl := controller.NewAuthController(mockService)
@@ -117,8 +234,8 @@ func TestAuthController_Login_Success(t *testing.T) {
 		Password: "secret",
 	}
 
-	token := "jwt.token.value"
-	mockService.On("Login", mock.Anything, mock.Anything).Return(token, nil)
+	tokens := web.AuthTokenResponse{Token: "jwt.token.value", RefreshToken: "refresh.token.value", TokenType: "Bearer"}
+	mockService.On("Login", mock.Anything, mock.Anything).Return(tokens, nil)
 
 	app := fiber.New()
 	ctrl := controller.NewAuthController(mockService)
@@ -132,6 +249,9 @@ func TestAuthController_Login_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 200, resp.StatusCode)
 
+	raw, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(raw), tokens.Token)
+	assert.Contains(t, string(raw), tokens.RefreshToken)
 	mockService.AssertExpectations(t)
 }
 
@@ -139,7 +259,7 @@ func TestUserController_Login_InvalidJSON(t *testing.T) {
 	mockService := new(UserServiceMock)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Post("/login", ctrl.Create)
 
 	body := []byte(`{ invalid json }`)
@@ -163,7 +283,7 @@ func TestAuthController_Login_ServiceError(t *testing.T) {
 		Password: "secret",
 	}
 
-	mockService.On("Login", mock.Anything, mock.Anything).Return("", assert.AnError)
+	mockService.On("Login", mock.Anything, mock.Anything).Return(web.AuthTokenResponse{}, assert.AnError)
 
 	app := fiber.New()
 	ctrl := controller.NewAuthController(mockService)
@@ -179,3 +299,222 @@ func TestAuthController_Login_ServiceError(t *testing.T) {
 
 	mockService.AssertExpectations(t)
 }
+
+func TestAuthController_Login_Locked(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	mockService.On("Login", mock.Anything, mock.MatchedBy(func(request web.AuthLoginRequest) bool {
+		return request.ClientIp != ""
+	})).Return(web.AuthTokenResponse{}, exception.AccountLockedError{Message: "account locked", RetryAfter: time.Minute})
+
+	app := fiber.New(fiber.Config{ErrorHandler: exception.NewErrorHandler})
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/login", ctrl.Login)
+
+	bodyBytes, _ := json.Marshal(web.AuthLoginRequest{Email: "locked@example.com", Password: "secret"})
+	req := httptest.NewRequest("POST", "/login", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 423, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthController_Refresh_Success(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	requestBody := web.AuthRefreshRequest{RefreshToken: "old.refresh"}
+	tokens := web.AuthTokenResponse{Token: "new.jwt", RefreshToken: "new.refresh", TokenType: "Bearer"}
+
+	mockService.On("Refresh", mock.Anything, requestBody).Return(tokens, nil)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/refresh", ctrl.Refresh)
+
+	bodyBytes, _ := json.Marshal(requestBody)
+	req := httptest.NewRequest("POST", "/refresh", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	raw, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(raw), "new.refresh")
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthController_Refresh_Rejected(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	mockService.On("Refresh", mock.Anything, mock.Anything).Return(web.AuthTokenResponse{}, assert.AnError)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/refresh", ctrl.Refresh)
+
+	bodyBytes, _ := json.Marshal(web.AuthRefreshRequest{RefreshToken: "reused"})
+	req := httptest.NewRequest("POST", "/refresh", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthController_VerifyMfa(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	requestBody := web.AuthMfaVerifyRequest{MfaToken: "challenge", Code: "123456"}
+	tokens := web.AuthTokenResponse{Token: "mfa.jwt", RefreshToken: "mfa.refresh", TokenType: "Bearer"}
+
+	// the controller adds the client address for the session record
+	expected := requestBody
+	expected.ClientIp = "0.0.0.0"
+
+	mockService.On("VerifyMfa", mock.Anything, expected).Return(tokens, nil).Once()
+	mockService.On("VerifyMfa", mock.Anything, mock.Anything).Return(web.AuthTokenResponse{}, assert.AnError)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/mfa/verify", ctrl.VerifyMfa)
+
+	for body, status := range map[web.AuthMfaVerifyRequest]int{
+		requestBody:                             200,
+		{MfaToken: "challenge", Code: "000000"}: 401,
+	} {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/mfa/verify", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthController_Logout_UsesTokenFromContext(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	mockService.On("Logout", mock.Anything, mock.MatchedBy(func(request web.AuthLogoutRequest) bool {
+		return request.UserId == "user-1" && request.Jti == "jti-1" && request.RefreshToken == "refresh-1"
+	})).Return(nil)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/logout", func(c *fiber.Ctx) error {
+		c.Locals("userId", "user-1")
+		c.Locals("jti", "jti-1")
+		c.Locals("tokenExpiresAt", time.Now().Add(time.Minute))
+		return c.Next()
+	}, ctrl.Logout)
+
+	bodyBytes, _ := json.Marshal(web.AuthLogoutRequest{RefreshToken: "refresh-1"})
+	req := httptest.NewRequest("POST", "/logout", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthController_VerifyEmail(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	mockService.On("VerifyEmail", mock.Anything, web.AuthVerifyEmailRequest{Token: "good"}).Return(nil)
+	mockService.On("VerifyEmail", mock.Anything, web.AuthVerifyEmailRequest{Token: "bad"}).Return(assert.AnError)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/verify-email", ctrl.VerifyEmail)
+
+	for token, status := range map[string]int{"good": 200, "bad": 400} {
+		bodyBytes, _ := json.Marshal(web.AuthVerifyEmailRequest{Token: token})
+		req := httptest.NewRequest("POST", "/verify-email", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthController_ResendVerification(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	mockService.On("ResendVerification", mock.Anything, web.AuthResendVerificationRequest{Email: "someone@example.com"}).Return(nil)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/resend-verification", ctrl.ResendVerification)
+
+	bodyBytes, _ := json.Marshal(web.AuthResendVerificationRequest{Email: "someone@example.com"})
+	req := httptest.NewRequest("POST", "/resend-verification", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthController_ForgotPassword_SameResponse(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	mockService.On("ForgotPassword", mock.Anything, mock.Anything).Return(nil)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/forgot-password", ctrl.ForgotPassword)
+
+	var bodies []string
+	for _, email := range []string{"exists@example.com", "missing@example.com"} {
+		bodyBytes, _ := json.Marshal(web.AuthForgotPasswordRequest{Email: email})
+		req := httptest.NewRequest("POST", "/forgot-password", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+
+		raw, _ := io.ReadAll(resp.Body)
+		bodies = append(bodies, string(raw))
+	}
+
+	assert.Equal(t, bodies[0], bodies[1])
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthController_ResetPassword(t *testing.T) {
+	mockService := new(AuthServiceMock)
+
+	request := web.AuthResetPasswordRequest{Token: "reset", NewPassword: "newsecret"}
+	mockService.On("ResetPassword", mock.Anything, request).Return(nil)
+
+	app := fiber.New()
+	ctrl := controller.NewAuthController(mockService)
+	app.Post("/reset-password", ctrl.ResetPassword)
+
+	bodyBytes, _ := json.Marshal(request)
+	req := httptest.NewRequest("POST", "/reset-password", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	mockService.AssertExpectations(t)
+}
@@ -1,14 +1,20 @@
 package test
 
 import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/mailer"
 	"auth-api-jwt/models/domain"
 	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
 	"auth-api-jwt/service"
 	"auth-api-jwt/utils"
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -33,11 +39,10 @@ func TestAuthService_Register_Success(t *testing.T) {
 	authMock := new(AuthRepositoryMock)
 	userMock := new(UserRepositoryMock)
 	db := setupTestDB(t)
-	validate := validator.New()
 
 	request := web.AuthRegisterRequest{
 		Email:    "reg@example.com",
-		Password: "secret",
+		Password: "Tr0ub4dor-cobalt-meadow",
 		FullName: "Reg User",
 	}
 
@@ -50,20 +55,60 @@ func TestAuthService_Register_Success(t *testing.T) {
 		IsVerified:   false,
 	}
 
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, request.Email).Return(domain.User{}, gorm.ErrRecordNotFound)
 	authMock.On("Create", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(expected, nil)
 
-	svc := service.NewAuthService(authMock, userMock, db, validate)
-	got, err := svc.Register(context.Background(), request)
+	svc := newTestAuthService(t, db, authMock, userMock)
+	err := svc.Register(context.Background(), request)
 	assert.NoError(t, err)
-	assert.Equal(t, expected.Email, got.Email)
 	authMock.AssertExpectations(t)
 }
 
+func TestAuthService_Register_PasswordPolicy(t *testing.T) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+
+	breachedList := filepath.Join(t.TempDir(), "breached.txt")
+	assert.NoError(t, os.WriteFile(breachedList, []byte("Tr0ub4dor-cobalt-meadow\n"), 0o600))
+	assert.NoError(t, utils.LoadBreachedPasswords(breachedList))
+	t.Cleanup(func() { utils.LoadBreachedPasswords(os.DevNull) })
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+
+	codes := func(password string) []string {
+		err := svc.Register(context.Background(), web.AuthRegisterRequest{Email: "jane.doe@example.com", Password: password, FullName: "Jane Doe"})
+
+		var policyErr exception.ValidationError
+		if !assert.ErrorAs(t, err, &policyErr) {
+			return nil
+		}
+
+		result := []string{}
+		for _, fieldError := range policyErr.Errors {
+			assert.Equal(t, "password", fieldError.Field)
+			result = append(result, fieldError.Code)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"too_short", "too_weak"}, codes("abc123"))
+	assert.Equal(t, []string{"contains_personal_info", "too_weak"}, codes("janedoe2024"))
+	assert.Equal(t, []string{"breached"}, codes("Tr0ub4dor-cobalt-meadow"))
+
+	// 40 characters but 80 bytes, more than bcrypt reads
+	assert.Equal(t, []string{"too_long"}, codes(strings.Repeat("é", 40)))
+	t.Setenv("PASSWORD_HASHER", "argon2id")
+	assert.NotContains(t, codes(strings.Repeat("é", 40)), "too_long")
+
+	// nothing was looked up or stored for rejected passwords
+	authMock.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestAuthService_Login_Success(t *testing.T) {
 	authMock := new(AuthRepositoryMock)
 	userMock := new(UserRepositoryMock)
 	db := setupTestDB(t)
-	validate := validator.New()
 
 	password := "mypassword"
 	hashed, err := utils.HashPassword(password)
@@ -81,10 +126,11 @@ func TestAuthService_Login_Success(t *testing.T) {
 
 	userMock.On("UpdateLastLogin", mock.Anything, mock.Anything, user.Id.String(), mock.Anything).Return(nil)
 
-	svc := service.NewAuthService(authMock, userMock, db, validate)
-	token, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: user.Email, Password: password})
+	svc := newTestAuthService(t, db, authMock, userMock)
+	tokens, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: user.Email, Password: password})
 	assert.NoError(t, err)
-	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, tokens.Token)
+	assert.NotEmpty(t, tokens.RefreshToken)
 
 	authMock.AssertExpectations(t)
 	userMock.AssertExpectations(t)
@@ -94,14 +140,13 @@ func TestAuthService_Register_InvalidRequest(t *testing.T) {
 	authMock := new(AuthRepositoryMock)
 	userMock := new(UserRepositoryMock)
 	db := setupTestDB(t)
-	validate := validator.New()
 
 	req := web.AuthRegisterRequest{
 		Email: "not-an-email",
 	}
 
-	svc := service.NewAuthService(authMock, userMock, db, validate)
-	_, err := svc.Register(context.Background(), req)
+	svc := newTestAuthService(t, db, authMock, userMock)
+	err := svc.Register(context.Background(), req)
 	if err == nil {
 		t.Fatalf("expected validation error for invalid register request")
 	}
@@ -111,16 +156,15 @@ func TestAuthService_Login_InvalidEmail(t *testing.T) {
 	authMock := new(AuthRepositoryMock)
 	userMock := new(UserRepositoryMock)
 	db := setupTestDB(t)
-	validate := validator.New()
 
 	authMock.On("FindByEmail", mock.Anything, mock.Anything, "noone@example.com").Return(domain.User{}, assert.AnError)
 
-	svc := service.NewAuthService(authMock, userMock, db, validate)
-	token, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: "noone@example.com", Password: "whatever"})
+	svc := newTestAuthService(t, db, authMock, userMock)
+	tokens, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: "noone@example.com", Password: "whatever"})
 	if err == nil {
 		t.Fatalf("expected error for unknown email login")
 	}
-	if token != "" {
+	if tokens.Token != "" {
 		t.Fatalf("expected empty token on failed login")
 	}
 	authMock.AssertExpectations(t)
@@ -130,7 +174,6 @@ func TestAuthService_Login_WrongPassword(t *testing.T) {
 	authMock := new(AuthRepositoryMock)
 	userMock := new(UserRepositoryMock)
 	db := setupTestDB(t)
-	validate := validator.New()
 
 	hashed, err := utils.HashPassword("correctpass")
 	assert.NoError(t, err)
@@ -145,15 +188,413 @@ func TestAuthService_Login_WrongPassword(t *testing.T) {
 
 	authMock.On("FindByEmail", mock.Anything, mock.Anything, user.Email).Return(user, nil)
 
-	svc := service.NewAuthService(authMock, userMock, db, validate)
-	token, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: user.Email, Password: "wrongpass"})
+	svc := newTestAuthService(t, db, authMock, userMock)
+	tokens, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: user.Email, Password: "wrongpass"})
 	if err == nil {
 		t.Fatalf("expected error for wrong password")
 	}
-	if token != "" {
+	if tokens.Token != "" {
 		t.Fatalf("expected empty token on failed login")
 	}
 
 	userMock.AssertNotCalled(t, "UpdateLastLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 	authMock.AssertExpectations(t)
 }
+
+func TestAuthService_Login_UnknownEmailIndistinguishable(t *testing.T) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+
+	hashed, err := utils.HashPassword("correctpass")
+	assert.NoError(t, err)
+
+	// fresh emails keep earlier runs from locking these out
+	user := domain.User{Id: uuid.New(), Email: uuid.NewString() + "@example.com", PasswordHash: hashed, FullName: "Known", Role: "user"}
+	unknownEmail := uuid.NewString() + "@example.com"
+
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, user.Email).Return(user, nil)
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, mock.Anything).Return(domain.User{}, gorm.ErrRecordNotFound)
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+
+	start := utils.PasswordComputations()
+	knownTokens, knownErr := svc.Login(context.Background(), web.AuthLoginRequest{Email: user.Email, Password: "wrongpass"})
+	knownHashes := utils.PasswordComputations() - start
+
+	start = utils.PasswordComputations()
+	unknownTokens, unknownErr := svc.Login(context.Background(), web.AuthLoginRequest{Email: unknownEmail, Password: "wrongpass"})
+	unknownHashes := utils.PasswordComputations() - start
+
+	assert.Equal(t, knownTokens, unknownTokens)
+	assert.EqualError(t, unknownErr, knownErr.Error())
+
+	// the unknown email is checked against the dummy hash, which costs
+	// as much as checking the real one
+	assert.Equal(t, int64(1), knownHashes)
+	assert.Equal(t, knownHashes, unknownHashes)
+}
+
+func TestAuthService_Register_ExistingEmailIndistinguishable(t *testing.T) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+	mailerMock := new(MailerMock)
+
+	existing := domain.User{Id: uuid.New(), Email: "taken@example.com", FullName: "Taken", Role: "user"}
+	created := domain.User{Id: uuid.New(), Email: "fresh@example.com", FullName: "Fresh", Role: "user"}
+
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, existing.Email).Return(existing, nil)
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, created.Email).Return(domain.User{}, gorm.ErrRecordNotFound)
+	authMock.On("Create", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(created, nil).Once()
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+	svc.Mailer = mailerMock
+
+	start := utils.PasswordComputations()
+	freshErr := svc.Register(context.Background(), web.AuthRegisterRequest{Email: created.Email, Password: "Tr0ub4dor-cobalt-meadow", FullName: "Fresh"})
+	freshHashes := utils.PasswordComputations() - start
+
+	start = utils.PasswordComputations()
+	takenErr := svc.Register(context.Background(), web.AuthRegisterRequest{Email: existing.Email, Password: "Tr0ub4dor-cobalt-meadow", FullName: "Someone"})
+	takenHashes := utils.PasswordComputations() - start
+
+	assert.NoError(t, freshErr)
+	assert.NoError(t, takenErr)
+	assert.Equal(t, int64(1), freshHashes)
+	assert.Equal(t, freshHashes, takenHashes)
+
+	// the owner of the existing account is told about the attempt
+	assert.Len(t, mailerMock.Messages, 2)
+	assert.Equal(t, existing.Email, mailerMock.Last().To)
+	assert.Equal(t, "You already have an account", mailerMock.Last().Subject)
+
+	authMock.AssertExpectations(t)
+}
+
+func TestAuthService_Login_RehashesOutdatedPassword(t *testing.T) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+
+	hashed, err := utils.BcryptHasher{Cost: 4}.Hash("mypassword")
+	assert.NoError(t, err)
+
+	user := domain.User{Id: uuid.New(), Email: "rehash@example.com", PasswordHash: hashed, FullName: "Rehash", Role: "user"}
+
+	t.Setenv("PASSWORD_HASHER", "argon2id")
+	t.Setenv("ARGON2_MEMORY", "1024")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+
+	var upgraded string
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, user.Email).Return(user, nil)
+	userMock.On("UpdateLastLogin", mock.Anything, mock.Anything, user.Id.String(), mock.Anything).Return(nil)
+	userMock.On("UpdatePassword", mock.Anything, mock.Anything, user.Id.String(), mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { upgraded = args.String(3) }).Return(nil).Once()
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+	tokens, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: user.Email, Password: "mypassword"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.Token)
+
+	assert.True(t, strings.HasPrefix(upgraded, "$argon2id$"))
+	assert.True(t, utils.CheckPassword("mypassword", upgraded))
+	userMock.AssertExpectations(t)
+}
+
+func loginForRefresh(t *testing.T, email string) (service.AuthService, domain.User, web.AuthTokenResponse) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+
+	hashed, err := utils.HashPassword("mypassword")
+	assert.NoError(t, err)
+
+	user := domain.User{
+		Id:           uuid.New(),
+		Email:        email,
+		PasswordHash: hashed,
+		FullName:     "Refresh User",
+		Role:         "user",
+	}
+
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, user.Email).Return(user, nil)
+	userMock.On("UpdateLastLogin", mock.Anything, mock.Anything, user.Id.String(), mock.Anything).Return(nil)
+	userMock.On("FindById", mock.Anything, mock.Anything, user.Id.String()).Return(user, nil)
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+	tokens, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: user.Email, Password: "mypassword"})
+	assert.NoError(t, err)
+
+	return svc, user, tokens
+}
+
+func TestAuthService_Reauthenticate(t *testing.T) {
+	os.Setenv("JWT_SECRET", "testsecret")
+	svc, user, tokens := loginForRefresh(t, "reauth@example.com")
+	ctx := context.Background()
+
+	claims, err := utils.ParseJWT(tokens.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{utils.AmrPassword}, claims["amr"])
+	assert.NotNil(t, claims["auth_time"])
+	sessionId := claims["sid"].(string)
+
+	request := web.AuthReauthenticateRequest{UserId: user.Id.String(), SessionId: sessionId, Password: "wrong"}
+	_, err = svc.Reauthenticate(ctx, request)
+	assert.EqualError(t, err, "invalid password or code")
+
+	// a code is only accepted from users with MFA
+	request.Password, request.Code = "", "123456"
+	_, err = svc.Reauthenticate(ctx, request)
+	assert.EqualError(t, err, "invalid password or code")
+
+	request.Password, request.Code = "mypassword", ""
+	reauthenticated, err := svc.Reauthenticate(ctx, request)
+	assert.NoError(t, err)
+	assert.Empty(t, reauthenticated.RefreshToken)
+
+	fresh, err := utils.ParseJWT(reauthenticated.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, sessionId, fresh["sid"])
+	assert.GreaterOrEqual(t, fresh["auth_time"].(float64), claims["auth_time"].(float64))
+
+	// refreshed tokens keep the session's authentication
+	refreshed, err := svc.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.NoError(t, err)
+	rotated, err := utils.ParseJWT(refreshed.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, sessionId, rotated["sid"])
+	assert.Equal(t, fresh["auth_time"], rotated["auth_time"])
+	assert.Equal(t, fresh["amr"], rotated["amr"])
+
+	// once the session is signed out it can't be re-authenticated
+	assert.NoError(t, svc.Logout(ctx, web.AuthLogoutRequest{UserId: user.Id.String(), RefreshToken: refreshed.RefreshToken}))
+	_, err = svc.Reauthenticate(ctx, request)
+	assert.EqualError(t, err, "session has ended, sign in again")
+}
+
+func TestAuthService_Refresh_Rotates(t *testing.T) {
+	svc, _, tokens := loginForRefresh(t, "rotate@example.com")
+
+	rotated, err := svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rotated.Token)
+	assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+
+	_, err = svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: rotated.RefreshToken})
+	assert.NoError(t, err)
+}
+
+func TestAuthService_Refresh_ReuseRevokesFamily(t *testing.T) {
+	svc, _, tokens := loginForRefresh(t, "reuse@example.com")
+
+	rotated, err := svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.NoError(t, err)
+
+	// replaying the already-rotated token kills the whole family
+	_, err = svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Error(t, err)
+
+	_, err = svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: rotated.RefreshToken})
+	assert.Error(t, err)
+}
+
+func TestAuthService_Refresh_UnknownToken(t *testing.T) {
+	svc, _, _ := loginForRefresh(t, "unknown-refresh@example.com")
+
+	_, err := svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: "not-a-real-token"})
+	assert.Error(t, err)
+
+	_, err = svc.Refresh(context.Background(), web.AuthRefreshRequest{})
+	assert.Error(t, err)
+}
+
+func TestAuthService_Logout_RevokesAccessAndRefreshTokens(t *testing.T) {
+	svc, user, tokens := loginForRefresh(t, "logout@example.com")
+
+	store := svc.(*service.AuthServiceImpl).RevocationStore
+
+	err := svc.Logout(context.Background(), web.AuthLogoutRequest{
+		UserId:         user.Id.String(),
+		Jti:            "logout-jti",
+		TokenExpiresAt: time.Now().Add(time.Minute),
+		RefreshToken:   tokens.RefreshToken,
+	})
+	assert.NoError(t, err)
+
+	revoked, err := store.IsRevoked(context.Background(), "logout-jti")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, err = svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Error(t, err)
+}
+
+func TestAuthService_Logout_ForeignRefreshToken(t *testing.T) {
+	svc, _, tokens := loginForRefresh(t, "logout-foreign@example.com")
+
+	err := svc.Logout(context.Background(), web.AuthLogoutRequest{
+		UserId:       uuid.NewString(),
+		RefreshToken: tokens.RefreshToken,
+	})
+	assert.Error(t, err)
+
+	_, err = svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.NoError(t, err)
+}
+
+func TestAuthService_Register_SendsVerificationEmail(t *testing.T) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+	mailerMock := new(MailerMock)
+
+	created := domain.User{Id: uuid.New(), Email: "verify@example.com", FullName: "Verify", Role: "user"}
+	authMock.On("Create", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(created, nil)
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, created.Email).Return(domain.User{}, gorm.ErrRecordNotFound)
+	userMock.On("MarkVerified", mock.Anything, mock.Anything, created.Id.String()).Return(nil).Once()
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+	svc.Mailer = mailerMock
+
+	err := svc.Register(context.Background(), web.AuthRegisterRequest{Email: created.Email, Password: "Tr0ub4dor-cobalt-meadow", FullName: "Verify"})
+	assert.NoError(t, err)
+	assert.Len(t, mailerMock.Messages, 1)
+	assert.Equal(t, created.Email, mailerMock.Last().To)
+
+	token := tokenFromMail(mailerMock.Last())
+	assert.NotEmpty(t, token)
+
+	err = svc.VerifyEmail(context.Background(), web.AuthVerifyEmailRequest{Token: token})
+	assert.NoError(t, err)
+
+	// the token is single-use
+	err = svc.VerifyEmail(context.Background(), web.AuthVerifyEmailRequest{Token: token})
+	assert.Error(t, err)
+
+	userMock.AssertExpectations(t)
+}
+
+func TestAuthService_ResendVerification_DoesNotRevealAccounts(t *testing.T) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+	mailerMock := new(MailerMock)
+
+	unverified := domain.User{Id: uuid.New(), Email: "resend@example.com", FullName: "Resend"}
+	verified := domain.User{Id: uuid.New(), Email: "done@example.com", FullName: "Done", IsVerified: true}
+
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, unverified.Email).Return(unverified, nil)
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, verified.Email).Return(verified, nil)
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, "ghost@example.com").Return(domain.User{}, assert.AnError)
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+	svc.Mailer = mailerMock
+
+	for _, email := range []string{"ghost@example.com", verified.Email, unverified.Email} {
+		err := svc.ResendVerification(context.Background(), web.AuthResendVerificationRequest{Email: email})
+		assert.NoError(t, err)
+	}
+
+	assert.Len(t, mailerMock.Messages, 1)
+	assert.Equal(t, unverified.Email, mailerMock.Last().To)
+
+	// a second request inside the cooldown is silently dropped
+	err := svc.ResendVerification(context.Background(), web.AuthResendVerificationRequest{Email: unverified.Email})
+	assert.NoError(t, err)
+	assert.Len(t, mailerMock.Messages, 1)
+}
+
+func TestAuthService_Login_RequiresVerifiedEmail(t *testing.T) {
+	os.Setenv("REQUIRE_VERIFIED_EMAIL", "true")
+	defer os.Unsetenv("REQUIRE_VERIFIED_EMAIL")
+
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+
+	hashed, _ := utils.HashPassword("mypassword")
+	user := domain.User{Id: uuid.New(), Email: "unverified@example.com", PasswordHash: hashed, Role: "user"}
+
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, user.Email).Return(user, nil)
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+
+	_, err := svc.Login(context.Background(), web.AuthLoginRequest{Email: user.Email, Password: "mypassword"})
+	assert.EqualError(t, err, "email not verified")
+	userMock.AssertNotCalled(t, "UpdateLastLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// committedTokenMailer looks up the token of every message it is sent
+// outside the sender's transaction.
+type committedTokenMailer struct {
+	MailerMock
+	db       *gorm.DB
+	lookupOk []bool
+}
+
+func (m *committedTokenMailer) Send(ctx context.Context, message mailer.Message) error {
+	_, err := repository.NewUserTokenRepository(m.db).FindByTokenHash(ctx, m.db, domain.UserTokenPasswordReset, utils.HashToken(tokenFromMail(message)))
+	m.lookupOk = append(m.lookupOk, err == nil)
+	return m.MailerMock.Send(ctx, message)
+}
+
+func TestAuthService_ForgotPassword_MailsAfterCommit(t *testing.T) {
+	authMock := new(AuthRepositoryMock)
+	userMock := new(UserRepositoryMock)
+	db := setupTestDB(t)
+	mailerMock := &committedTokenMailer{db: db}
+
+	user := domain.User{Id: uuid.New(), Email: uuid.NewString() + "@example.com", FullName: "Forgetful", Role: "user"}
+	authMock.On("FindByEmail", mock.Anything, mock.Anything, user.Email).Return(user, nil)
+
+	svc := newTestAuthService(t, db, authMock, userMock)
+	svc.Mailer = mailerMock
+
+	assert.NoError(t, svc.ForgotPassword(context.Background(), web.AuthForgotPasswordRequest{Email: user.Email}))
+
+	// the link in the email already works when the email goes out
+	assert.Equal(t, []bool{true}, mailerMock.lookupOk)
+}
+
+func TestAuthService_ResetPassword_RevokesSessions(t *testing.T) {
+	svc, user, tokens := loginForRefresh(t, "reset@example.com")
+	impl := svc.(*service.AuthServiceImpl)
+	mailerMock := impl.Mailer.(*MailerMock)
+
+	impl.AuthRepository.(*AuthRepositoryMock).On("FindByEmail", mock.Anything, mock.Anything, "nobody@example.com").Return(domain.User{}, assert.AnError)
+	impl.UserRepository.(*UserRepositoryMock).On("ChangePassword", mock.Anything, mock.Anything, user.Id.String(), mock.AnythingOfType("string"), mock.Anything).Return(nil).Once()
+
+	err := svc.ForgotPassword(context.Background(), web.AuthForgotPasswordRequest{Email: "nobody@example.com"})
+	assert.NoError(t, err)
+	assert.Empty(t, mailerMock.Messages)
+
+	err = svc.ForgotPassword(context.Background(), web.AuthForgotPasswordRequest{Email: user.Email})
+	assert.NoError(t, err)
+	assert.Len(t, mailerMock.Messages, 1)
+
+	resetToken := tokenFromMail(mailerMock.Last())
+	issuedBefore := time.Now().Add(-time.Second)
+
+	// a rejected password does not burn the link
+	err = svc.ResetPassword(context.Background(), web.AuthResetPasswordRequest{Token: resetToken, NewPassword: "password"})
+	var policyErr exception.ValidationError
+	assert.ErrorAs(t, err, &policyErr)
+
+	err = svc.ResetPassword(context.Background(), web.AuthResetPasswordRequest{Token: resetToken, NewPassword: "brand-new-pass"})
+	assert.NoError(t, err)
+
+	// single use
+	err = svc.ResetPassword(context.Background(), web.AuthResetPasswordRequest{Token: resetToken, NewPassword: "another-pass"})
+	assert.Error(t, err)
+
+	_, err = svc.Refresh(context.Background(), web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Error(t, err)
+
+	revoked, err := impl.RevocationStore.IsUserRevoked(context.Background(), user.Id.String(), issuedBefore)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	impl.UserRepository.(*UserRepositoryMock).AssertExpectations(t)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -130,3 +131,58 @@ func TestErrorHandler_InternalServerError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, writer.Code)
 	assert.Equal(t, "INTERNAL SERVICE ERROR", writer.Status)
 }
+
+func TestErrorHandler_AccountLockedError(t *testing.T) {
+	app := setupApp()
+
+	app.Get("/locked", func(c *fiber.Ctx) error {
+		return exception.AccountLockedError{Message: "account locked", RetryAfter: 90 * time.Second}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/locked", nil)
+	resp, _ := app.Test(req, -1)
+	assert.Equal(t, http.StatusLocked, resp.StatusCode)
+	assert.Equal(t, "90", resp.Header.Get("Retry-After"))
+
+	writer := decodeResponse(t, resp)
+	assert.Equal(t, "LOCKED", writer.Status)
+	assert.Equal(t, "account locked", writer.Data)
+}
+
+func TestErrorHandler_TooManyRequestsError(t *testing.T) {
+	app := setupApp()
+
+	app.Get("/slow-down", func(c *fiber.Ctx) error {
+		return exception.TooManyRequestsError{Message: "try again later", RetryAfter: 1500 * time.Millisecond}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/slow-down", nil)
+	resp, _ := app.Test(req, -1)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+}
+
+func TestErrorHandler_FieldErrors(t *testing.T) {
+	app := setupApp()
+
+	app.Post("/password", func(c *fiber.Ctx) error {
+		return exception.ValidationError{Errors: []exception.FieldError{
+			{Field: "password", Code: "too_short", Message: "must be at least 8 characters"},
+			{Field: "password", Code: "breached", Message: "appears in a known data breach, choose another one"},
+		}}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/password", nil)
+	resp, _ := app.Test(req, -1)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var body struct {
+		Status string                 `json:"status"`
+		Data   []exception.FieldError `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "BAD REQUEST", body.Status)
+	assert.Len(t, body.Data, 2)
+	assert.Equal(t, "too_short", body.Data[0].Code)
+	assert.Equal(t, "password", body.Data[1].Field)
+}
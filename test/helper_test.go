@@ -114,6 +114,29 @@ func TestCommitOrRollback(t *testing.T) {
 	assert.EqualValues(t, int64(2), count)
 }
 
+func TestAfterCommit(t *testing.T) {
+	db := setupTestDB(t)
+
+	ran := 0
+	helper.AfterCommit(db, func() { ran++ })
+	assert.Equal(t, 1, ran, "outside a transaction it runs right away")
+
+	tx := db.Begin()
+	helper.AfterCommit(tx, func() { ran++ })
+	assert.Equal(t, 1, ran)
+	helper.CommitOrRollback(tx)
+	assert.Equal(t, 2, ran)
+
+	func() {
+		tx := db.Begin()
+		defer func() { recover() }()
+		defer helper.CommitOrRollback(tx)
+		helper.AfterCommit(tx, func() { ran++ })
+		panic("error")
+	}()
+	assert.Equal(t, 2, ran, "a rolled back transaction does not run it")
+}
+
 func TestValidateEmail(t *testing.T) {
 
 	// valid email
@@ -2,6 +2,8 @@ package test
 
 import (
 	"auth-api-jwt/middleware"
+	"auth-api-jwt/repository"
+	"context"
 	"net/http/httptest"
 	"os"
 	"testing"
@@ -143,3 +145,185 @@ func TestJWTMiddleware_MissingRole(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 401, resp.StatusCode)
 }
+
+func TestJWTMiddleware_RevokedToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "testsecret")
+
+	claims := jwt.MapClaims{
+		"user_id": "12345",
+		"role":    "user",
+		"jti":     "revoked-jti",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, _ := token.SignedString([]byte("testsecret"))
+
+	store := repository.NewMemoryRevocationStore()
+
+	app := fiber.New()
+	app.Use(middleware.JWTMiddleware(middleware.JWTConfig{RevocationStore: store}))
+	app.Get("/protected", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	store.Revoke(context.Background(), "revoked-jti", time.Now().Add(time.Hour))
+
+	req = httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+func TestJWTMiddleware_MissingExpiry(t *testing.T) {
+	os.Setenv("JWT_SECRET", "testsecret")
+
+	claims := jwt.MapClaims{
+		"user_id": "12345",
+		"role":    "user",
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, _ := token.SignedString([]byte("testsecret"))
+
+	app := fiber.New()
+	app.Use(middleware.JWTMiddleware())
+	app.Get("/protected", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+func TestJWTMiddleware_RequireVerifiedEmail(t *testing.T) {
+	os.Setenv("JWT_SECRET", "testsecret")
+
+	app := fiber.New()
+	app.Use(middleware.JWTMiddleware(middleware.JWTConfig{RequireVerifiedEmail: true}))
+	app.Get("/protected", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	for verified, status := range map[bool]int{true: 200, false: 403} {
+		claims := jwt.MapClaims{
+			"user_id":        "12345",
+			"role":           "user",
+			"email_verified": verified,
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+		signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("testsecret"))
+
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+	}
+}
+
+func TestJWTMiddleware_UserRevokedAfterIssue(t *testing.T) {
+	os.Setenv("JWT_SECRET", "testsecret")
+
+	claims := jwt.MapClaims{
+		"user_id": "revoked-user",
+		"role":    "user",
+		"iat":     time.Now().Add(-time.Minute).Unix(),
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("testsecret"))
+
+	store := repository.NewMemoryRevocationStore()
+	store.RevokeUser(context.Background(), "revoked-user", time.Now(), time.Now().Add(time.Hour))
+
+	app := fiber.New()
+	app.Use(middleware.JWTMiddleware(middleware.JWTConfig{RevocationStore: store}))
+	app.Get("/protected", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+func TestJWTMiddleware_RestrictedToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "testsecret")
+
+	claims := jwt.MapClaims{
+		"user_id":       "12345",
+		"role":          "admin",
+		"restricted_to": "mfa_enrollment",
+		"exp":           time.Now().Add(time.Hour).Unix(),
+	}
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("testsecret"))
+
+	app := fiber.New()
+	app.Get("/enroll", middleware.JWTMiddleware(middleware.JWTConfig{AllowedRestrictions: []string{"mfa_enrollment"}}), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+	app.Get("/protected", middleware.JWTMiddleware(), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	for path, status := range map[string]int{"/enroll": 200, "/protected": 403} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+	}
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	os.Setenv("JWT_SECRET", "testsecret")
+
+	app := fiber.New()
+	app.Delete("/sensitive", middleware.JWTMiddleware(), middleware.RequireRecentAuth(5*time.Minute), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	sign := func(authTime *time.Time) string {
+		claims := jwt.MapClaims{
+			"user_id": "12345",
+			"role":    "user",
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		}
+		if authTime != nil {
+			claims["auth_time"] = authTime.Unix()
+		}
+		signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("testsecret"))
+		return signed
+	}
+
+	recent := time.Now().Add(-time.Minute)
+	stale := time.Now().Add(-time.Hour)
+
+	for name, token := range map[string]string{"recent": sign(&recent), "stale": sign(&stale), "missing": sign(nil)} {
+		req := httptest.NewRequest("DELETE", "/sensitive", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		if name == "recent" {
+			assert.Equal(t, 200, resp.StatusCode)
+			continue
+		}
+
+		assert.Equal(t, 401, resp.StatusCode, name)
+		assert.Contains(t, resp.Header.Get("WWW-Authenticate"), `error="insufficient_user_authentication"`)
+		assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "max_age=300")
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newThrottledAuthService(t *testing.T, email string) (service.AuthService, service.UserService, domain.User) {
+	db := setupTestDB(t)
+	userRepository := repository.NewUserRepository(db)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(db)
+
+	var user domain.User
+	if email != "" {
+		hashed, _ := utils.HashPassword("mypassword")
+		saved, err := userRepository.Save(context.Background(), db, domain.User{Id: uuid.New(), Email: email, PasswordHash: hashed, FullName: "Throttled", Role: "user"})
+		assert.NoError(t, err)
+		user = saved
+	}
+
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+	userService := service.NewUserService(userRepository, loginAttemptRepository, repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validator.New())
+
+	return authService, userService, user
+}
+
+func TestLogin_LocksAccountAndAdminUnlocks(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("LOGIN_MAX_FAILURES", "2")
+	authService, userService, user := newThrottledAuthService(t, "lockout@example.com")
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "wrong", ClientIp: "10.0.0.1"})
+		assert.EqualError(t, err, "invalid email or password")
+	}
+
+	// The correct password does not help while the account is locked.
+	_, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "mypassword", ClientIp: "10.0.0.2"})
+	var locked exception.AccountLockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.InDelta(t, (15 * time.Minute).Seconds(), locked.RetryAfter.Seconds(), 5)
+
+	assert.NoError(t, userService.Unlock(ctx, user.Id.String()))
+
+	tokens, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "mypassword", ClientIp: "10.0.0.2"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.Token)
+}
+
+func TestLogin_ProgressiveDelay(t *testing.T) {
+	authService, _, _ := newThrottledAuthService(t, "")
+	ctx := context.Background()
+
+	// Unknown emails are throttled exactly like existing ones.
+	for i := 0; i < 3; i++ {
+		_, err := authService.Login(ctx, web.AuthLoginRequest{Email: "nobody-delay@example.com", Password: "wrong"})
+		assert.EqualError(t, err, "invalid email or password")
+	}
+
+	_, err := authService.Login(ctx, web.AuthLoginRequest{Email: "nobody-delay@example.com", Password: "wrong"})
+	var tooMany exception.TooManyRequestsError
+	assert.ErrorAs(t, err, &tooMany)
+	assert.LessOrEqual(t, tooMany.RetryAfter, time.Second)
+}
+
+func TestLogin_ThrottlesClientIp(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES_PER_IP", "2")
+	authService, _, _ := newThrottledAuthService(t, "")
+	ctx := context.Background()
+
+	for _, email := range []string{"spray-1@example.com", "spray-2@example.com"} {
+		_, err := authService.Login(ctx, web.AuthLoginRequest{Email: email, Password: "wrong", ClientIp: "10.9.9.9"})
+		assert.EqualError(t, err, "invalid email or password")
+	}
+
+	_, err := authService.Login(ctx, web.AuthLoginRequest{Email: "spray-3@example.com", Password: "wrong", ClientIp: "10.9.9.9"})
+	var tooMany exception.TooManyRequestsError
+	assert.ErrorAs(t, err, &tooMany)
+}
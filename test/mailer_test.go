@@ -0,0 +1,91 @@
+package test
+
+import (
+	"auth-api-jwt/mailer"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MailerMock struct {
+	mu       sync.Mutex
+	Messages []mailer.Message
+}
+
+func (m *MailerMock) Send(ctx context.Context, message mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Messages = append(m.Messages, message)
+	return nil
+}
+
+func (m *MailerMock) Last() mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.Messages) == 0 {
+		return mailer.Message{}
+	}
+	return m.Messages[len(m.Messages)-1]
+}
+
+// tokenFromMail pulls the value of the token query parameter out of a link
+// in the message body.
+func tokenFromMail(message mailer.Message) string {
+	index := strings.Index(message.Body, "token=")
+	if index < 0 {
+		return ""
+	}
+
+	return strings.Fields(message.Body[index+len("token="):])[0]
+}
+
+func TestFileMailer_WritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	fileMailer := mailer.NewFileMailer(dir)
+
+	err := fileMailer.Send(context.Background(), mailer.Message{
+		To:      "file@example.com",
+		Subject: "Hello",
+		Body:    "Body text",
+	})
+	assert.NoError(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.Len(t, files, 1)
+
+	content, _ := os.ReadFile(files[0])
+	assert.Contains(t, string(content), "To: file@example.com")
+	assert.Contains(t, string(content), "Subject: Hello")
+	assert.Contains(t, string(content), "Body text")
+}
+
+// blockingMailer holds every message until release is closed.
+type blockingMailer struct {
+	MailerMock
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(ctx context.Context, message mailer.Message) error {
+	<-m.release
+	return m.MailerMock.Send(ctx, message)
+}
+
+func TestBackgroundMailer_DoesNotWaitForDelivery(t *testing.T) {
+	inner := &blockingMailer{release: make(chan struct{})}
+	background := mailer.NewBackgroundMailer(inner)
+	message := mailer.Message{To: "someone@example.com", Subject: "Hello", Body: "Hi"}
+
+	assert.NoError(t, background.Send(context.Background(), message))
+	assert.Empty(t, inner.Last())
+
+	close(inner.release)
+	background.Wait()
+	assert.Equal(t, message, inner.Last())
+}
@@ -0,0 +1,224 @@
+package test
+
+import (
+	"auth-api-jwt/exception"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func newTestMfaService(userRepository repository.UserRepository, db *gorm.DB) service.MfaService {
+	return service.NewMfaService(userRepository, repository.NewMfaRecoveryCodeRepository(db), repository.NewLoginAttemptRepository(db), db, validator.New())
+}
+
+// newTestAuthService wires authRepository and userRepository, which may be
+// mocks, to real repositories on db, in-memory token stores and a
+// MailerMock. Tests that share a store or need another mailer replace the
+// field afterwards.
+func newTestAuthService(t *testing.T, db *gorm.DB, authRepository repository.AuthRepository, userRepository repository.UserRepository) *service.AuthServiceImpl {
+	t.Helper()
+
+	return service.NewAuthService(authRepository, userRepository, repository.NewRefreshTokenRepository(db), repository.NewUserTokenRepository(db), repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewSessionRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), newTestMfaService(userRepository, db), nil, new(MailerMock), db, validator.New()).(*service.AuthServiceImpl)
+}
+
+// setupMfaUser stores a user with MFA confirmed and returns the TOTP
+// secret and recovery codes along with a service that uses real
+// repositories.
+func setupMfaUser(t *testing.T, email string, role string) (service.AuthService, domain.User, string, []string) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	userRepository := repository.NewUserRepository(db)
+	mfaService := newTestMfaService(userRepository, db)
+
+	hashed, err := utils.HashPassword("mypassword")
+	assert.NoError(t, err)
+
+	user, err := userRepository.Save(ctx, db, domain.User{
+		Id:           uuid.New(),
+		Email:        email,
+		PasswordHash: hashed,
+		FullName:     "Mfa User",
+		Role:         role,
+	})
+	assert.NoError(t, err)
+
+	enrollment, err := mfaService.Enroll(ctx, user.Id.String())
+	assert.NoError(t, err)
+	assert.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/")
+
+	code, _ := utils.GenerateTOTPCode(enrollment.Secret, utils.TOTPStep(time.Now()))
+	recovery, err := mfaService.Confirm(ctx, web.MfaCodeRequest{UserId: user.Id.String(), Code: code})
+	assert.NoError(t, err)
+	assert.Len(t, recovery.RecoveryCodes, 10)
+
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+	authService.MfaService = mfaService
+
+	return authService, user, enrollment.Secret, recovery.RecoveryCodes
+}
+
+func TestMfaService_ConfirmRejectsWrongCode(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	userRepository := repository.NewUserRepository(db)
+	mfaService := newTestMfaService(userRepository, db)
+
+	user, err := userRepository.Save(ctx, db, domain.User{Id: uuid.New(), Email: "mfa-wrong@example.com", PasswordHash: "hash", FullName: "Wrong", Role: "user"})
+	assert.NoError(t, err)
+
+	_, err = mfaService.Enroll(ctx, user.Id.String())
+	assert.NoError(t, err)
+
+	_, err = mfaService.Confirm(ctx, web.MfaCodeRequest{UserId: user.Id.String(), Code: "000000"})
+	assert.EqualError(t, err, "invalid mfa code")
+
+	stored, err := userRepository.FindById(ctx, db, user.Id.String())
+	assert.NoError(t, err)
+	assert.False(t, stored.MfaEnabled)
+}
+
+func TestAuthService_Login_MfaChallenge(t *testing.T) {
+	svc, _, secret, _ := setupMfaUser(t, "mfa-login@example.com", "user")
+	ctx := context.Background()
+
+	challenge, err := svc.Login(ctx, web.AuthLoginRequest{Email: "mfa-login@example.com", Password: "mypassword"})
+	assert.NoError(t, err)
+	assert.True(t, challenge.MfaRequired)
+	assert.NotEmpty(t, challenge.MfaToken)
+	assert.Empty(t, challenge.Token)
+
+	// The code from the current step was spent on enrollment.
+	replayed, _ := utils.GenerateTOTPCode(secret, utils.TOTPStep(time.Now()))
+	_, err = svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, Code: replayed})
+	assert.Error(t, err)
+
+	next, _ := utils.GenerateTOTPCode(secret, utils.TOTPStep(time.Now())+1)
+	tokens, err := svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, Code: next})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.Token)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	_, err = svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, Code: next})
+	assert.EqualError(t, err, "invalid or expired mfa token")
+}
+
+func TestAuthService_VerifyMfa_RecoveryCodeSingleUse(t *testing.T) {
+	svc, _, _, codes := setupMfaUser(t, "mfa-recovery@example.com", "user")
+	ctx := context.Background()
+
+	for i, want := range []bool{true, false} {
+		challenge, err := svc.Login(ctx, web.AuthLoginRequest{Email: "mfa-recovery@example.com", Password: "mypassword"})
+		assert.NoError(t, err)
+
+		_, err = svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, RecoveryCode: codes[0]})
+		assert.Equal(t, want, err == nil, "attempt %d", i)
+	}
+}
+
+func TestAuthService_VerifyMfa_LocksChallenge(t *testing.T) {
+	// keep the account throttle out of the way of the challenge limit
+	t.Setenv("LOGIN_FAILURE_WINDOW", "1ns")
+	svc, _, secret, _ := setupMfaUser(t, "mfa-lock@example.com", "user")
+	ctx := context.Background()
+
+	challenge, err := svc.Login(ctx, web.AuthLoginRequest{Email: "mfa-lock@example.com", Password: "mypassword"})
+	assert.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err := svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, Code: "000000"})
+		assert.EqualError(t, err, "invalid mfa code")
+	}
+
+	next, _ := utils.GenerateTOTPCode(secret, utils.TOTPStep(time.Now())+1)
+	_, err = svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, Code: next})
+	assert.EqualError(t, err, "invalid or expired mfa token")
+}
+
+func TestAuthService_VerifyMfa_CountsAgainstLoginThrottle(t *testing.T) {
+	svc, _, secret, _ := setupMfaUser(t, "mfa-throttle@example.com", "user")
+	ctx := context.Background()
+
+	challenge, err := svc.Login(ctx, web.AuthLoginRequest{Email: "mfa-throttle@example.com", Password: "mypassword"})
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, Code: "000000"})
+		assert.EqualError(t, err, "invalid mfa code")
+	}
+
+	// the right password alone does not clear the failures, so a fresh
+	// challenge does not buy more guesses
+	challenge, err = svc.Login(ctx, web.AuthLoginRequest{Email: "mfa-throttle@example.com", Password: "mypassword"})
+	assert.NoError(t, err)
+	_, err = svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, Code: "000000"})
+	assert.EqualError(t, err, "invalid mfa code")
+
+	code, _ := utils.GenerateTOTPCode(secret, utils.TOTPStep(time.Now()))
+	_, err = svc.VerifyMfa(ctx, web.AuthMfaVerifyRequest{MfaToken: challenge.MfaToken, Code: code})
+	assert.IsType(t, exception.TooManyRequestsError{}, err)
+}
+
+func TestAuthService_Login_MfaEnrollmentRequired(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("MFA_REQUIRED_ROLES", "admin")
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+	userRepository := repository.NewUserRepository(db)
+
+	hashed, _ := utils.HashPassword("mypassword")
+	_, err := userRepository.Save(ctx, db, domain.User{Id: uuid.New(), Email: "mfa-admin@example.com", PasswordHash: hashed, FullName: "Admin", Role: "admin"})
+	assert.NoError(t, err)
+
+	svc := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+
+	tokens, err := svc.Login(ctx, web.AuthLoginRequest{Email: "mfa-admin@example.com", Password: "mypassword"})
+	assert.NoError(t, err)
+	assert.True(t, tokens.MfaEnrollmentRequired)
+	assert.Empty(t, tokens.RefreshToken)
+
+	claims, err := utils.ParseJWT(tokens.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.RestrictionMfaEnrollment, claims["restricted_to"])
+}
+
+func TestMfaService_DisableBlockedForRequiredRole(t *testing.T) {
+	t.Setenv("MFA_REQUIRED_ROLES", "admin")
+
+	_, user, _, codes := setupMfaUser(t, "mfa-keep@example.com", "admin")
+	db := setupTestDB(t)
+	mfaService := newTestMfaService(repository.NewUserRepository(db), db)
+
+	err := mfaService.Disable(context.Background(), web.MfaCodeRequest{UserId: user.Id.String(), RecoveryCode: codes[0]})
+	assert.EqualError(t, err, "mfa is required for your role")
+}
+
+func TestMfaService_DisableCountsAgainstLoginThrottle(t *testing.T) {
+	_, user, secret, _ := setupMfaUser(t, "mfa-disable-throttle@example.com", "user")
+	db := setupTestDB(t)
+	mfaService := newTestMfaService(repository.NewUserRepository(db), db)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := mfaService.RegenerateRecoveryCodes(ctx, web.MfaCodeRequest{UserId: user.Id.String(), Code: "000000"})
+		assert.EqualError(t, err, "invalid mfa code")
+	}
+
+	// the next guess has to wait, even with the right code
+	code, _ := utils.GenerateTOTPCode(secret, utils.TOTPStep(time.Now()))
+	err := mfaService.Disable(ctx, web.MfaCodeRequest{UserId: user.Id.String(), Code: code})
+	assert.IsType(t, exception.TooManyRequestsError{}, err)
+
+	disabled, err := repository.NewUserRepository(db).FindById(ctx, db, user.Id.String())
+	assert.NoError(t, err)
+	assert.True(t, disabled.MfaEnabled)
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/exception"
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/routes"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newMachineClient(t *testing.T, oauthService service.OAuthService, scopes ...string) web.OAuthClientResponse {
+	client, err := oauthService.CreateClient(context.Background(), web.OAuthClientCreateRequest{Name: "Nightly job", Confidential: true, Scopes: scopes})
+	assert.NoError(t, err)
+	assert.True(t, client.Confidential)
+	assert.NotEmpty(t, client.ClientSecret)
+
+	return client
+}
+
+func TestOAuth_ClientCredentials(t *testing.T) {
+	oauthService, publicClient, _ := newOAuthTestService(t)
+	client := newMachineClient(t, oauthService, "users:read", "users:write")
+	ctx := context.Background()
+
+	tokens, err := oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "client_credentials", ClientId: client.ClientId, ClientSecret: client.ClientSecret, Scope: "users:read"})
+	assert.NoError(t, err)
+	assert.Equal(t, "users:read", tokens.Scope)
+	assert.Empty(t, tokens.RefreshToken)
+
+	claims, err := utils.ParseJWT(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, client.ClientId, claims["sub"])
+	assert.Equal(t, client.ClientId, claims["client_id"])
+	assert.NotContains(t, claims, "user_id")
+	assert.NotContains(t, claims, "role")
+
+	cases := []struct {
+		request web.OAuthTokenRequest
+		code    string
+	}{
+		{web.OAuthTokenRequest{ClientId: client.ClientId, ClientSecret: "wrong"}, "invalid_client"},
+		{web.OAuthTokenRequest{ClientId: client.ClientId, ClientSecret: client.ClientSecret, Scope: "admin"}, "invalid_scope"},
+		{web.OAuthTokenRequest{ClientId: publicClient.ClientId}, "unauthorized_client"},
+	}
+	for _, tc := range cases {
+		tc.request.GrantType = "client_credentials"
+		_, err := oauthService.Token(ctx, tc.request)
+		assert.Equal(t, tc.code, err.(exception.OAuthError).Code)
+	}
+}
+
+func TestOAuth_ClientCredentialsWithBasicAuth(t *testing.T) {
+	oauthService, _, _ := newOAuthTestService(t)
+	client := newMachineClient(t, oauthService, "users:read")
+	app := fiber.New(fiber.Config{ErrorHandler: exception.NewErrorHandler})
+	routes.NewOAuthRoutes(app, controller.NewOAuthController(oauthService), middleware.JWTConfig{})
+
+	token := func(secret string) int {
+		req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(client.ClientId+":"+secret)))
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		if resp.StatusCode == 200 {
+			assert.Equal(t, "users:read", body["scope"])
+		}
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, 200, token(client.ClientSecret))
+	assert.Equal(t, 401, token("wrong"))
+}
+
+func TestUserRoutes_ClientScopes(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	userService := new(UserServiceMock)
+	userService.On("FindAll", mock.Anything).Return([]domain.User{}, nil)
+	userService.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	userService.On("FindById", mock.Anything, mock.Anything).Return(domain.User{}, nil)
+
+	app := fiber.New()
+	routes.NewUserRouter(app, controller.NewUserController(userService, nil), controller.NewMfaController(nil), controller.NewSessionController(nil), controller.NewUserImportController(nil), middleware.JWTConfig{})
+
+	request := func(method string, path string, scope string) int {
+		token, err := utils.GenerateClientJWT("job", scope, time.Minute)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	target := "/users/" + uuid.NewString()
+
+	assert.Equal(t, 200, request("GET", "/users", "users:read"))
+	assert.Equal(t, 403, request("DELETE", target, "users:read"))
+	assert.Equal(t, 200, request("DELETE", target, "users:write"))
+	assert.Equal(t, 403, request("GET", "/users", "sessions:read"))
+
+	// there is no user behind a client token
+	assert.Equal(t, 403, request("GET", "/users/me", "users:write"))
+	assert.Equal(t, 403, request("POST", "/users/me/password", "users:write"))
+
+	userToken, _ := utils.GenerateJWT(uuid.NewString(), "user")
+	req := httptest.NewRequest("GET", "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestUserRoutes_RejectDelegatedAdminToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	userService := new(UserServiceMock)
+	userService.On("FindAll", mock.Anything).Return([]domain.User{}, nil)
+	userService.On("Delete", mock.Anything, mock.Anything).Return(nil)
+
+	app := fiber.New()
+	routes.NewUserRouter(app, controller.NewUserController(userService, nil), controller.NewMfaController(nil), controller.NewSessionController(nil), controller.NewUserImportController(nil), middleware.JWTConfig{})
+
+	// an admin who signed in to a third-party app through /oauth/authorize
+	delegated, err := utils.GenerateJWT(uuid.NewString(), "admin", jwt.MapClaims{"client_id": "spa", "aud": "spa", "scope": "profile", "auth_time": time.Now().Unix()})
+	assert.NoError(t, err)
+
+	for _, method := range []string{"GET", "DELETE"} {
+		path := "/users"
+		if method == "DELETE" {
+			path += "/" + uuid.NewString()
+		}
+
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+delegated)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 403, resp.StatusCode, method)
+	}
+	userService.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/exception"
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/routes"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+func newDeviceClient(t *testing.T, oauthService service.OAuthService) web.OAuthClientResponse {
+	client, err := oauthService.CreateClient(context.Background(), web.OAuthClientCreateRequest{Name: "Deploy CLI", Scopes: []string{"openid", "profile"}})
+	assert.NoError(t, err)
+	assert.Empty(t, client.RedirectUris)
+
+	return client
+}
+
+func TestOAuth_DeviceFlow(t *testing.T) {
+	useTestSigningKey(t)
+	t.Setenv("OAUTH_DEVICE_POLL_INTERVAL", "1h")
+	oauthService, _, login := newOAuthTestService(t)
+	client := newDeviceClient(t, oauthService)
+	ctx := context.Background()
+
+	authorization, err := oauthService.DeviceAuthorization(ctx, web.OAuthDeviceAuthorizationRequest{ClientId: client.ClientId})
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[B-Z]{4}-[B-Z]{4}$`, authorization.UserCode)
+	assert.Equal(t, 3600, authorization.Interval)
+	assert.Contains(t, authorization.VerificationUriComplete, "/device?user_code=")
+
+	poll := web.OAuthTokenRequest{GrantType: deviceGrantType, ClientId: client.ClientId, DeviceCode: authorization.DeviceCode}
+	_, err = oauthService.Token(ctx, poll)
+	assert.Equal(t, "authorization_pending", err.(exception.OAuthError).Code)
+	_, err = oauthService.Token(ctx, poll)
+	assert.Equal(t, "slow_down", err.(exception.OAuthError).Code)
+
+	// the code is forgiving about how it is typed in
+	userCode := strings.ToLower(strings.ReplaceAll(authorization.UserCode, "-", " "))
+	consent, err := oauthService.ValidateUserCode(ctx, web.OAuthDeviceVerifyRequest{UserCode: userCode})
+	assert.NoError(t, err)
+	assert.Equal(t, "Deploy CLI", consent.ClientName)
+
+	verify := web.OAuthDeviceVerifyRequest{UserCode: userCode, Email: login.Email, Password: "wrong", Decision: "allow"}
+	assert.EqualError(t, oauthService.VerifyDevice(ctx, verify), "invalid email or password")
+
+	verify.Password = login.Password
+	assert.NoError(t, oauthService.VerifyDevice(ctx, verify))
+	assert.EqualError(t, oauthService.VerifyDevice(ctx, verify), "invalid or expired code")
+
+	tokens, err := oauthService.Token(ctx, poll)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.NotEmpty(t, tokens.IdToken)
+
+	claims, err := utils.ParseJWT(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims["user_id"])
+	assert.Equal(t, "user", claims["role"])
+	assert.Equal(t, client.ClientId, claims["client_id"])
+
+	_, err = oauthService.Token(ctx, poll)
+	assert.Equal(t, "invalid_grant", err.(exception.OAuthError).Code)
+}
+
+func TestOAuth_DeviceDeniedAndExpired(t *testing.T) {
+	useTestSigningKey(t)
+	oauthService, _, login := newOAuthTestService(t)
+	client := newDeviceClient(t, oauthService)
+	ctx := context.Background()
+
+	authorization, err := oauthService.DeviceAuthorization(ctx, web.OAuthDeviceAuthorizationRequest{ClientId: client.ClientId})
+	assert.NoError(t, err)
+
+	// knowing the code is not enough to turn the device away
+	deny := web.OAuthDeviceVerifyRequest{UserCode: authorization.UserCode, Decision: "deny"}
+	assert.EqualError(t, oauthService.VerifyDevice(ctx, deny), "invalid email or password")
+
+	deny.Email, deny.Password = login.Email, login.Password
+	assert.NoError(t, oauthService.VerifyDevice(ctx, deny))
+
+	_, err = oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: deviceGrantType, ClientId: client.ClientId, DeviceCode: authorization.DeviceCode})
+	assert.Equal(t, "access_denied", err.(exception.OAuthError).Code)
+
+	t.Setenv("OAUTH_DEVICE_CODE_TTL", "1ns")
+	authorization, err = oauthService.DeviceAuthorization(ctx, web.OAuthDeviceAuthorizationRequest{ClientId: client.ClientId})
+	assert.NoError(t, err)
+
+	_, err = oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: deviceGrantType, ClientId: client.ClientId, DeviceCode: authorization.DeviceCode})
+	assert.Equal(t, "expired_token", err.(exception.OAuthError).Code)
+
+	_, err = oauthService.ValidateUserCode(ctx, web.OAuthDeviceVerifyRequest{UserCode: authorization.UserCode})
+	assert.EqualError(t, err, "invalid or expired code")
+}
+
+func TestOAuth_DeviceUserCodeGuessingIsThrottled(t *testing.T) {
+	t.Setenv("DEVICE_CODE_MAX_FAILURES_PER_IP", "3")
+	t.Setenv("DEVICE_CODE_MAX_SIGNIN_FAILURES", "2")
+	useTestSigningKey(t)
+	oauthService, _, login := newOAuthTestService(t)
+	client := newDeviceClient(t, oauthService)
+	ctx := context.Background()
+
+	authorization, err := oauthService.DeviceAuthorization(ctx, web.OAuthDeviceAuthorizationRequest{ClientId: client.ClientId})
+	assert.NoError(t, err)
+
+	for _, guess := range []string{"BBBB-BBBB", "CCCC-CCCC", "DDDD-DDDD"} {
+		_, err = oauthService.ValidateUserCode(ctx, web.OAuthDeviceVerifyRequest{UserCode: guess, ClientIp: "203.0.113.7"})
+		assert.ErrorIs(t, err, service.ErrInvalidUserCode)
+	}
+
+	// even the right code is refused once the address is locked out
+	_, err = oauthService.ValidateUserCode(ctx, web.OAuthDeviceVerifyRequest{UserCode: authorization.UserCode, ClientIp: "203.0.113.7"})
+	assert.IsType(t, exception.TooManyRequestsError{}, err)
+	err = oauthService.VerifyDevice(ctx, web.OAuthDeviceVerifyRequest{UserCode: authorization.UserCode, ClientIp: "203.0.113.7", Email: login.Email, Password: login.Password, Decision: "allow"})
+	assert.IsType(t, exception.TooManyRequestsError{}, err)
+
+	// wrong credentials lock the device request itself, whoever sends them
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		err = oauthService.VerifyDevice(ctx, web.OAuthDeviceVerifyRequest{UserCode: authorization.UserCode, Email: email, Password: "wrong", Decision: "allow"})
+		assert.EqualError(t, err, "invalid email or password")
+	}
+	err = oauthService.VerifyDevice(ctx, web.OAuthDeviceVerifyRequest{UserCode: authorization.UserCode, Email: login.Email, Password: login.Password, Decision: "allow"})
+	assert.IsType(t, exception.TooManyRequestsError{}, err)
+}
+
+func TestOAuthController_DevicePage(t *testing.T) {
+	useTestSigningKey(t)
+	oauthService, _, login := newOAuthTestService(t)
+	client := newDeviceClient(t, oauthService)
+	app := fiber.New(fiber.Config{ErrorHandler: exception.NewErrorHandler})
+	routes.NewOAuthRoutes(app, controller.NewOAuthController(oauthService), middleware.JWTConfig{})
+
+	req := httptest.NewRequest("POST", "/oauth/device_authorization", strings.NewReader(url.Values{"client_id": {client.ClientId}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	authorization, err := oauthService.DeviceAuthorization(context.Background(), web.OAuthDeviceAuthorizationRequest{ClientId: client.ClientId})
+	assert.NoError(t, err)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/device?user_code="+authorization.UserCode, nil))
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "DENY", resp.Header.Get("X-Frame-Options"))
+	assert.Contains(t, string(body), "Connect Deploy CLI")
+
+	// an unknown code shows the error without a sign-in form
+	form := url.Values{"user_code": {"BBBB-BBBB"}, "email": {login.Email}, "password": {login.Password}, "decision": {"deny"}}
+	req = httptest.NewRequest("POST", "/device", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Contains(t, string(body), "invalid or expired code")
+
+	form = url.Values{"user_code": {authorization.UserCode}, "email": {login.Email}, "password": {login.Password}, "decision": {"allow"}}
+	req = httptest.NewRequest("POST", "/device", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(body), "Your device is connected")
+}
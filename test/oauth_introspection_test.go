@@ -0,0 +1,169 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/exception"
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/routes"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func oauthLogin(t *testing.T, oauthService service.OAuthService, client web.OAuthClientResponse, request web.OAuthAuthorizeRequest) web.OAuthTokenResponse {
+	code := authorizeCode(t, oauthService, request)
+	tokens, err := oauthService.Token(context.Background(), web.OAuthTokenRequest{GrantType: "authorization_code", ClientId: client.ClientId, Code: code, RedirectUri: request.RedirectUri, CodeVerifier: oauthTestVerifier})
+	assert.NoError(t, err)
+
+	return tokens
+}
+
+func TestOAuth_Introspect(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+	resourceServer := newMachineClient(t, oauthService, "users:read")
+	tokens := oauthLogin(t, oauthService, client, request)
+	ctx := context.Background()
+
+	introspect := func(token string) web.OAuthIntrospectResponse {
+		response, err := oauthService.Introspect(ctx, web.OAuthIntrospectRequest{Token: token, ClientId: resourceServer.ClientId, ClientSecret: resourceServer.ClientSecret})
+		assert.NoError(t, err)
+		return response
+	}
+
+	access := introspect(tokens.AccessToken)
+	assert.True(t, access.Active)
+	assert.Equal(t, "access_token", access.TokenType)
+	assert.Equal(t, "user", access.Role)
+	assert.Equal(t, "profile email", access.Scope)
+	assert.Equal(t, client.ClientId, access.ClientId)
+	assert.NotEmpty(t, access.Sub)
+	assert.NotZero(t, access.Exp)
+
+	refresh := introspect(tokens.RefreshToken)
+	assert.True(t, refresh.Active)
+	assert.Equal(t, "refresh_token", refresh.TokenType)
+	assert.Equal(t, access.Sub, refresh.Sub)
+
+	machine, err := oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "client_credentials", ClientId: resourceServer.ClientId, ClientSecret: resourceServer.ClientSecret})
+	assert.NoError(t, err)
+	own := introspect(machine.AccessToken)
+	assert.True(t, own.Active)
+	assert.Equal(t, resourceServer.ClientId, own.Sub)
+	assert.Empty(t, own.Role)
+
+	assert.Equal(t, web.OAuthIntrospectResponse{}, introspect("not-a-token"))
+	assert.Equal(t, web.OAuthIntrospectResponse{}, introspect("a.b.c"))
+
+	// only clients that can authenticate may ask
+	_, err = oauthService.Introspect(ctx, web.OAuthIntrospectRequest{Token: tokens.AccessToken, ClientId: client.ClientId})
+	assert.Equal(t, "invalid_client", err.(exception.OAuthError).Code)
+	_, err = oauthService.Introspect(ctx, web.OAuthIntrospectRequest{Token: tokens.AccessToken, ClientId: resourceServer.ClientId, ClientSecret: "wrong"})
+	assert.Equal(t, "invalid_client", err.(exception.OAuthError).Code)
+}
+
+func TestOAuth_IntrospectRejectsTokensTheApiWouldReject(t *testing.T) {
+	oauthService, _, _ := newOAuthTestService(t)
+	resourceServer := newMachineClient(t, oauthService, "users:read")
+	ctx := context.Background()
+
+	isActive := func(token string) bool {
+		response, err := oauthService.Introspect(ctx, web.OAuthIntrospectRequest{Token: token, ClientId: resourceServer.ClientId, ClientSecret: resourceServer.ClientSecret})
+		assert.NoError(t, err)
+		return response.Active
+	}
+
+	userId := uuid.NewString()
+	for _, restriction := range []string{utils.RestrictionMfaEnrollment, utils.RestrictionPasswordChange} {
+		restricted, err := utils.GenerateJWT(userId, "user", jwt.MapClaims{"email_verified": true, "restricted_to": restriction})
+		assert.NoError(t, err)
+		assert.False(t, isActive(restricted), restriction)
+	}
+
+	unverified, err := utils.GenerateJWT(userId, "user", jwt.MapClaims{"email_verified": false})
+	assert.NoError(t, err)
+	assert.True(t, isActive(unverified))
+
+	oauthService.(*service.OAuthServiceImpl).JWTConfig.RequireVerifiedEmail = true
+	assert.False(t, isActive(unverified))
+}
+
+func TestOAuth_Revoke(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+	resourceServer := newMachineClient(t, oauthService, "users:read")
+	ctx := context.Background()
+
+	isActive := func(token string) bool {
+		response, err := oauthService.Introspect(ctx, web.OAuthIntrospectRequest{Token: token, ClientId: resourceServer.ClientId, ClientSecret: resourceServer.ClientSecret})
+		assert.NoError(t, err)
+		return response.Active
+	}
+
+	tokens := oauthLogin(t, oauthService, client, request)
+
+	// a client can only revoke its own tokens
+	err := oauthService.Revoke(ctx, web.OAuthRevokeRequest{Token: tokens.AccessToken, ClientId: resourceServer.ClientId, ClientSecret: resourceServer.ClientSecret})
+	assert.Equal(t, "unauthorized_client", err.(exception.OAuthError).Code)
+
+	assert.NoError(t, oauthService.Revoke(ctx, web.OAuthRevokeRequest{Token: tokens.AccessToken, ClientId: client.ClientId}))
+	assert.False(t, isActive(tokens.AccessToken))
+	assert.True(t, isActive(tokens.RefreshToken))
+
+	// revoking the refresh token ends the session
+	refreshed, err := oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "refresh_token", ClientId: client.ClientId, RefreshToken: tokens.RefreshToken})
+	assert.NoError(t, err)
+	assert.NoError(t, oauthService.Revoke(ctx, web.OAuthRevokeRequest{Token: refreshed.RefreshToken, TokenTypeHint: "refresh_token", ClientId: client.ClientId}))
+	assert.False(t, isActive(refreshed.RefreshToken))
+	assert.False(t, isActive(refreshed.AccessToken))
+
+	// unknown tokens are not an error
+	assert.NoError(t, oauthService.Revoke(ctx, web.OAuthRevokeRequest{Token: "not-a-token", ClientId: client.ClientId}))
+}
+
+func TestOAuthController_IntrospectAndRevoke(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+	resourceServer := newMachineClient(t, oauthService, "users:read")
+	tokens := oauthLogin(t, oauthService, client, request)
+	app := fiber.New(fiber.Config{ErrorHandler: exception.NewErrorHandler})
+	routes.NewOAuthRoutes(app, controller.NewOAuthController(oauthService), middleware.JWTConfig{})
+
+	post := func(path string, form url.Values, secret string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if secret != "" {
+			req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(resourceServer.ClientId+":"+secret)))
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	status, body := post("/oauth/introspect", url.Values{"token": {tokens.AccessToken}}, resourceServer.ClientSecret)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, true, body["active"])
+	assert.Equal(t, "user", body["role"])
+
+	status, body = post("/oauth/introspect", url.Values{"token": {tokens.AccessToken}}, "wrong")
+	assert.Equal(t, 401, status)
+	assert.Equal(t, "invalid_client", body["error"])
+
+	status, _ = post("/oauth/revoke", url.Values{"token": {tokens.RefreshToken}, "client_id": {client.ClientId}}, "")
+	assert.Equal(t, 200, status)
+
+	_, body = post("/oauth/introspect", url.Values{"token": {tokens.RefreshToken}}, resourceServer.ClientSecret)
+	assert.Equal(t, map[string]interface{}{"active": false}, body)
+}
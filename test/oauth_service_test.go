@@ -0,0 +1,227 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/exception"
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/routes"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const oauthTestVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func oauthTestChallenge() string {
+	sum := sha256.Sum256([]byte(oauthTestVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newOAuthTestService(t *testing.T) (service.OAuthService, web.OAuthClientResponse, web.OAuthAuthorizeRequest) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	db := setupTestDB(t)
+	userRepository := repository.NewUserRepository(db)
+	refreshTokenRepository := repository.NewRefreshTokenRepository(db)
+	sessionRepository := repository.NewSessionRepository(db)
+	revocationStore := repository.NewMemoryRevocationStore()
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+	authService.RevocationStore = revocationStore
+	oauthService := service.NewOAuthService(repository.NewOAuthClientRepository(db), repository.NewOAuthAuthorizationCodeRepository(db), repository.NewOAuthDeviceCodeRepository(db), userRepository, refreshTokenRepository, sessionRepository, repository.NewLoginAttemptRepository(db), revocationStore, authService, service.NewUserService(userRepository, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), refreshTokenRepository, sessionRepository, repository.NewUserTokenRepository(db), revocationStore, repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validator.New()), middleware.JWTConfig{RevocationStore: revocationStore}, db, validator.New())
+
+	hashed, _ := utils.HashPassword("Tr0ub4dor-cobalt-meadow")
+	user, err := userRepository.Save(context.Background(), db, domain.User{Id: uuid.New(), Email: uuid.NewString() + "@example.com", PasswordHash: hashed, FullName: "OAuth", Role: "user", IsVerified: true})
+	assert.NoError(t, err)
+
+	client, err := oauthService.CreateClient(context.Background(), web.OAuthClientCreateRequest{
+		Name:         "Example SPA",
+		RedirectUris: []string{"https://app.example.com/callback"},
+		Scopes:       []string{"profile", "email"},
+	})
+	assert.NoError(t, err)
+
+	return oauthService, client, web.OAuthAuthorizeRequest{
+		ResponseType:        "code",
+		ClientId:            client.ClientId,
+		RedirectUri:         "https://app.example.com/callback",
+		State:               "xyz",
+		CodeChallenge:       oauthTestChallenge(),
+		CodeChallengeMethod: "S256",
+		Email:               user.Email,
+		Password:            "Tr0ub4dor-cobalt-meadow",
+		Decision:            "allow",
+	}
+}
+
+func authorizeCode(t *testing.T, oauthService service.OAuthService, request web.OAuthAuthorizeRequest) string {
+	location, err := oauthService.Authorize(context.Background(), request)
+	assert.NoError(t, err)
+
+	redirect, err := url.Parse(location)
+	assert.NoError(t, err)
+	assert.Equal(t, "app.example.com", redirect.Host)
+	assert.Equal(t, request.State, redirect.Query().Get("state"))
+
+	return redirect.Query().Get("code")
+}
+
+func TestOAuth_AuthorizationCodeFlow(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+	ctx := context.Background()
+
+	code := authorizeCode(t, oauthService, request)
+	tokens, err := oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "authorization_code", ClientId: client.ClientId, Code: code, RedirectUri: request.RedirectUri, CodeVerifier: oauthTestVerifier})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, "profile email", tokens.Scope)
+
+	claims, err := utils.ParseJWT(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, client.ClientId, claims["client_id"])
+	assert.Equal(t, "profile email", claims["scope"])
+	assert.NotEmpty(t, claims["user_id"])
+
+	refreshed, err := oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "refresh_token", ClientId: client.ClientId, RefreshToken: tokens.RefreshToken})
+	assert.NoError(t, err)
+	claims, err = utils.ParseJWT(refreshed.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, client.ClientId, claims["client_id"])
+
+	// a refresh token only works for the client it was issued to
+	_, err = oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "refresh_token", ClientId: "other", RefreshToken: refreshed.RefreshToken})
+	assert.Equal(t, "invalid_client", err.(exception.OAuthError).Code)
+}
+
+func TestOAuth_ClientRefreshTokensStayWithClient(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+	impl := oauthService.(*service.OAuthServiceImpl)
+	ctx := context.Background()
+
+	code := authorizeCode(t, oauthService, request)
+	tokens, err := oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "authorization_code", ClientId: client.ClientId, Code: code, RedirectUri: request.RedirectUri, CodeVerifier: oauthTestVerifier})
+	assert.NoError(t, err)
+
+	// the first-party endpoint does not hand out unscoped tokens for it
+	_, err = impl.AuthService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.EqualError(t, err, "invalid refresh token")
+
+	_, err = middleware.VerifyToken(ctx, impl.JWTConfig, tokens.AccessToken)
+	assert.NoError(t, err)
+
+	// deleting the client ends the sessions users started through it
+	assert.NoError(t, oauthService.DeleteClient(ctx, client.ClientId))
+
+	_, err = middleware.VerifyToken(ctx, impl.JWTConfig, tokens.AccessToken)
+	assert.Error(t, err)
+
+	_, err = impl.AuthService.RefreshForClient(ctx, client.ClientId, web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.EqualError(t, err, "invalid refresh token")
+}
+
+func TestOAuth_RedirectUriMustMatchExactly(t *testing.T) {
+	oauthService, _, request := newOAuthTestService(t)
+
+	for _, redirectUri := range []string{"https://app.example.com/callback/", "https://app.example.com/callback?next=/", "https://evil.example.com/callback", ""} {
+		request.RedirectUri = redirectUri
+		_, err := oauthService.ValidateAuthorization(context.Background(), request)
+
+		oauthError, ok := err.(exception.OAuthError)
+		assert.True(t, ok, redirectUri)
+		assert.Equal(t, "invalid_request", oauthError.Code)
+		assert.Empty(t, oauthError.RedirectUri, "must not redirect to an unregistered uri")
+	}
+}
+
+func TestOAuth_PkceIsRequired(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+	ctx := context.Background()
+
+	plain := request
+	plain.CodeChallengeMethod = "plain"
+	_, err := oauthService.ValidateAuthorization(ctx, plain)
+	assert.Equal(t, exception.OAuthError{Code: "invalid_request", Description: "a PKCE code_challenge with code_challenge_method S256 is required", RedirectUri: request.RedirectUri}, err)
+
+	code := authorizeCode(t, oauthService, request)
+	_, err = oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "authorization_code", ClientId: client.ClientId, Code: code, RedirectUri: request.RedirectUri, CodeVerifier: strings.Repeat("a", 43)})
+	assert.Equal(t, "invalid_grant", err.(exception.OAuthError).Code)
+}
+
+func TestOAuth_CodeReuseRevokesTokens(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+	ctx := context.Background()
+
+	code := authorizeCode(t, oauthService, request)
+	exchange := web.OAuthTokenRequest{GrantType: "authorization_code", ClientId: client.ClientId, Code: code, RedirectUri: request.RedirectUri, CodeVerifier: oauthTestVerifier}
+
+	tokens, err := oauthService.Token(ctx, exchange)
+	assert.NoError(t, err)
+
+	_, err = oauthService.Token(ctx, exchange)
+	assert.Equal(t, "invalid_grant", err.(exception.OAuthError).Code)
+
+	_, err = oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "refresh_token", ClientId: client.ClientId, RefreshToken: tokens.RefreshToken})
+	assert.Error(t, err)
+}
+
+func TestOAuth_DenyAndWrongPassword(t *testing.T) {
+	oauthService, _, request := newOAuthTestService(t)
+	ctx := context.Background()
+
+	denied := request
+	denied.Decision = "deny"
+	_, err := oauthService.Authorize(ctx, denied)
+	assert.Equal(t, "access_denied", err.(exception.OAuthError).Code)
+	assert.Equal(t, request.RedirectUri, err.(exception.OAuthError).RedirectUri)
+
+	request.Password = "wrong"
+	_, err = oauthService.Authorize(ctx, request)
+	assert.EqualError(t, err, "invalid email or password")
+}
+
+func TestOAuthController_PageAndTokenErrors(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+	app := fiber.New(fiber.Config{ErrorHandler: exception.NewErrorHandler})
+	routes.NewOAuthRoutes(app, controller.NewOAuthController(oauthService), middleware.JWTConfig{})
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientId},
+		"redirect_uri":          {request.RedirectUri},
+		"state":                 {"xyz"},
+		"code_challenge":        {request.CodeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	resp, err := app.Test(httptest.NewRequest("GET", "/oauth/authorize?"+query.Encode(), nil))
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "DENY", resp.Header.Get("X-Frame-Options"))
+	assert.Contains(t, string(body), "Sign in to Example SPA")
+
+	form := url.Values{"grant_type": {"password"}}
+	req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	var oauthError map[string]string
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&oauthError))
+	assert.Equal(t, "unsupported_grant_type", oauthError["error"])
+}
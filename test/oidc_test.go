@@ -0,0 +1,148 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/exception"
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/routes"
+	"auth-api-jwt/utils"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// useTestSigningKey signs tokens with an asymmetric key for the rest of
+// the test, which ID tokens require.
+func useTestSigningKey(t *testing.T) {
+	key, err := utils.GenerateSigningKey("ES256")
+	assert.NoError(t, err)
+
+	utils.UseKeySet(utils.NewKeySet([]utils.SigningKey{key}))
+	t.Cleanup(func() { utils.UseKeySet(nil) })
+}
+
+func TestOIDC_IdTokenAndUserInfo(t *testing.T) {
+	t.Setenv("OIDC_ISSUER", "https://auth.example.com/")
+	useTestSigningKey(t)
+	oauthService, _, request := newOAuthTestService(t)
+	ctx := context.Background()
+
+	client, err := oauthService.CreateClient(ctx, web.OAuthClientCreateRequest{Name: "SSO app", RedirectUris: []string{request.RedirectUri}, Scopes: []string{"openid", "profile", "email"}})
+	assert.NoError(t, err)
+
+	request.ClientId = client.ClientId
+	request.Scope = "openid email"
+	request.Nonce = "n-0S6_WzA2Mj"
+	code := authorizeCode(t, oauthService, request)
+
+	tokens, err := oauthService.Token(ctx, web.OAuthTokenRequest{GrantType: "authorization_code", ClientId: client.ClientId, Code: code, RedirectUri: request.RedirectUri, CodeVerifier: oauthTestVerifier})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.IdToken)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(tokens.IdToken, jwt.MapClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ES256", parsed.Method.Alg())
+
+	idToken, err := utils.ParseJWT(tokens.IdToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://auth.example.com", idToken["iss"])
+	assert.Equal(t, client.ClientId, idToken["aud"])
+	assert.Equal(t, "n-0S6_WzA2Mj", idToken["nonce"])
+	assert.Equal(t, request.Email, idToken["email"])
+	assert.Equal(t, true, idToken["email_verified"])
+	assert.NotContains(t, idToken, "name", "profile was not granted")
+
+	claims, err := utils.ParseJWT(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, idToken["sub"], claims["user_id"])
+
+	app := fiber.New(fiber.Config{ErrorHandler: exception.NewErrorHandler})
+	routes.NewOAuthRoutes(app, controller.NewOAuthController(oauthService), middleware.JWTConfig{})
+
+	userInfo := func(token string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("GET", "/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	status, body := userInfo(tokens.AccessToken)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, idToken["sub"], body["sub"])
+	assert.Equal(t, request.Email, body["email"])
+	assert.NotContains(t, body, "name")
+
+	// an id_token is not an access token
+	status, _ = userInfo(tokens.IdToken)
+	assert.Equal(t, 401, status)
+
+	// neither is a token without the openid scope
+	plain, _ := utils.GenerateJWT(claims["user_id"].(string), "user")
+	status, body = userInfo(plain)
+	assert.Equal(t, 403, status)
+	assert.Equal(t, "insufficient_scope", body["error"])
+}
+
+func TestOIDC_NoIdTokenWithoutOpenidScope(t *testing.T) {
+	oauthService, client, request := newOAuthTestService(t)
+
+	code := authorizeCode(t, oauthService, request)
+	tokens, err := oauthService.Token(context.Background(), web.OAuthTokenRequest{GrantType: "authorization_code", ClientId: client.ClientId, Code: code, RedirectUri: request.RedirectUri, CodeVerifier: oauthTestVerifier})
+	assert.NoError(t, err)
+	assert.Empty(t, tokens.IdToken)
+}
+
+func TestOIDC_OpenidScopeRequiresAsymmetricKey(t *testing.T) {
+	oauthService, _, request := newOAuthTestService(t)
+	ctx := context.Background()
+
+	client, err := oauthService.CreateClient(ctx, web.OAuthClientCreateRequest{Name: "SSO app", RedirectUris: []string{request.RedirectUri}, Scopes: []string{"openid", "profile"}})
+	assert.NoError(t, err)
+
+	// with HS256 the id_token would be signed with JWT_SECRET
+	request.ClientId = client.ClientId
+	request.Scope = "openid profile"
+	_, err = oauthService.ValidateAuthorization(ctx, request)
+	assert.Equal(t, "invalid_scope", err.(exception.OAuthError).Code)
+
+	_, err = oauthService.DeviceAuthorization(ctx, web.OAuthDeviceAuthorizationRequest{ClientId: client.ClientId})
+	assert.Equal(t, "invalid_scope", err.(exception.OAuthError).Code)
+
+	request.Scope = "profile"
+	_, err = oauthService.ValidateAuthorization(ctx, request)
+	assert.NoError(t, err)
+
+	assert.NotContains(t, oauthService.OpenIDConfiguration().ScopesSupported, "openid")
+	assert.Empty(t, oauthService.OpenIDConfiguration().IdTokenSigningAlgValuesSupported)
+}
+
+func TestOIDC_Discovery(t *testing.T) {
+	t.Setenv("OIDC_ISSUER", "https://auth.example.com")
+	useTestSigningKey(t)
+	oauthService, _, _ := newOAuthTestService(t)
+	app := fiber.New()
+	routes.NewWellKnownRoutes(app, controller.NewWellKnownController(nil, oauthService))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/.well-known/openid-configuration", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var configuration web.OpenIDConfigurationResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&configuration))
+	assert.Equal(t, "https://auth.example.com", configuration.Issuer)
+	assert.Equal(t, "https://auth.example.com/userinfo", configuration.UserinfoEndpoint)
+	assert.Equal(t, "https://auth.example.com/.well-known/jwks.json", configuration.JwksUri)
+	assert.Contains(t, configuration.ScopesSupported, "openid")
+	assert.Equal(t, []string{"ES256"}, configuration.IdTokenSigningAlgValuesSupported)
+	assert.Equal(t, []string{"S256"}, configuration.CodeChallengeMethodsSupported)
+}
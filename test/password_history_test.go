@@ -0,0 +1,216 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/exception"
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/routes"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPasswordHistoryServices(t *testing.T) (service.AuthService, service.UserService, repository.UserRepository) {
+	db := setupTestDB(t)
+	userRepository := repository.NewUserRepository(db)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(db)
+	passwordHistoryRepository := repository.NewPasswordHistoryRepository(db)
+	refreshTokenRepository := repository.NewRefreshTokenRepository(db)
+	sessionRepository := repository.NewSessionRepository(db)
+	revocationStore := repository.NewMemoryRevocationStore()
+	tokenVersionStore := repository.NewCachedTokenVersionStore(db, time.Minute)
+
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+	authService.RevocationStore = revocationStore
+	authService.TokenVersionStore = tokenVersionStore
+	userService := service.NewUserService(userRepository, loginAttemptRepository, passwordHistoryRepository, refreshTokenRepository, sessionRepository, repository.NewUserTokenRepository(db), revocationStore, tokenVersionStore, new(MailerMock), db, validator.New())
+
+	return authService, userService, userRepository
+}
+
+func assertFieldErrorCode(t *testing.T, err error, code string) {
+	var validationErr exception.ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, code, validationErr.Errors[0].Code)
+	}
+}
+
+func TestPasswordHistory_RejectsRecentPasswords(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("PASSWORD_HISTORY_SIZE", "3")
+	authService, userService, _ := newPasswordHistoryServices(t)
+	ctx := context.Background()
+
+	passwords := []string{"Tr0ub4dor-cobalt-meadow", "violet-Harbor-9-quill", "amber-Falcon-42-river", "copper-Lantern-7-sage"}
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: passwords[0], FullName: "History"})
+	assert.NoError(t, err)
+
+	changePassword := func(current string, next string) error {
+		_, err := authService.ChangePassword(ctx, web.AuthChangePasswordRequest{UserId: user.Id.String(), CurrentPassword: current, NewPassword: next})
+		return err
+	}
+
+	// the current password counts as used
+	assertFieldErrorCode(t, changePassword(passwords[0], passwords[0]), "reused")
+
+	assert.NoError(t, changePassword(passwords[0], passwords[1]))
+	_, err = userService.Update(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: user.FullName, Role: "user", PasswordHash: passwords[2]})
+	assert.NoError(t, err)
+
+	for _, reused := range passwords[:3] {
+		assertFieldErrorCode(t, changePassword(passwords[2], reused), "reused")
+		_, err = userService.Update(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: user.FullName, Role: "user", PasswordHash: reused})
+		assertFieldErrorCode(t, err, "reused")
+	}
+
+	_, err = userService.Update(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: user.FullName, Role: "user", PasswordHash: passwords[3]})
+	assert.NoError(t, err)
+
+	// only the last three passwords are remembered
+	assert.NoError(t, changePassword(passwords[3], passwords[0]))
+
+	tokens, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: passwords[0]})
+	assert.NoError(t, err)
+	assert.False(t, tokens.MustChangePassword)
+}
+
+func TestPasswordChange_RequiredTokenOnlyChangesPassword(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	authService, userService, userRepository := newPasswordHistoryServices(t)
+	ctx := context.Background()
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: "Tr0ub4dor-cobalt-meadow", FullName: "Forced", MustChangePassword: true})
+	assert.NoError(t, err)
+
+	tokens, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"})
+	assert.NoError(t, err)
+	assert.True(t, tokens.MustChangePassword)
+	assert.Empty(t, tokens.RefreshToken)
+
+	claims, err := utils.ParseJWT(tokens.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.RestrictionPasswordChange, claims["restricted_to"])
+
+	app := fiber.New()
+	routes.NewUserRouter(app, controller.NewUserController(userService, authService), controller.NewMfaController(newTestMfaService(userRepository, setupTestDB(t))), controller.NewSessionController(nil), controller.NewUserImportController(nil), middleware.JWTConfig{})
+
+	request := func(method string, path string, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens.Token)
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, 403, request("GET", "/users/me", ""))
+	assert.Equal(t, 403, request("POST", "/users/me/mfa/enroll", ""))
+	assert.Equal(t, 200, request("POST", "/users/me/password", `{"current_password":"Tr0ub4dor-cobalt-meadow","new_password":"violet-Harbor-9-quill"}`))
+
+	tokens, err = authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "violet-Harbor-9-quill"})
+	assert.NoError(t, err)
+	assert.False(t, tokens.MustChangePassword)
+	assert.NotEmpty(t, tokens.RefreshToken)
+}
+
+func TestPasswordChange_RequiredAfterMaxAge(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("PASSWORD_MAX_AGE", "720h")
+	authService, _, userRepository := newPasswordHistoryServices(t)
+	ctx := context.Background()
+
+	hashed, _ := utils.HashPassword("Tr0ub4dor-cobalt-meadow")
+	changedAt := time.Now().Add(-721 * time.Hour)
+	user, err := userRepository.Save(ctx, setupTestDB(t), domain.User{Id: uuid.New(), Email: uuid.NewString() + "@example.com", PasswordHash: hashed, FullName: "Expired", Role: "user", PasswordChangedAt: &changedAt})
+	assert.NoError(t, err)
+
+	tokens, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"})
+	assert.NoError(t, err)
+	assert.True(t, tokens.MustChangePassword)
+
+	// the change itself hands out a full session
+	tokens, err = authService.ChangePassword(ctx, web.AuthChangePasswordRequest{UserId: user.Id.String(), CurrentPassword: "Tr0ub4dor-cobalt-meadow", NewPassword: "violet-Harbor-9-quill"})
+	assert.NoError(t, err)
+	assert.False(t, tokens.MustChangePassword)
+	assert.NotEmpty(t, tokens.RefreshToken)
+}
+
+func TestAuthService_ChangePassword_RevokesOtherSessions(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	authService, userService, _ := newPasswordHistoryServices(t)
+	ctx := context.Background()
+	mailerMock := authService.(*service.AuthServiceImpl).Mailer.(*MailerMock)
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: "Tr0ub4dor-cobalt-meadow", FullName: "Sessions"})
+	assert.NoError(t, err)
+
+	login := web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"}
+	laptop, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+	phone, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+
+	_, err = authService.ChangePassword(ctx, web.AuthChangePasswordRequest{UserId: user.Id.String(), CurrentPassword: "wrong", NewPassword: "violet-Harbor-9-quill"})
+	assertFieldErrorCode(t, err, "incorrect")
+
+	tokens, err := authService.ChangePassword(ctx, web.AuthChangePasswordRequest{UserId: user.Id.String(), CurrentPassword: "Tr0ub4dor-cobalt-meadow", NewPassword: "violet-Harbor-9-quill"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.Token)
+
+	for _, old := range []web.AuthTokenResponse{laptop, phone} {
+		_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: old.RefreshToken})
+		assert.Error(t, err)
+	}
+
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.NoError(t, err)
+
+	// the new access token is not caught by the revocation
+	claims, err := utils.ParseJWT(tokens.Token)
+	assert.NoError(t, err)
+	issuedAt, _ := claims.GetIssuedAt()
+	revoked, err := authService.(*service.AuthServiceImpl).RevocationStore.IsUserRevoked(ctx, user.Id.String(), issuedAt.Time)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	if assert.Len(t, mailerMock.Messages, 1) {
+		assert.Equal(t, "Your password has been changed", mailerMock.Messages[0].Subject)
+	}
+
+	// the profile endpoint no longer changes passwords
+	_, err = userService.UpdateMe(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: user.FullName, PasswordHash: "amber-Falcon-42-river"})
+	assert.EqualError(t, err, "use POST /users/me/password to change the password")
+}
+
+func TestAuthService_LoginRightAfterResetIsNotRevoked(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	authService, userService, _ := newPasswordHistoryServices(t)
+	ctx := context.Background()
+	impl := authService.(*service.AuthServiceImpl)
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: "Tr0ub4dor-cobalt-meadow", FullName: "Reset"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, authService.ForgotPassword(ctx, web.AuthForgotPasswordRequest{Email: user.Email}))
+	resetToken := tokenFromMail(impl.Mailer.(*MailerMock).Last())
+
+	assert.NoError(t, authService.ResetPassword(ctx, web.AuthResetPasswordRequest{Token: resetToken, NewPassword: "violet-Harbor-9-quill"}))
+	tokens, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "violet-Harbor-9-quill"})
+	assert.NoError(t, err)
+
+	_, err = middleware.VerifyToken(ctx, middleware.JWTConfig{RevocationStore: impl.RevocationStore}, tokens.Token)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPasswordlessService(t *testing.T) (service.AuthService, repository.UserRepository, *MailerMock) {
+	db := setupTestDB(t)
+	userRepository := repository.NewUserRepository(db)
+	mailerMock := new(MailerMock)
+
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+	authService.Mailer = mailerMock
+
+	return authService, userRepository, mailerMock
+}
+
+func createPasswordlessUser(t *testing.T, userRepository repository.UserRepository) domain.User {
+	user, err := userRepository.Save(context.Background(), setupTestDB(t), domain.User{Id: uuid.New(), Email: uuid.NewString() + "@example.com", FullName: "Passwordless", Role: "user"})
+	assert.NoError(t, err)
+	return user
+}
+
+func TestAuthService_PasswordlessLink(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("REQUIRE_VERIFIED_EMAIL", "true")
+	t.Setenv("PASSWORDLESS_LOGIN_URL", "https://app.example.com/passwordless?token=")
+	authService, userRepository, mailerMock := newPasswordlessService(t)
+	ctx := context.Background()
+	user := createPasswordlessUser(t, userRepository)
+
+	assert.NoError(t, authService.StartPasswordless(ctx, web.AuthPasswordlessStartRequest{Email: user.Email}))
+	// a second request within a minute sends nothing
+	assert.NoError(t, authService.StartPasswordless(ctx, web.AuthPasswordlessStartRequest{Email: user.Email}))
+	// unknown emails look the same
+	assert.NoError(t, authService.StartPasswordless(ctx, web.AuthPasswordlessStartRequest{Email: uuid.NewString() + "@example.com"}))
+
+	if !assert.Len(t, mailerMock.Messages, 1) {
+		return
+	}
+	token := regexp.MustCompile(`passwordless\?token=(\S+)`).FindStringSubmatch(mailerMock.Messages[0].Body)[1]
+
+	tokens, err := authService.VerifyPasswordless(ctx, web.AuthPasswordlessVerifyRequest{Token: token})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	claims, err := utils.ParseJWT(tokens.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{utils.AmrOtp}, claims["amr"])
+	assert.Equal(t, true, claims["email_verified"])
+
+	verified, err := userRepository.FindById(ctx, setupTestDB(t), user.Id.String())
+	assert.NoError(t, err)
+	assert.True(t, verified.IsVerified)
+
+	_, err = authService.VerifyPasswordless(ctx, web.AuthPasswordlessVerifyRequest{Token: token})
+	assert.EqualError(t, err, "invalid or expired sign-in link")
+}
+
+func TestAuthService_PasswordlessCode(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	authService, userRepository, mailerMock := newPasswordlessService(t)
+	ctx := context.Background()
+	user := createPasswordlessUser(t, userRepository)
+
+	assert.NoError(t, authService.StartPasswordless(ctx, web.AuthPasswordlessStartRequest{Email: user.Email, Method: "code"}))
+	if !assert.Len(t, mailerMock.Messages, 1) {
+		return
+	}
+	code := regexp.MustCompile(`\b\d{6}\b`).FindString(mailerMock.Messages[0].Body)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	_, err := authService.VerifyPasswordless(ctx, web.AuthPasswordlessVerifyRequest{Email: user.Email, Code: wrong})
+	assert.EqualError(t, err, "invalid or expired code")
+
+	_, err = authService.VerifyPasswordless(ctx, web.AuthPasswordlessVerifyRequest{Code: code})
+	assert.Error(t, err)
+
+	tokens, err := authService.VerifyPasswordless(ctx, web.AuthPasswordlessVerifyRequest{Email: user.Email, Code: code})
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, tokens.Token)
+	}
+
+	_, err = authService.VerifyPasswordless(ctx, web.AuthPasswordlessVerifyRequest{Email: user.Email, Code: code})
+	assert.EqualError(t, err, "invalid or expired code")
+}
+
+func TestAuthService_PasswordlessHourlyLimit(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("PASSWORDLESS_MAX_PER_HOUR", "1")
+	authService, userRepository, mailerMock := newPasswordlessService(t)
+	ctx := context.Background()
+	user := createPasswordlessUser(t, userRepository)
+
+	assert.NoError(t, authService.StartPasswordless(ctx, web.AuthPasswordlessStartRequest{Email: user.Email, Method: "code"}))
+
+	// move the first code past the per-minute limit
+	db := setupTestDB(t)
+	assert.NoError(t, db.Model(&domain.UserToken{}).Where("user_id = ?", user.Id).Update("created_at", time.Now().Add(-2*time.Minute)).Error)
+
+	assert.NoError(t, authService.StartPasswordless(ctx, web.AuthPasswordlessStartRequest{Email: user.Email}))
+	assert.Len(t, mailerMock.Messages, 1)
+}
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokenRepository_RotateAndRevokeFamily(t *testing.T) {
+	db := setupTestDB(t)
+	repo := repository.NewRefreshTokenRepository(db)
+	ctx := context.Background()
+
+	familyId := uuid.New()
+	first, err := repo.Save(ctx, db, domain.RefreshToken{
+		UserId:    uuid.New(),
+		FamilyId:  familyId,
+		TokenHash: "hash-first-" + familyId.String()[:8],
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, first.Id)
+
+	found, err := repo.FindByTokenHash(ctx, db, first.TokenHash)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Id, found.Id)
+
+	second, err := repo.Save(ctx, db, domain.RefreshToken{
+		UserId:    first.UserId,
+		FamilyId:  familyId,
+		TokenHash: "hash-second-" + familyId.String()[:8],
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	rotated, err := repo.MarkRotated(ctx, db, first.Id, second.Id, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, rotated)
+
+	// a token can only be rotated once
+	rotated, err = repo.MarkRotated(ctx, db, first.Id, uuid.New(), time.Now())
+	assert.NoError(t, err)
+	assert.False(t, rotated)
+
+	err = repo.RevokeFamily(ctx, db, familyId, time.Now())
+	assert.NoError(t, err)
+
+	revoked, err := repo.FindByTokenHash(ctx, db, second.TokenHash)
+	assert.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+}
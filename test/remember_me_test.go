@@ -0,0 +1,78 @@
+package test
+
+import (
+	"auth-api-jwt/models/web"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRememberMe_LongLivedDeviceBoundSession(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("REMEMBER_ME_TTL", "720h")
+	authService, login := newSessionPolicyService(t)
+	ctx := context.Background()
+
+	login.RememberMe = true
+	tokens, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.DeviceId)
+	assert.Equal(t, int64((720 * time.Hour).Seconds()), tokens.RefreshExpiresIn)
+	assert.Equal(t, int64((15 * time.Minute).Seconds()), tokens.ExpiresIn)
+
+	refreshed, err := authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken, DeviceId: tokens.DeviceId})
+	assert.NoError(t, err)
+	assert.Equal(t, tokens.DeviceId, refreshed.DeviceId)
+	assert.Equal(t, int64((720 * time.Hour).Seconds()), refreshed.RefreshExpiresIn)
+
+	// without its device id the token is treated as stolen
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: refreshed.RefreshToken})
+	assert.EqualError(t, err, "refresh token is bound to another device, please login again")
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: refreshed.RefreshToken, DeviceId: tokens.DeviceId})
+	assert.Error(t, err)
+}
+
+func TestRememberMe_ExcludedRole(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("REMEMBER_ME_TTL_USER", "0")
+	authService, login := newSessionPolicyService(t)
+
+	login.RememberMe = true
+	tokens, err := authService.Login(context.Background(), login)
+	assert.NoError(t, err)
+	assert.Empty(t, tokens.DeviceId)
+	assert.Equal(t, int64((7 * 24 * time.Hour).Seconds()), tokens.RefreshExpiresIn)
+}
+
+func TestRememberMe_ReplacesSessionOfSameDevice(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("SESSION_IDLE_TIMEOUT", "1ns")
+	authService, login := newSessionPolicyService(t)
+	ctx := context.Background()
+
+	login.RememberMe = true
+	login.DeviceId = "laptop-1"
+	first, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+	assert.Equal(t, "laptop-1", first.DeviceId)
+
+	other := login
+	other.DeviceId = "phone-1"
+	phone, err := authService.Login(ctx, other)
+	assert.NoError(t, err)
+
+	second, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: first.RefreshToken, DeviceId: "laptop-1"})
+	assert.Error(t, err)
+
+	// remembered sessions don't expire from inactivity
+	time.Sleep(time.Millisecond)
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: second.RefreshToken, DeviceId: "laptop-1"})
+	assert.NoError(t, err)
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: phone.RefreshToken, DeviceId: "phone-1"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"auth-api-jwt/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertRevocationStore(t *testing.T, store repository.RevocationStore) {
+	ctx := context.Background()
+
+	revoked, err := store.IsRevoked(ctx, "active-jti")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, store.Revoke(ctx, "active-jti", time.Now().Add(time.Hour)))
+	assert.NoError(t, store.Revoke(ctx, "active-jti", time.Now().Add(time.Hour)))
+	assert.NoError(t, store.Revoke(ctx, "stale-jti", time.Now().Add(-time.Minute)))
+
+	revoked, err = store.IsRevoked(ctx, "active-jti")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// an entry past the token's own expiry no longer matters
+	revoked, err = store.IsRevoked(ctx, "stale-jti")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, store.PurgeExpired(ctx))
+
+	revoked, err = store.IsRevoked(ctx, "active-jti")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	cutoff := time.Now()
+	assert.NoError(t, store.RevokeUser(ctx, "user-1", cutoff, cutoff.Add(time.Hour)))
+
+	revoked, err = store.IsUserRevoked(ctx, "user-1", cutoff.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsUserRevoked(ctx, "user-1", cutoff.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	// iat has second precision: a token from the cutoff's own second is
+	// kept, one from the second before is not
+	second := time.Now().Truncate(time.Second)
+	assert.NoError(t, store.RevokeUser(ctx, "user-3", second.Add(500*time.Millisecond), second.Add(time.Hour)))
+
+	revoked, err = store.IsUserRevoked(ctx, "user-3", second)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	revoked, err = store.IsUserRevoked(ctx, "user-3", second.Add(-time.Second))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsUserRevoked(ctx, "user-2", cutoff.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	assertRevocationStore(t, repository.NewMemoryRevocationStore())
+}
+
+func TestSQLRevocationStore(t *testing.T) {
+	db := setupTestDB(t)
+	assertRevocationStore(t, repository.NewSQLRevocationStore(db))
+}
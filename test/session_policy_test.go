@@ -0,0 +1,119 @@
+package test
+
+import (
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSessionPolicyService(t *testing.T) (service.AuthService, web.AuthLoginRequest) {
+	db := setupTestDB(t)
+	userRepository := repository.NewUserRepository(db)
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+
+	hashed, _ := utils.HashPassword("Tr0ub4dor-cobalt-meadow")
+	user, err := userRepository.Save(context.Background(), db, domain.User{Id: uuid.New(), Email: uuid.NewString() + "@example.com", PasswordHash: hashed, FullName: "Policy", Role: "user"})
+	assert.NoError(t, err)
+
+	return authService, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"}
+}
+
+func TestSessionLimit_EvictsOldest(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("SESSION_MAX_CONCURRENT", "2")
+	authService, login := newSessionPolicyService(t)
+	ctx := context.Background()
+
+	first, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+	second, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+	third, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: first.RefreshToken})
+	assert.Error(t, err)
+	for _, tokens := range []web.AuthTokenResponse{second, third} {
+		_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+		assert.NoError(t, err)
+	}
+}
+
+func TestSessionLimit_RejectsNewLogins(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("SESSION_MAX_CONCURRENT", "1")
+	t.Setenv("SESSION_LIMIT_POLICY", "reject")
+	authService, login := newSessionPolicyService(t)
+	ctx := context.Background()
+
+	first, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+
+	_, err = authService.Login(ctx, login)
+	assert.EqualError(t, err, "too many active sessions, sign out of another device first")
+
+	// signing out frees the slot
+	claims, _ := utils.ParseJWT(first.Token)
+	assert.NoError(t, authService.Logout(ctx, web.AuthLogoutRequest{SessionId: claims["sid"].(string)}))
+	_, err = authService.Login(ctx, login)
+	assert.NoError(t, err)
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("SESSION_IDLE_TIMEOUT", "30m")
+	authService, login := newSessionPolicyService(t)
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	tokens, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+	claims, _ := utils.ParseJWT(tokens.Token)
+	sessionId := claims["sid"].(string)
+
+	request := func(activity repository.SessionActivityStore) int {
+		app := fiber.New()
+		app.Get("/me", middleware.JWTMiddleware(middleware.JWTConfig{SessionActivity: activity, SessionIdleTimeout: 30 * time.Minute}), func(c *fiber.Ctx) error {
+			return c.SendStatus(200)
+		})
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.Token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	setLastSeen := func(at time.Time) {
+		assert.NoError(t, db.Model(&domain.Session{}).Where("id = ?", sessionId).Update("last_seen_at", at).Error)
+	}
+	lastSeen := func() time.Time {
+		var session domain.Session
+		assert.NoError(t, db.Where("id = ?", sessionId).First(&session).Error)
+		return session.LastSeenAt
+	}
+
+	// activity is only written when flushed
+	setLastSeen(time.Now().Add(-20 * time.Minute))
+	activity := repository.NewBatchedSessionActivityStore(db)
+	assert.Equal(t, 200, request(activity))
+	assert.True(t, time.Since(lastSeen()) > 19*time.Minute)
+	assert.NoError(t, activity.Flush(ctx))
+	assert.True(t, time.Since(lastSeen()) < time.Minute)
+
+	setLastSeen(time.Now().Add(-31 * time.Minute))
+	assert.Equal(t, 401, request(repository.NewBatchedSessionActivityStore(db)))
+
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.EqualError(t, err, "session expired due to inactivity, please login again")
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/routes"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	chromeWindowsUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	safariIPhoneUserAgent  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+)
+
+func TestDeviceName(t *testing.T) {
+	assert.Equal(t, "Chrome on Windows", utils.DeviceName(chromeWindowsUserAgent))
+	assert.Equal(t, "Safari on iOS", utils.DeviceName(safariIPhoneUserAgent))
+	assert.Equal(t, "curl", utils.DeviceName("curl/8.4.0"))
+	assert.Equal(t, "Unknown device", utils.DeviceName(""))
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	db := setupTestDB(t)
+	ctx := context.Background()
+	userRepository := repository.NewUserRepository(db)
+	refreshTokenRepository := repository.NewRefreshTokenRepository(db)
+	sessionRepository := repository.NewSessionRepository(db)
+	revocationStore := repository.NewMemoryRevocationStore()
+	passwordHistoryRepository := repository.NewPasswordHistoryRepository(db)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(db)
+	tokenVersionStore := repository.NewCachedTokenVersionStore(db, time.Minute)
+
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+	authService.RevocationStore = revocationStore
+	authService.TokenVersionStore = tokenVersionStore
+	userService := service.NewUserService(userRepository, loginAttemptRepository, passwordHistoryRepository, refreshTokenRepository, sessionRepository, repository.NewUserTokenRepository(db), revocationStore, tokenVersionStore, new(MailerMock), db, validator.New())
+	sessionService := service.NewSessionService(sessionRepository, refreshTokenRepository, revocationStore, tokenVersionStore, db)
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: "Tr0ub4dor-cobalt-meadow", FullName: "Sessions"})
+	assert.NoError(t, err)
+
+	laptop, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow", ClientIp: "203.0.113.7", UserAgent: chromeWindowsUserAgent})
+	assert.NoError(t, err)
+	phone, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow", ClientIp: "198.51.100.4", UserAgent: safariIPhoneUserAgent, Device: "Work phone"})
+	assert.NoError(t, err)
+
+	app := fiber.New()
+	routes.NewUserRouter(app, controller.NewUserController(userService, authService), controller.NewMfaController(newTestMfaService(userRepository, db)), controller.NewSessionController(sessionService), controller.NewUserImportController(nil), middleware.JWTConfig{RevocationStore: revocationStore})
+
+	request := func(method string, path string, token string) (int, []web.SessionResponse) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var payload struct {
+			Data []web.SessionResponse `json:"data"`
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(raw, &payload)
+		return resp.StatusCode, payload.Data
+	}
+
+	status, sessions := request("GET", "/users/me/sessions", phone.Token)
+	assert.Equal(t, 200, status)
+	if assert.Len(t, sessions, 2) {
+		devices := map[string]web.SessionResponse{}
+		for _, session := range sessions {
+			devices[session.Device] = session
+		}
+		assert.True(t, devices["Work phone"].Current)
+		assert.Equal(t, "198.51.100.4", devices["Work phone"].IpAddress)
+		assert.False(t, devices["Chrome on Windows"].Current)
+		assert.Equal(t, "203.0.113.7", devices["Chrome on Windows"].IpAddress)
+	}
+
+	// refreshing keeps the same session
+	laptop, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: laptop.RefreshToken})
+	assert.NoError(t, err)
+	_, sessions = request("GET", "/users/me/sessions", laptop.Token)
+	assert.Len(t, sessions, 2)
+
+	// log out everywhere else
+	status, _ = request("DELETE", "/users/me/sessions", phone.Token)
+	assert.Equal(t, 200, status)
+
+	status, _ = request("GET", "/users/me", laptop.Token)
+	assert.Equal(t, 401, status)
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: laptop.RefreshToken})
+	assert.Error(t, err)
+
+	status, sessions = request("GET", "/users/me/sessions", phone.Token)
+	assert.Equal(t, 200, status)
+	if assert.Len(t, sessions, 1) {
+		// someone else's session can't be revoked
+		other, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"})
+		assert.NoError(t, err)
+		assert.EqualError(t, sessionService.Revoke(ctx, uuid.NewString(), sessions[0].Id.String()), "session not found")
+
+		status, _ = request("DELETE", "/users/me/sessions/"+sessions[0].Id.String(), other.Token)
+		assert.Equal(t, 200, status)
+		status, _ = request("GET", "/users/me", phone.Token)
+		assert.Equal(t, 401, status)
+	}
+
+	// logging out ends the session too
+	tablet, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"})
+	assert.NoError(t, err)
+	claims, err := utils.ParseJWT(tablet.Token)
+	assert.NoError(t, err)
+	assert.NoError(t, authService.Logout(ctx, web.AuthLogoutRequest{UserId: user.Id.String(), SessionId: claims["sid"].(string)}))
+
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: tablet.RefreshToken})
+	assert.Error(t, err)
+
+	sessionsLeft, err := sessionService.FindByUser(ctx, user.Id.String(), "")
+	assert.NoError(t, err)
+	assert.Len(t, sessionsLeft, 1)
+
+	assert.NoError(t, sessionService.RevokeOthers(ctx, user.Id.String(), ""))
+	sessionsLeft, err = sessionService.FindByUser(ctx, user.Id.String(), "")
+	assert.NoError(t, err)
+	assert.Empty(t, sessionsLeft)
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySet_SignAndParse(t *testing.T) {
+	t.Cleanup(func() { utils.UseKeySet(nil) })
+
+	for _, algorithm := range []string{"RS256", "ES256", "EdDSA"} {
+		key, err := utils.GenerateSigningKey(algorithm)
+		assert.NoError(t, err)
+
+		utils.UseKeySet(utils.NewKeySet([]utils.SigningKey{key}))
+
+		tokenString, err := utils.GenerateJWT("12345", "user")
+		assert.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+		assert.NoError(t, err)
+		assert.Equal(t, key.Kid, parsed.Header["kid"])
+		assert.Equal(t, algorithm, parsed.Method.Alg())
+
+		claims, err := utils.ParseJWT(tokenString)
+		assert.NoError(t, err, algorithm)
+		assert.Equal(t, "12345", claims["user_id"])
+	}
+}
+
+func TestKeySet_RejectsUnknownKeyAndLegacyHMAC(t *testing.T) {
+	t.Cleanup(func() { utils.UseKeySet(nil) })
+	os.Setenv("JWT_SECRET", "testsecret")
+
+	signer, _ := utils.GenerateSigningKey("ES256")
+	other, _ := utils.GenerateSigningKey("ES256")
+
+	utils.UseKeySet(utils.NewKeySet([]utils.SigningKey{signer}))
+	foreignToken, _ := utils.GenerateJWT("12345", "user")
+	hmacToken := generateTestToken("testsecret", "12345", "admin")
+
+	utils.UseKeySet(utils.NewKeySet([]utils.SigningKey{other}))
+
+	_, err := utils.ParseJWT(foreignToken)
+	assert.Error(t, err)
+
+	_, err = utils.ParseJWT(hmacToken)
+	assert.Error(t, err)
+
+	os.Setenv("JWT_ACCEPT_LEGACY_HS256", "true")
+	defer os.Unsetenv("JWT_ACCEPT_LEGACY_HS256")
+
+	_, err = utils.ParseJWT(hmacToken)
+	assert.NoError(t, err)
+}
+
+func TestSigningKeyService_RotateKeepsRetiredKeyPublished(t *testing.T) {
+	t.Cleanup(func() { utils.UseKeySet(nil) })
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+	svc := service.NewSigningKeyService(repository.NewSigningKeyRepository(db), db, "ES256")
+	svc.(*service.SigningKeyServiceImpl).ActivationDelay = 0
+
+	assert.NoError(t, svc.Initialize(ctx))
+	assert.Len(t, svc.JWKS().Keys, 1)
+
+	oldToken, err := utils.GenerateJWT("12345", "user")
+	assert.NoError(t, err)
+
+	assert.NoError(t, svc.Rotate(ctx))
+
+	jwks := svc.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	for _, key := range jwks.Keys {
+		assert.Equal(t, "EC", key.Kty)
+		assert.Equal(t, "P-256", key.Crv)
+		assert.NotEmpty(t, key.X)
+	}
+
+	// tokens signed before the rotation keep working until they expire
+	_, err = utils.ParseJWT(oldToken)
+	assert.NoError(t, err)
+
+	newToken, err := utils.GenerateJWT("12345", "user")
+	assert.NoError(t, err)
+	oldHeader, _, _ := jwt.NewParser().ParseUnverified(oldToken, jwt.MapClaims{})
+	newHeader, _, _ := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	assert.NotEqual(t, oldHeader.Header["kid"], newHeader.Header["kid"])
+
+	// a second instance loading from the same database sees both keys
+	other := service.NewSigningKeyService(repository.NewSigningKeyRepository(db), db, "ES256")
+	assert.NoError(t, other.Reload(ctx))
+	assert.Len(t, other.JWKS().Keys, 2)
+}
+
+func TestSigningKeyService_RotatePublishesKeyBeforeUse(t *testing.T) {
+	t.Cleanup(func() { utils.UseKeySet(nil) })
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+	svc := service.NewSigningKeyService(repository.NewSigningKeyRepository(db), db, "ES256")
+	impl := svc.(*service.SigningKeyServiceImpl)
+
+	assert.NoError(t, svc.Initialize(ctx))
+	active, ok := impl.KeySet.Active()
+	assert.True(t, ok, "the first key signs right away")
+
+	// the next key is in the JWKS of every instance before anything is
+	// signed with it
+	impl.ActivationDelay = 200 * time.Millisecond
+	assert.NoError(t, svc.Rotate(ctx))
+
+	next, _ := impl.KeySet.Latest()
+	assert.NotEqual(t, active.Kid, next.Kid)
+	current, _ := impl.KeySet.Active()
+	assert.Equal(t, active.Kid, current.Kid)
+
+	other := service.NewSigningKeyService(repository.NewSigningKeyRepository(db), db, "ES256")
+	assert.NoError(t, other.Reload(ctx))
+	_, published := other.(*service.SigningKeyServiceImpl).KeySet.Lookup(next.Kid)
+	assert.True(t, published)
+
+	assert.Eventually(t, func() bool {
+		current, _ := impl.KeySet.Active()
+		return current.Kid == next.Kid
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestSigningKeyService_RotateCoversClientTokenLifetime(t *testing.T) {
+	t.Cleanup(func() { utils.UseKeySet(nil) })
+	t.Setenv("JWT_ACCESS_TTL", "15m")
+	t.Setenv("OAUTH_CLIENT_TOKEN_TTL", "2h")
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+	svc := service.NewSigningKeyService(repository.NewSigningKeyRepository(db), db, "ES256")
+
+	assert.NoError(t, svc.Initialize(ctx))
+	active, ok := utils.CurrentKeySet().Active()
+	assert.True(t, ok)
+	assert.NoError(t, svc.Rotate(ctx))
+
+	var retired domain.SigningKey
+	assert.NoError(t, db.First(&retired, "kid = ?", active.Kid).Error)
+	assert.NotNil(t, retired.ExpiresAt)
+	assert.True(t, retired.ExpiresAt.After(time.Now().Add(2*time.Hour)), "client tokens live longer than access tokens")
+}
+
+func TestWellKnownController_JWKS(t *testing.T) {
+	key, _ := utils.GenerateSigningKey("RS256")
+	svc := &service.SigningKeyServiceImpl{KeySet: utils.NewKeySet([]utils.SigningKey{key})}
+
+	app := fiber.New()
+	ctrl := controller.NewWellKnownController(svc, nil)
+	app.Get("/.well-known/jwks.json", ctrl.JWKS)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var jwks utils.JWKS
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	assert.Len(t, jwks.Keys, 1)
+	assert.Equal(t, key.Kid, jwks.Keys[0].Kid)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "AQAB", jwks.Keys[0].E)
+}
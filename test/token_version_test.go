@@ -0,0 +1,154 @@
+package test
+
+import (
+	"auth-api-jwt/middleware"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenVersion_RoleChangeInvalidatesTokens(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	db := setupTestDB(t)
+	ctx := context.Background()
+	userRepository := repository.NewUserRepository(db)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(db)
+	passwordHistoryRepository := repository.NewPasswordHistoryRepository(db)
+	tokenVersionStore := repository.NewCachedTokenVersionStore(db, time.Minute)
+
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+	authService.TokenVersionStore = tokenVersionStore
+	userService := service.NewUserService(userRepository, loginAttemptRepository, passwordHistoryRepository, repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), tokenVersionStore, new(MailerMock), db, validator.New())
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: "Tr0ub4dor-cobalt-meadow", FullName: "Demoted", Role: "admin"})
+	assert.NoError(t, err)
+
+	login := web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"}
+	tokens, err := authService.Login(ctx, login)
+	assert.NoError(t, err)
+
+	app := fiber.New()
+	app.Get("/admin", middleware.JWTMiddleware(middleware.JWTConfig{TokenVersions: tokenVersionStore}), middleware.AdminOnly(), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	request := func(token string) int {
+		req := httptest.NewRequest("GET", "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, 200, request(tokens.Token))
+
+	// a profile edit keeps the tokens working
+	_, err = userService.Update(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: "Renamed", Role: "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, request(tokens.Token))
+
+	_, err = userService.Update(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: "Renamed", Role: "user"})
+	assert.NoError(t, err)
+	assert.Equal(t, 401, request(tokens.Token))
+
+	// a new login carries the new version and role
+	tokens, err = authService.Login(ctx, login)
+	assert.NoError(t, err)
+	assert.Equal(t, 403, request(tokens.Token))
+
+	assert.NoError(t, userService.Delete(ctx, user.Id.String()))
+	assert.Equal(t, 401, request(tokens.Token))
+}
+
+func TestTokenVersion_PasswordChangeKeepsNewTokens(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	authService, userService, _ := newPasswordHistoryServices(t)
+	ctx := context.Background()
+	tokenVersionStore := authService.(*service.AuthServiceImpl).TokenVersionStore
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: "Tr0ub4dor-cobalt-meadow", FullName: "Versioned"})
+	assert.NoError(t, err)
+
+	old, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"})
+	assert.NoError(t, err)
+
+	tokens, err := authService.ChangePassword(ctx, web.AuthChangePasswordRequest{UserId: user.Id.String(), CurrentPassword: "Tr0ub4dor-cobalt-meadow", NewPassword: "violet-Harbor-9-quill"})
+	assert.NoError(t, err)
+
+	app := fiber.New()
+	app.Get("/me", middleware.JWTMiddleware(middleware.JWTConfig{TokenVersions: tokenVersionStore}), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	for token, status := range map[string]int{old.Token: 401, tokens.Token: 200} {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+	}
+}
+
+func TestUserService_AdminPasswordChangeRevokesSessions(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	authService, userService, _ := newPasswordHistoryServices(t)
+	ctx := context.Background()
+	impl := authService.(*service.AuthServiceImpl)
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: "Tr0ub4dor-cobalt-meadow", FullName: "Compromised"})
+	assert.NoError(t, err)
+
+	tokens, err := authService.Login(ctx, web.AuthLoginRequest{Email: user.Email, Password: "Tr0ub4dor-cobalt-meadow"})
+	assert.NoError(t, err)
+
+	_, err = userService.Update(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: user.FullName, Role: "user", PasswordHash: "violet-Harbor-9-quill"})
+	assert.NoError(t, err)
+
+	// the old session can neither refresh nor keep using its access token
+	_, err = authService.Refresh(ctx, web.AuthRefreshRequest{RefreshToken: tokens.RefreshToken})
+	assert.Error(t, err)
+
+	sessions, err := impl.SessionRepository.FindActiveByUser(ctx, impl.DB, user.Id, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	_, err = middleware.VerifyToken(ctx, middleware.JWTConfig{RevocationStore: impl.RevocationStore, TokenVersions: impl.TokenVersionStore}, tokens.Token)
+	assert.Error(t, err)
+}
+
+func TestTokenVersion_RoleChangeRevokesUser(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	userRepository := repository.NewUserRepository(db)
+	revocationStore := repository.NewMemoryRevocationStore()
+
+	userService := service.NewUserService(userRepository, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), revocationStore, repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validator.New())
+
+	user, err := userService.Create(ctx, web.UserCreateRequest{Email: uuid.NewString() + "@example.com", Password: "Tr0ub4dor-cobalt-meadow", FullName: "Demoted", Role: "admin"})
+	assert.NoError(t, err)
+
+	issuedBefore := time.Now().Add(-time.Second)
+
+	// instances that still cache the old token version reject the old
+	// tokens through the revocation store
+	_, err = userService.Update(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: user.FullName, Role: "admin"})
+	assert.NoError(t, err)
+	revoked, err := revocationStore.IsUserRevoked(ctx, user.Id.String(), issuedBefore)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	_, err = userService.Update(ctx, web.UserUpdateRequest{Id: user.Id, Email: user.Email, FullName: user.FullName, Role: "user"})
+	assert.NoError(t, err)
+	revoked, err = revocationStore.IsUserRevoked(ctx, user.Id.String(), issuedBefore)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
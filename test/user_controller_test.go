@@ -58,6 +58,11 @@ func (m *UserServiceMock) FindAll(ctx context.Context) ([]domain.User, error) {
 	return args.Get(0).([]domain.User), args.Error(1)
 }
 
+func (m *UserServiceMock) Unlock(ctx context.Context, targetUserId string) error {
+	args := m.Called(ctx, targetUserId)
+	return args.Error(0)
+}
+
 func TestUserController_Create_Success(t *testing.T) {
 	mockService := new(UserServiceMock)
 
@@ -76,7 +81,7 @@ func TestUserController_Create_Success(t *testing.T) {
 	mockService.On("Create", mock.Anything, mock.Anything).Return(expected, nil)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Post("/users", ctrl.Create)
 
 	bodyBytes, _ := json.Marshal(requestBody)
@@ -99,7 +104,7 @@ func TestUserController_Create_InvalidJSON(t *testing.T) {
 	requestBody := []byte(`{ invalid json }`)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Post("/users", ctrl.Create)
 
 	bodyBytes, _ := json.Marshal(requestBody)
@@ -123,7 +128,7 @@ func TestUserController_Create_ValidationError(t *testing.T) {
 	}
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Post("/users", func(c *fiber.Ctx) error {
 		return ctrl.Create(c)
 	})
@@ -151,7 +156,7 @@ func TestUserController_Create_ServiceError(t *testing.T) {
 	mockService.On("Create", mock.Anything, mock.Anything).Return(domain.User{}, assert.AnError)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Post("/users", ctrl.Create)
 
 	bodyBytes, _ := json.Marshal(requestBody)
@@ -186,7 +191,7 @@ func TestUserController_Update_Success(t *testing.T) {
 	mockService.On("Update", mock.Anything, mock.AnythingOfType("web.UserUpdateRequest")).Return(updated, nil)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Put("/users/:userId", func(c *fiber.Ctx) error {
 		c.Locals("userId", "admin-id")
 		c.Locals("role", "admin")
@@ -211,7 +216,7 @@ func TestUserController_Update_InvalidUUID(t *testing.T) {
 	mockService := new(UserServiceMock)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 
 	app.Put("/users/:userId", func(c *fiber.Ctx) error {
 		c.Locals("role", "admin")
@@ -232,7 +237,7 @@ func TestUserController_Update_InvalidJSON(t *testing.T) {
 	targetId := uuid.New().String()
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 
 	app.Put("/users/:userId", func(c *fiber.Ctx) error {
 		c.Locals("userId", targetId)
@@ -262,7 +267,7 @@ func TestUserController_Update_ServiceError(t *testing.T) {
 		Return(domain.User{}, errors.New("database error"))
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 
 	app.Put("/users/:userId", func(c *fiber.Ctx) error {
 		c.Locals("userId", targetId)
@@ -300,7 +305,7 @@ func TestUserController_UpdateMe_Success(t *testing.T) {
 	mockService.On("UpdateMe", mock.Anything, mock.AnythingOfType("web.UserUpdateRequest")).Return(expectedUser, nil)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Put("/users/me", func(c *fiber.Ctx) error {
 		c.Locals("userId", userId)
 		c.Locals("role", "user")
@@ -326,7 +331,7 @@ func TestUserController_UpdateMe_InvalidJSON(t *testing.T) {
 	targetId := uuid.New().String()
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 
 	app.Put("/users/me", func(c *fiber.Ctx) error {
 		c.Locals("userId", targetId)
@@ -358,7 +363,7 @@ func TestUserController_UpdateMe_ServiceError(t *testing.T) {
 		Return(domain.User{}, errors.New("database error"))
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 
 	app.Put("/users/me", func(c *fiber.Ctx) error {
 		c.Locals("userId", targetId)
@@ -390,7 +395,7 @@ func TestUserController_FindById_Success(t *testing.T) {
 	mockService.On("FindById", mock.Anything, targetId).Return(expectedUser, nil)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Get("/users/:userId", func(c *fiber.Ctx) error {
 		c.Locals("userId", targetId)
 		c.Locals("role", "user")
@@ -416,7 +421,7 @@ func TestUserController_FindById_InvalidUUID(t *testing.T) {
 	mockService := new(UserServiceMock)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Get("/users/:userId", func(c *fiber.Ctx) error {
 		c.Locals("role", "admin")
 		return ctrl.FindById(c)
@@ -435,7 +440,7 @@ func TestUserController_FindById_ServiceError(t *testing.T) {
 	mockService.On("FindById", mock.Anything, targetId).Return(domain.User{}, errors.New("database error"))
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Get("/users/:userId", func(c *fiber.Ctx) error {
 		c.Locals("role", "admin")
 		return ctrl.FindById(c)
@@ -461,7 +466,7 @@ func TestUserController_Me_Success(t *testing.T) {
 	mockService.On("FindById", mock.Anything, targetId).Return(expectedUser, nil)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Get("/users/me", func(c *fiber.Ctx) error {
 		c.Locals("userId", targetId)
 		return ctrl.Me(c)
@@ -487,7 +492,7 @@ func TestUserController_Me_InvalidUUID(t *testing.T) {
 	mockService := new(UserServiceMock)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Get("/users/me", func(c *fiber.Ctx) error {
 		c.Locals("userId", "not-uuid")
 		return ctrl.Me(c)
@@ -509,7 +514,7 @@ func TestUserController_Me_ServiceError(t *testing.T) {
 	mockService.On("FindById", mock.Anything, targetId).Return(domain.User{}, errors.New("database error"))
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Get("/users/me", func(c *fiber.Ctx) error {
 		c.Locals("userId", targetId)
 		return ctrl.Me(c)
@@ -529,7 +534,7 @@ func TestUserController_Delete_Success(t *testing.T) {
 	mockService.On("Delete", mock.Anything, id).Return(nil)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Delete("/users/:userId", ctrl.Delete)
 
 	req := httptest.NewRequest("DELETE", "/users/"+id, nil)
@@ -550,7 +555,7 @@ func TestUserController_Delete_InvalidUUID(t *testing.T) {
 	mockService := new(UserServiceMock)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Delete("/users/:userId", func(c *fiber.Ctx) error {
 		return ctrl.Delete(c)
 	})
@@ -570,7 +575,7 @@ func TestUserController_Delete_ServiceError(t *testing.T) {
 	mockService.On("Delete", mock.Anything, id).Return(errors.New("failed to delete"))
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Delete("/users/:userId", ctrl.Delete)
 
 	req := httptest.NewRequest("DELETE", "/users/"+id, nil)
@@ -588,7 +593,7 @@ func TestUserController_FindAll_Success(t *testing.T) {
 	mockService.On("FindAll", mock.Anything).Return(users, nil)
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Get("/users", ctrl.FindAll)
 
 	req := httptest.NewRequest("GET", "/users", nil)
@@ -610,7 +615,7 @@ func TestUserController_FindAll_ServiceError(t *testing.T) {
 	mockService.On("FindAll", mock.Anything).Return([]domain.User{}, errors.New("failed"))
 
 	app := fiber.New()
-	ctrl := controller.NewUserController(mockService)
+	ctrl := controller.NewUserController(mockService, new(AuthServiceMock))
 	app.Get("/users", func(c *fiber.Ctx) error {
 		return ctrl.FindAll(c)
 	})
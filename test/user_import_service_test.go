@@ -0,0 +1,203 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	firebaseSignerKey     = "jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA=="
+	firebaseSaltSeparator = "Bw=="
+	firebaseSalt          = "42xEC+ixf3L2lw=="
+	firebaseHash          = "lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ=="
+	djangoHash            = "pbkdf2_sha256$1000$seasalt$VBT1mYqqELxykRqSYaU4OT84xvzrvR+wDo3CT+hPNgY="
+)
+
+func TestForeignPasswordHashes(t *testing.T) {
+	assert.True(t, utils.CheckPassword("secret134", djangoHash))
+	assert.False(t, utils.CheckPassword("incorrect", djangoHash))
+
+	encoded, err := utils.EncodeFirebaseScryptHash(utils.FirebaseScryptConfig{
+		SignerKey:     firebaseSignerKey,
+		SaltSeparator: firebaseSaltSeparator,
+		Rounds:        8,
+		MemCost:       14,
+	}, firebaseSalt, firebaseHash)
+	assert.NoError(t, err)
+	assert.True(t, utils.CheckPassword("user1password", encoded))
+	assert.False(t, utils.CheckPassword("incorrect", encoded))
+
+	// foreign hashes are always upgraded on the next login
+	assert.True(t, utils.PasswordNeedsRehash(djangoHash))
+	assert.True(t, utils.PasswordNeedsRehash(encoded))
+}
+
+func newTestImportRequest(data string, dryRun bool) web.UserImportRequest {
+	return web.UserImportRequest{
+		Format:                "ndjson",
+		DryRun:                dryRun,
+		Data:                  strings.NewReader(data),
+		FirebaseSignerKey:     firebaseSignerKey,
+		FirebaseSaltSeparator: firebaseSaltSeparator,
+		FirebaseRounds:        8,
+		FirebaseMemCost:       14,
+	}
+}
+
+func TestUserImportService_NDJSON(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	userRepository := repository.NewUserRepository(db)
+	authRepository := repository.NewAuthRepository(db)
+	importService := service.NewUserImportService(authRepository, db, validator.New())
+
+	auth0Hash, err := utils.BcryptHasher{Cost: 4}.Hash("auth0password")
+	assert.NoError(t, err)
+
+	data := strings.Join([]string{
+		fmt.Sprintf(`{"email":"import-auth0@example.com","name":"Auth0 User","email_verified":true,"passwordHash":%q}`, auth0Hash),
+		fmt.Sprintf(`{"email":"import-django@example.com","email_verified":true,"password_hash":%q,"hash_algorithm":"django"}`, djangoHash),
+		fmt.Sprintf(`{"email":"import-firebase@example.com","displayName":"Firebase User","emailVerified":true,"passwordHash":%q,"salt":%q}`, firebaseHash, firebaseSalt),
+		`{"email":"not-an-email","passwordHash":"x"}`,
+		`{"email":"import-auth0@example.com"}`,
+		`{"email":"import-md5@example.com","password_hash":"5f4dcc3b5aa765d61d8327deb882cf99"}`,
+		`{not json`,
+	}, "\n")
+
+	report, err := importService.Import(ctx, newTestImportRequest(data, true))
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 7, report.Total)
+	assert.Equal(t, 3, report.Imported)
+	assert.Equal(t, 4, report.Failed)
+
+	rows := []int{}
+	for _, rowError := range report.Errors {
+		rows = append(rows, rowError.Row)
+	}
+	assert.Equal(t, []int{4, 5, 6, 7}, rows)
+	assert.Equal(t, "duplicate email in import", report.Errors[1].Error)
+	assert.Equal(t, "unsupported password hash format", report.Errors[2].Error)
+
+	// a dry run saves nothing
+	_, err = authRepository.FindByEmail(ctx, db, "import-django@example.com")
+	assert.Error(t, err)
+
+	report, err = importService.Import(ctx, newTestImportRequest(data, false))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Imported)
+
+	firebaseUser, err := authRepository.FindByEmail(ctx, db, "import-firebase@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "Firebase User", firebaseUser.FullName)
+	assert.True(t, firebaseUser.IsVerified)
+
+	// importing the same file again rejects every row
+	report, err = importService.Import(ctx, newTestImportRequest(data, true))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, "email already registered", report.Errors[0].Error)
+
+	// foreign hashes sign in and are replaced by a native hash
+	authService := newTestAuthService(t, db, authRepository, userRepository)
+
+	for email, password := range map[string]string{"import-django@example.com": "secret134", "import-firebase@example.com": "user1password"} {
+		tokens, err := authService.Login(ctx, web.AuthLoginRequest{Email: email, Password: password})
+		assert.NoError(t, err, email)
+		assert.NotEmpty(t, tokens.Token)
+
+		upgraded, err := authRepository.FindByEmail(ctx, db, email)
+		assert.NoError(t, err)
+		assert.False(t, utils.PasswordNeedsRehash(upgraded.PasswordHash), email)
+		assert.True(t, utils.CheckPassword(password, upgraded.PasswordHash))
+	}
+}
+
+func TestUserImportService_RejectsUnsafeHashes(t *testing.T) {
+	db := setupTestDB(t)
+	importService := service.NewUserImportService(repository.NewAuthRepository(db), db, validator.New())
+
+	bcryptHash, err := utils.BcryptHasher{Cost: 4}.Hash("secret134")
+	assert.NoError(t, err)
+
+	data := strings.Join([]string{
+		`{"email":"slow-django@example.com","password_hash":"pbkdf2_sha256$2000000000$seasalt$VBT1mYqqELxykRqSYaU4OT84xvzrvR+wDo3CT+hPNgY=","hash_algorithm":"django"}`,
+		`{"email":"truncated-django@example.com","password_hash":"pbkdf2_sha256$1000$seasalt","hash_algorithm":"django"}`,
+		`{"email":"slow-scrypt@example.com","password_hash":"$scrypt$ln=20,r=1024,p=1$c29tZXNhbHQ$a2V5","hash_algorithm":"scrypt"}`,
+		fmt.Sprintf(`{"email":"mislabelled@example.com","password_hash":%q,"hash_algorithm":"django"}`, bcryptHash),
+	}, "\n")
+
+	report, err := importService.Import(context.Background(), newTestImportRequest(data, true))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, 4, report.Failed)
+	assert.Equal(t, "unsupported password hash format", report.Errors[0].Error)
+	assert.Equal(t, "unsupported password hash format", report.Errors[1].Error)
+	assert.Equal(t, "unsupported password hash format", report.Errors[2].Error)
+	assert.Equal(t, "password hash is not a pbkdf2 hash", report.Errors[3].Error)
+
+	// firebase rounds are bounded like any other scrypt cost
+	request := newTestImportRequest(fmt.Sprintf(`{"email":"slow-firebase@example.com","passwordHash":%q,"salt":%q}`, firebaseHash, firebaseSalt), true)
+	request.FirebaseRounds = 1 << 20
+	report, err = importService.Import(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+}
+
+func TestUserImportController_CSV(t *testing.T) {
+	db := setupTestDB(t)
+	authRepository := repository.NewAuthRepository(db)
+	importService := service.NewUserImportService(authRepository, db, validator.New())
+
+	app := fiber.New()
+	app.Post("/users/import", controller.NewUserImportController(importService).Import)
+
+	csvData := "email,full_name,role,password_hash\n" +
+		"import-csv@example.com,CSV User,admin," + djangoHash + "\n" +
+		"import-csv-2@example.com,Bad Role,owner,\n" +
+		"import-csv-3@example.com,Too,Many,Columns,Here\n"
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("dry_run", "false")
+	part, _ := writer.CreateFormFile("file", "users.csv")
+	part.Write([]byte(csvData))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/users/import", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := app.Test(req, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	raw, _ := io.ReadAll(resp.Body)
+	var payload struct {
+		Data web.UserImportResponse `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(raw, &payload))
+	assert.Equal(t, 3, payload.Data.Total)
+	assert.Equal(t, 1, payload.Data.Imported)
+	assert.Equal(t, []int{3, 4}, []int{payload.Data.Errors[0].Row, payload.Data.Errors[1].Row})
+
+	imported, err := authRepository.FindByEmail(context.Background(), db, "import-csv@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", imported.Role)
+	assert.Equal(t, djangoHash, imported.PasswordHash)
+}
@@ -15,16 +15,22 @@ import (
 )
 
 type testUser struct {
-	Id           uuid.UUID `gorm:"type:uuid;primaryKey"`
-	Email        string    `gorm:"type:varchar(255);unique;not null"`
-	PasswordHash string    `gorm:"type:text;not null"`
-	FullName     string    `gorm:"type:varchar(100);not null"`
-	IsVerified   bool      `gorm:"default:false"`
-	Role         string    `gorm:"type:varchar(50);default:'user'"`
-	LastLoginAt  *time.Time
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	Id                 uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Email              string    `gorm:"type:varchar(255);unique;not null"`
+	PasswordHash       string    `gorm:"type:text;not null"`
+	FullName           string    `gorm:"type:varchar(100);not null"`
+	IsVerified         bool      `gorm:"default:false"`
+	Role               string    `gorm:"type:varchar(50);default:'user'"`
+	MfaEnabled         bool      `gorm:"default:false"`
+	MfaSecret          string    `gorm:"type:varchar(64)"`
+	MfaLastUsedStep    int64     `gorm:"default:0"`
+	LastLoginAt        *time.Time
+	MustChangePassword bool `gorm:"default:false"`
+	PasswordChangedAt  *time.Time
+	TokenVersion       int `gorm:"not null;default:0"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
 func (testUser) TableName() string { return "users" }
@@ -35,7 +41,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("failed to open in-memory sqlite: %v", err)
 	}
 
-	if err := db.AutoMigrate(&testUser{}); err != nil {
+	if err := db.AutoMigrate(&testUser{}, &domain.RefreshToken{}, &domain.RevokedToken{}, &domain.UserRevocation{}, &domain.SigningKey{}, &domain.UserToken{}, &domain.MfaRecoveryCode{}, &domain.WebAuthnCredential{}, &domain.LoginAttempt{}, &domain.PasswordHistory{}, &domain.Session{}, &domain.OAuthClient{}, &domain.OAuthAuthorizationCode{}, &domain.OAuthDeviceCode{}); err != nil {
 		t.Fatalf("failed to automigrate: %v", err)
 	}
 
@@ -3,6 +3,7 @@ package test
 import (
 	"auth-api-jwt/models/domain"
 	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
 	"auth-api-jwt/service"
 	"context"
 	"errors"
@@ -51,6 +52,31 @@ func (m *UserRepositoryMock) UpdateLastLogin(ctx context.Context, tx *gorm.DB, u
 	return args.Error(0)
 }
 
+func (m *UserRepositoryMock) MarkVerified(ctx context.Context, tx *gorm.DB, userId string) error {
+	args := m.Called(ctx, tx, userId)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) UpdatePassword(ctx context.Context, tx *gorm.DB, userId string, passwordHash string) error {
+	args := m.Called(ctx, tx, userId, passwordHash)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) ChangePassword(ctx context.Context, tx *gorm.DB, userId string, passwordHash string, changedAt time.Time) error {
+	args := m.Called(ctx, tx, userId, passwordHash, changedAt)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) UpdateMfa(ctx context.Context, tx *gorm.DB, userId string, enabled bool, secret string) error {
+	args := m.Called(ctx, tx, userId, enabled, secret)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) UseMfaStep(ctx context.Context, tx *gorm.DB, userId string, step int64) (bool, error) {
+	args := m.Called(ctx, tx, userId, step)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestUserService_Create_Success(t *testing.T) {
 	mockRepo := new(UserRepositoryMock)
 	db := setupTestDB(t)
@@ -58,7 +84,7 @@ func TestUserService_Create_Success(t *testing.T) {
 
 	request := web.UserCreateRequest{
 		Email:    "test@example.com",
-		Password: "password",
+		Password: "Tr0ub4dor-cobalt-meadow",
 		FullName: "Test User",
 		Role:     "user",
 	}
@@ -73,7 +99,7 @@ func TestUserService_Create_Success(t *testing.T) {
 
 	mockRepo.On("Save", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(expected, nil)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	got, err := svc.Create(context.Background(), request)
 	assert.NoError(t, err)
 	assert.Equal(t, expected.Email, got.Email)
@@ -91,7 +117,7 @@ func TestUserService_Create_Failed(t *testing.T) {
 		Role:     "",
 	}
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 
 	assert.Panics(t, func() {
 		svc.Create(context.Background(), request)
@@ -107,14 +133,14 @@ func TestUserService_Create_RepoSaveError(t *testing.T) {
 
 	request := web.UserCreateRequest{
 		Email:    "test@example.com",
-		Password: "password",
+		Password: "Tr0ub4dor-cobalt-meadow",
 		FullName: "Test User",
 		Role:     "user",
 	}
 
 	mockRepo.On("Save", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(domain.User{}, errors.New("db error"))
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	_, err := svc.Create(context.Background(), request)
 	assert.Error(t, err)
 	assert.Equal(t, "db error", err.Error())
@@ -154,7 +180,7 @@ func TestUserService_Update_Success(t *testing.T) {
 
 	mockRepo.On("Update", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(updated, nil)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	got, err := svc.Update(context.Background(), request)
 	assert.NoError(t, err)
 	assert.Equal(t, "Updated", got.FullName)
@@ -174,7 +200,7 @@ func TestUserService_Update_FindByIdError(t *testing.T) {
 
 	mockRepo.On("FindById", mock.Anything, mock.Anything, id.String()).Return(domain.User{}, assert.AnError)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 
 	result, err := svc.Update(context.Background(), request)
 	assert.Error(t, err)
@@ -204,7 +230,7 @@ func TestUserService_Update_RepoUpdateError(t *testing.T) {
 
 	mockRepo.On("Update", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(domain.User{}, errors.New("db update error"))
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	_, err := svc.Update(context.Background(), request)
 	assert.Error(t, err)
 	assert.Equal(t, "db update error", err.Error())
@@ -240,7 +266,7 @@ func TestUserService_UpdateMe_Success(t *testing.T) {
 
 	mockRepo.On("Update", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(updated, nil)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 
 	got, err := svc.UpdateMe(context.Background(), request)
 	assert.NoError(t, err)
@@ -248,6 +274,41 @@ func TestUserService_UpdateMe_Success(t *testing.T) {
 	assert.Equal(t, "updated@example.com", got.Email)
 }
 
+func TestUserService_UpdateMe_NewEmailNeedsVerification(t *testing.T) {
+	mockRepo := new(UserRepositoryMock)
+	db := setupTestDB(t)
+	mailerMock := new(MailerMock)
+
+	id := uuid.New()
+	existing := domain.User{Id: id, Email: "verified@example.com", FullName: "Verified", Role: "user", IsVerified: true}
+
+	mockRepo.On("FindById", mock.Anything, mock.Anything, id.String()).Return(existing, nil)
+	sameAddress := existing
+	sameAddress.Email = "Verified@example.com"
+	newAddress := existing
+	newAddress.Email = "someone-else@example.com"
+	newAddress.IsVerified = false
+	mockRepo.On("Update", mock.Anything, mock.Anything, sameAddress).Return(sameAddress, nil).Once()
+	mockRepo.On("Update", mock.Anything, mock.Anything, newAddress).Return(newAddress, nil).Once()
+
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), mailerMock, db, validator.New())
+
+	// only the case changed, so it is still the same address
+	got, err := svc.UpdateMe(context.Background(), web.UserUpdateRequest{Id: id, Email: "Verified@example.com", FullName: "Verified"})
+	assert.NoError(t, err)
+	assert.True(t, got.IsVerified)
+	assert.Empty(t, mailerMock.Messages)
+
+	got, err = svc.UpdateMe(context.Background(), web.UserUpdateRequest{Id: id, Email: "someone-else@example.com", FullName: "Verified"})
+	assert.NoError(t, err)
+	assert.False(t, got.IsVerified)
+	assert.Len(t, mailerMock.Messages, 1)
+	assert.Equal(t, "someone-else@example.com", mailerMock.Last().To)
+	assert.NotEmpty(t, tokenFromMail(mailerMock.Last()))
+
+	mockRepo.AssertExpectations(t)
+}
+
 func TestUserService_UpdateMe_FindByIdError(t *testing.T) {
 	mockRepo := new(UserRepositoryMock)
 	db := setupTestDB(t)
@@ -262,7 +323,7 @@ func TestUserService_UpdateMe_FindByIdError(t *testing.T) {
 
 	mockRepo.On("FindById", mock.Anything, mock.Anything, id.String()).Return(domain.User{}, assert.AnError)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 
 	result, err := svc.UpdateMe(context.Background(), request)
 	assert.Error(t, err)
@@ -292,7 +353,7 @@ func TestUserService_UpdateMe_UpdateError(t *testing.T) {
 
 	mockRepo.On("Update", mock.Anything, mock.Anything, mock.AnythingOfType("domain.User")).Return(domain.User{}, errors.New("db update error"))
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	_, err := svc.UpdateMe(context.Background(), request)
 	assert.Error(t, err)
 	assert.Equal(t, "db update error", err.Error())
@@ -317,7 +378,7 @@ func TestUserService_Delete_Success(t *testing.T) {
 
 	mockRepo.On("Delete", mock.Anything, mock.Anything, existing.Id.String()).Return(nil)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	err := svc.Delete(context.Background(), existing.Id.String())
 	assert.NoError(t, err)
 
@@ -333,7 +394,7 @@ func TestUserService_Delete_NotFound(t *testing.T) {
 
 	mockRepo.On("FindById", mock.Anything, mock.Anything, id).Return(domain.User{}, assert.AnError)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	err := svc.Delete(context.Background(), id)
 	if err == nil {
 		t.Fatalf("expected error when finding non-existent user")
@@ -354,7 +415,7 @@ func TestUserService_Delete_RepoDeleteError(t *testing.T) {
 
 	mockRepo.On("Delete", mock.Anything, mock.Anything, id).Return(errors.New("delete failed"))
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 
 	err := svc.Delete(context.Background(), id)
 	assert.Error(t, err)
@@ -377,7 +438,7 @@ func TestUserService_FindById_Success(t *testing.T) {
 
 	mockRepo.On("FindById", mock.Anything, mock.Anything, existing.Id.String()).Return(existing, nil)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	result, err := svc.FindById(context.Background(), existing.Id.String())
 	assert.NoError(t, err)
 	assert.Equal(t, existing.Id.String(), result.Id.String())
@@ -397,7 +458,7 @@ func TestUserService_FindById_NotFound(t *testing.T) {
 
 	mockRepo.On("FindById", mock.Anything, mock.Anything, id).Return(domain.User{}, assert.AnError)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	_, err := svc.FindById(context.Background(), id)
 	if err == nil {
 		t.Fatalf("expected error when finding non-existent user")
@@ -415,7 +476,7 @@ func TestUserService_FindById_RepoFindByIdError(t *testing.T) {
 
 	mockRepo.On("FindById", mock.Anything, mock.Anything, id).Return(domain.User{}, errors.New("database error"))
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 
 	_, err := svc.FindById(context.Background(), id)
 	assert.Error(t, err)
@@ -433,7 +494,7 @@ func TestUserService_FindAll_Success(t *testing.T) {
 
 	mockRepo.On("FindAll", mock.Anything, mock.Anything).Return(existing, nil)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	result, err := svc.FindAll(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, existing, result)
@@ -450,7 +511,7 @@ func TestUserService_FindAll_RepoFindAllError(t *testing.T) {
 
 	mockRepo.On("FindAll", mock.Anything, mock.Anything).Return(existing, assert.AnError)
 
-	svc := service.NewUserService(mockRepo, db, validate)
+	svc := service.NewUserService(mockRepo, repository.NewLoginAttemptRepository(db), repository.NewPasswordHistoryRepository(db), repository.NewRefreshTokenRepository(db), repository.NewSessionRepository(db), repository.NewUserTokenRepository(db), repository.NewMemoryRevocationStore(), repository.NewCachedTokenVersionStore(db, time.Minute), new(MailerMock), db, validate)
 	_, err := svc.FindAll(context.Background())
 	if err == nil {
 		t.Fatalf("expected error when finding non-existent user")
@@ -3,7 +3,10 @@ package test
 import (
 	"auth-api-jwt/utils"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
@@ -42,6 +45,146 @@ func TestCheckPassword_Failure(t *testing.T) {
 
 }
 
+func TestPasswordHashers_RoundTrip(t *testing.T) {
+	hashers := map[string]utils.PasswordHasher{
+		"$2a$":       utils.BcryptHasher{Cost: 4},
+		"$argon2id$": utils.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1},
+		"$scrypt$":   utils.ScryptHasher{LogN: 10, BlockSize: 8, Parallelism: 1},
+	}
+
+	for prefix, hasher := range hashers {
+		hashed, err := hasher.Hash("secret134")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(hashed, prefix), hashed)
+
+		assert.True(t, hasher.Verify("secret134", hashed))
+		assert.False(t, hasher.Verify("incorrect", hashed))
+		assert.False(t, hasher.NeedsRehash(hashed))
+
+		// any stored format is accepted regardless of the preferred hasher
+		assert.True(t, utils.CheckPassword("secret134", hashed))
+	}
+}
+
+func TestPasswordHashers_RejectUnsafeParameters(t *testing.T) {
+	salt := "c29tZXNhbHRzb21lc2FsdA"
+	key := "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	for _, hashed := range []string{
+		"$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key,
+		"$argon2id$v=19$m=1024,t=1,p=256$" + salt + "$" + key,
+		"$argon2id$v=19$m=4294967295,t=1,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=1024,t=1000000,p=1$" + salt + "$" + key,
+		"$scrypt$ln=20,r=1024,p=1$" + salt + "$" + key,
+		"$scrypt$ln=10,r=8,p=1000000$" + salt + "$" + key,
+		"$scrypt$ln=10,r=0,p=1$" + salt + "$" + key,
+	} {
+		assert.NotPanics(t, func() {
+			assert.False(t, utils.CheckPassword("secret134", hashed), hashed)
+		})
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	bcryptHash, _ := utils.BcryptHasher{Cost: 4}.Hash("secret134")
+
+	t.Setenv("PASSWORD_HASHER", "bcrypt")
+	t.Setenv("BCRYPT_COST", "4")
+	assert.False(t, utils.PasswordNeedsRehash(bcryptHash))
+
+	t.Setenv("BCRYPT_COST", "5")
+	assert.True(t, utils.PasswordNeedsRehash(bcryptHash))
+
+	// a lower cost never downgrades a stored hash
+	stronger, _ := utils.BcryptHasher{Cost: 6}.Hash("secret134")
+	assert.False(t, utils.PasswordNeedsRehash(stronger))
+
+	t.Setenv("PASSWORD_HASHER", "argon2id")
+	t.Setenv("ARGON2_MEMORY", "1024")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	assert.True(t, utils.PasswordNeedsRehash(bcryptHash))
+
+	argonHash, err := utils.HashPassword("secret134")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,"))
+	assert.False(t, utils.PasswordNeedsRehash(argonHash))
+
+	t.Setenv("ARGON2_ITERATIONS", "2")
+	assert.True(t, utils.PasswordNeedsRehash(argonHash))
+
+	// lowering one argon2id or scrypt parameter does not downgrade either
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_MEMORY", "512")
+	assert.False(t, utils.PasswordNeedsRehash(argonHash))
+
+	t.Setenv("PASSWORD_HASHER", "scrypt")
+	t.Setenv("SCRYPT_COST", "4")
+	t.Setenv("SCRYPT_BLOCK_SIZE", "8")
+	t.Setenv("SCRYPT_PARALLELISM", "1")
+	scryptHash, err := utils.HashPassword("secret134")
+	assert.NoError(t, err)
+
+	t.Setenv("SCRYPT_COST", "3")
+	assert.False(t, utils.PasswordNeedsRehash(scryptHash))
+	t.Setenv("SCRYPT_BLOCK_SIZE", "9")
+	assert.True(t, utils.PasswordNeedsRehash(scryptHash))
+}
+
+func TestCheckPasswordHasherConfig(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"PASSWORD_HASHER": "bcrypt", "BCRYPT_COST": "17"},
+		{"PASSWORD_HASHER": "bcrypt", "BCRYPT_COST": "3"},
+		{"PASSWORD_HASHER": "argon2", "BCRYPT_COST": "10"},
+		{"PASSWORD_HASHER": "argon2id", "ARGON2_ITERATIONS": "0"},
+		{"PASSWORD_HASHER": "argon2id", "ARGON2_PARALLELISM": "0"},
+		{"PASSWORD_HASHER": "argon2id", "ARGON2_PARALLELISM": "257"},
+		{"PASSWORD_HASHER": "argon2id", "ARGON2_MEMORY": "2097152"},
+		{"PASSWORD_HASHER": "scrypt", "SCRYPT_COST": "21"},
+		{"PASSWORD_HASHER": "scrypt", "SCRYPT_PARALLELISM": "0"},
+	} {
+		for key, value := range env {
+			t.Setenv(key, value)
+		}
+		assert.Error(t, utils.CheckPasswordHasherConfig(), env)
+
+		for key := range env {
+			t.Setenv(key, "")
+		}
+	}
+
+	for _, name := range []string{"", "bcrypt", "argon2id", "scrypt"} {
+		t.Setenv("PASSWORD_HASHER", name)
+		assert.NoError(t, utils.CheckPasswordHasherConfig(), name)
+	}
+}
+
+func TestPasswordStrength(t *testing.T) {
+	for _, password := range []string{"password", "P@ssw0rd", "qwerty123", "abcdefgh", "aaaaaaaaaa"} {
+		assert.Equal(t, 0, utils.PasswordStrength(password), password)
+	}
+
+	assert.GreaterOrEqual(t, utils.PasswordStrength("Tr0ub4dor-cobalt-meadow"), 3)
+
+	// the user's own details are as guessable as common passwords
+	assert.Greater(t, utils.PasswordStrength("maryjones"), utils.PasswordStrength("maryjones", "Mary Jones"))
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	// sha1("hunter2") in the HIBP "HASH:count" layout, and a plain entry
+	content := "# breached passwords\nF3BBBD66A63D4BF1747940578EC3D0103530E21D:17043\nletmein2020\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	assert.NoError(t, utils.LoadBreachedPasswords(path))
+	t.Cleanup(func() { utils.LoadBreachedPasswords(os.DevNull) })
+
+	assert.True(t, utils.IsBreachedPassword("hunter2"))
+	assert.True(t, utils.IsBreachedPassword("letmein2020"))
+	assert.False(t, utils.IsBreachedPassword("Tr0ub4dor-cobalt-meadow"))
+
+	assert.Error(t, utils.LoadBreachedPasswords(filepath.Join(t.TempDir(), "missing.txt")))
+}
+
 func TestJWTGeneration(t *testing.T) {
 	os.Setenv("JWT_SECRET", "testsecretkey")
 
@@ -65,4 +208,42 @@ func TestJWTGeneration(t *testing.T) {
 	assert.Equal(t, "admin", claims["role"])
 	assert.NotNil(t, claims["exp"])
 	assert.NotNil(t, claims["iat"])
+	assert.NotEmpty(t, claims["jti"])
+}
+
+func TestTOTP_Validate(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	step := utils.TOTPStep(now)
+
+	previous, _ := utils.GenerateTOTPCode(secret, step-1)
+	got, ok := utils.ValidateTOTP(secret, previous, now)
+	assert.True(t, ok)
+	assert.Equal(t, step-1, got)
+
+	stale, _ := utils.GenerateTOTPCode(secret, step-3)
+	_, ok = utils.ValidateTOTP(secret, stale, now)
+	assert.False(t, ok)
+
+	_, ok = utils.ValidateTOTP(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestTOTP_KnownVector(t *testing.T) {
+	// RFC 6238 SHA1 test secret "12345678901234567890" at T = 59s.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	code, err := utils.GenerateTOTPCode(secret, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", code)
+}
+
+func TestRecoveryCode_Format(t *testing.T) {
+	code, err := utils.GenerateRecoveryCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, 14)
+	assert.Equal(t, utils.NormalizeRecoveryCode(code), utils.NormalizeRecoveryCode(" "+strings.ToUpper(code)+" "))
+	assert.Len(t, utils.NormalizeRecoveryCode(code), 12)
 }
@@ -0,0 +1,334 @@
+package test
+
+import (
+	"auth-api-jwt/controller"
+	"auth-api-jwt/exception"
+	"auth-api-jwt/models/domain"
+	"auth-api-jwt/models/web"
+	"auth-api-jwt/repository"
+	"auth-api-jwt/service"
+	"auth-api-jwt/utils"
+	"auth-api-jwt/webauthn"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// softAuthenticator is an in-memory ES256 authenticator that answers the
+// options returned by WebAuthnService the way a browser would.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+	userHandle   []byte
+	signCount    uint32
+	origin       string
+	rpId         string
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	credentialId := make([]byte, 16)
+	rand.Read(credentialId)
+
+	return &softAuthenticator{key: key, credentialId: credentialId, origin: "http://localhost:3000", rpId: "localhost"}
+}
+
+func (a *softAuthenticator) create(options web.WebAuthnCreationOptions) web.WebAuthnAttestation {
+	a.userHandle, _ = webauthn.DecodeBase64URL(options.User.Id)
+
+	coseKey := cborMap(
+		cborInt(1), cborInt(2),
+		cborInt(3), cborInt(-7),
+		cborInt(-1), cborInt(1),
+		cborInt(-2), cborBytes(a.key.X.FillBytes(make([]byte, 32))),
+		cborInt(-3), cborBytes(a.key.Y.FillBytes(make([]byte, 32))),
+	)
+
+	attested := make([]byte, 18)
+	binary.BigEndian.PutUint16(attested[16:], uint16(len(a.credentialId)))
+	attested = append(append(attested, a.credentialId...), coseKey...)
+
+	authData := a.authenticatorData(webauthn.FlagUserPresent|webauthn.FlagUserVerified|webauthn.FlagAttestedCredentialData, attested)
+	attestationObject := cborMap(
+		cborText("fmt"), cborText("none"),
+		cborText("attStmt"), cborMap(),
+		cborText("authData"), cborBytes(authData),
+	)
+
+	return web.WebAuthnAttestation{
+		Id:   webauthn.EncodeBase64URL(a.credentialId),
+		Type: "public-key",
+		Response: web.AuthenticatorAttestationResponse{
+			ClientDataJSON:    webauthn.EncodeBase64URL(a.clientData("webauthn.create", options.Challenge)),
+			AttestationObject: webauthn.EncodeBase64URL(attestationObject),
+		},
+	}
+}
+
+func (a *softAuthenticator) get(options web.WebAuthnRequestOptions) web.WebAuthnAssertion {
+	a.signCount++
+
+	clientDataJSON := a.clientData("webauthn.get", options.Challenge)
+	authData := a.authenticatorData(webauthn.FlagUserPresent|webauthn.FlagUserVerified, nil)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, _ := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+
+	return web.WebAuthnAssertion{
+		Id:   webauthn.EncodeBase64URL(a.credentialId),
+		Type: "public-key",
+		Response: web.AuthenticatorAssertionResponse{
+			ClientDataJSON:    webauthn.EncodeBase64URL(clientDataJSON),
+			AuthenticatorData: webauthn.EncodeBase64URL(authData),
+			Signature:         webauthn.EncodeBase64URL(signature),
+			UserHandle:        webauthn.EncodeBase64URL(a.userHandle),
+		},
+	}
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge string) []byte {
+	clientDataJSON, _ := json.Marshal(webauthn.ClientData{Type: ceremony, Challenge: challenge, Origin: a.origin})
+	return clientDataJSON
+}
+
+func (a *softAuthenticator) authenticatorData(flags byte, attested []byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.rpId))
+
+	authData := append([]byte(nil), rpIdHash[:]...)
+	authData = append(authData, flags)
+	authData = binary.BigEndian.AppendUint32(authData, a.signCount)
+
+	return append(authData, attested...)
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	}
+}
+
+func cborInt(n int64) []byte {
+	if n < 0 {
+		return cborHead(1, uint64(-1-n))
+	}
+	return cborHead(0, uint64(n))
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, uint64(len(b))), b...) }
+
+func cborText(s string) []byte { return append(cborHead(3, uint64(len(s))), s...) }
+
+func cborMap(items ...[]byte) []byte {
+	return append(cborHead(5, uint64(len(items)/2)), bytes.Join(items, nil)...)
+}
+
+func newTestWebAuthnServices(t *testing.T) (service.WebAuthnService, service.AuthService) {
+	db := setupTestDB(t)
+	userRepository := repository.NewUserRepository(db)
+	userTokenRepository := repository.NewUserTokenRepository(db)
+
+	webAuthnService := service.NewWebAuthnService(userRepository, repository.NewWebAuthnCredentialRepository(db), userTokenRepository, db, validator.New())
+	authService := newTestAuthService(t, db, repository.NewAuthRepository(db), userRepository)
+	authService.WebAuthnService = webAuthnService
+
+	return webAuthnService, authService
+}
+
+// signupWithPasskey returns the authenticator holding the new passkey and
+// the id of the account, taken from the user handle in the options.
+func signupWithPasskey(t *testing.T, webAuthnService service.WebAuthnService, authService service.AuthService, email string) (*softAuthenticator, uuid.UUID) {
+	ctx := context.Background()
+	authenticator := newSoftAuthenticator(t)
+
+	options, err := webAuthnService.SignupOptions(ctx, web.WebAuthnSignupOptionsRequest{Email: email, FullName: "Passkey User"})
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", options.Rp.Id)
+
+	err = authService.SignupWithPasskey(ctx, web.AuthPasskeySignupRequest{
+		Email:      email,
+		FullName:   "Passkey User",
+		Credential: authenticator.create(options),
+	})
+	assert.NoError(t, err)
+
+	handle, err := webauthn.DecodeBase64URL(options.User.Id)
+	assert.NoError(t, err)
+	userId, err := uuid.FromBytes(handle)
+	assert.NoError(t, err)
+
+	return authenticator, userId
+}
+
+func TestWebAuthn_PasskeyOnlySignupAndLogin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	webAuthnService, authService := newTestWebAuthnServices(t)
+	ctx := context.Background()
+
+	authenticator, userId := signupWithPasskey(t, webAuthnService, authService, "passkey@example.com")
+
+	_, err := authService.Login(ctx, web.AuthLoginRequest{Email: "passkey@example.com", Password: "anything"})
+	assert.EqualError(t, err, "invalid email or password")
+
+	options, err := webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{})
+	assert.NoError(t, err)
+
+	assertion := authenticator.get(options)
+	tokens, err := authService.LoginWithPasskey(ctx, web.AuthPasskeyLoginRequest{Credential: assertion})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	claims, err := utils.ParseJWT(tokens.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, userId.String(), claims["user_id"])
+
+	_, err = authService.LoginWithPasskey(ctx, web.AuthPasskeyLoginRequest{Credential: assertion})
+	assert.EqualError(t, err, "invalid or expired challenge")
+}
+
+func TestWebAuthn_SignupWithTakenEmailLooksTheSame(t *testing.T) {
+	webAuthnService, authService := newTestWebAuthnServices(t)
+	ctx := context.Background()
+
+	signupWithPasskey(t, webAuthnService, authService, "taken-passkey@example.com")
+
+	// the second signup succeeds as far as the caller can tell, but its
+	// passkey is not attached to the existing account
+	intruder, _ := signupWithPasskey(t, webAuthnService, authService, "taken-passkey@example.com")
+
+	options, err := webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{})
+	assert.NoError(t, err)
+	_, err = authService.LoginWithPasskey(ctx, web.AuthPasskeyLoginRequest{Credential: intruder.get(options)})
+	assert.Error(t, err)
+}
+
+func TestWebAuthn_SignupChallengeIsBoundToEmail(t *testing.T) {
+	webAuthnService, authService := newTestWebAuthnServices(t)
+	ctx := context.Background()
+	authenticator := newSoftAuthenticator(t)
+
+	options, err := webAuthnService.SignupOptions(ctx, web.WebAuthnSignupOptionsRequest{Email: "asked@example.com", FullName: "Passkey User"})
+	assert.NoError(t, err)
+
+	err = authService.SignupWithPasskey(ctx, web.AuthPasskeySignupRequest{Email: "other@example.com", FullName: "Passkey User", Credential: authenticator.create(options)})
+	assert.EqualError(t, err, "invalid or expired challenge")
+}
+
+func TestWebAuthn_AnonymousChallengesAreLimited(t *testing.T) {
+	t.Setenv("WEBAUTHN_MAX_PENDING_CHALLENGES", "2")
+	db := setupTestDB(t)
+	userTokenRepository := repository.NewUserTokenRepository(db)
+	webAuthnService := service.NewWebAuthnService(repository.NewUserRepository(db), repository.NewWebAuthnCredentialRepository(db), userTokenRepository, db, validator.New())
+	ctx := context.Background()
+	stored := func() int64 {
+		var count int64
+		assert.NoError(t, db.Model(&domain.UserToken{}).Where("purpose = ? AND client_ip IN ?", domain.UserTokenWebAuthnLogin, []string{"203.0.113.7", "198.51.100.1"}).Count(&count).Error)
+		return count
+	}
+
+	// expired challenges do not count and are purged on the next request
+	t.Setenv("WEBAUTHN_TIMEOUT", "1ns")
+	for i := 0; i < 3; i++ {
+		_, err := webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{ClientIp: "203.0.113.7"})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), stored())
+
+	t.Setenv("WEBAUTHN_TIMEOUT", "5m")
+	for i := 0; i < 2; i++ {
+		_, err := webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{ClientIp: "203.0.113.7"})
+		assert.NoError(t, err)
+	}
+	_, err := webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{ClientIp: "203.0.113.7"})
+	assert.IsType(t, exception.TooManyRequestsError{}, err)
+
+	_, err = webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{ClientIp: "198.51.100.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), stored())
+}
+
+func TestWebAuthn_RegisterForExistingUser(t *testing.T) {
+	webAuthnService, authService := newTestWebAuthnServices(t)
+	ctx := context.Background()
+
+	_, userId := signupWithPasskey(t, webAuthnService, authService, "second-key@example.com")
+
+	options, err := webAuthnService.RegistrationOptions(ctx, userId.String())
+	assert.NoError(t, err)
+	assert.Len(t, options.ExcludeCredentials, 1)
+
+	second := newSoftAuthenticator(t)
+	credential, err := webAuthnService.Register(ctx, web.WebAuthnRegisterRequest{UserId: userId.String(), Name: "Laptop", Credential: second.create(options)})
+	assert.NoError(t, err)
+	assert.Equal(t, "Laptop", credential.Name)
+}
+
+func TestWebAuthn_RejectsWrongOrigin(t *testing.T) {
+	webAuthnService, authService := newTestWebAuthnServices(t)
+	ctx := context.Background()
+
+	authenticator, _ := signupWithPasskey(t, webAuthnService, authService, "phished@example.com")
+	authenticator.origin = "https://evil.example.com"
+
+	options, err := webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{})
+	assert.NoError(t, err)
+
+	_, err = authService.LoginWithPasskey(ctx, web.AuthPasskeyLoginRequest{Credential: authenticator.get(options)})
+	assert.EqualError(t, err, "origin not allowed")
+}
+
+func TestWebAuthn_RejectsClonedAuthenticator(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	webAuthnService, authService := newTestWebAuthnServices(t)
+	ctx := context.Background()
+
+	authenticator, _ := signupWithPasskey(t, webAuthnService, authService, "cloned@example.com")
+
+	options, _ := webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{})
+	_, err := authService.LoginWithPasskey(ctx, web.AuthPasskeyLoginRequest{Credential: authenticator.get(options)})
+	assert.NoError(t, err)
+
+	authenticator.signCount--
+
+	options, _ = webAuthnService.LoginOptions(ctx, web.WebAuthnLoginOptionsRequest{})
+	_, err = authService.LoginWithPasskey(ctx, web.AuthPasskeyLoginRequest{Credential: authenticator.get(options)})
+	assert.EqualError(t, err, "credential sign count did not increase")
+}
+
+func TestWebAuthnController_Login_Rejected(t *testing.T) {
+	mockService := new(AuthServiceMock)
+	mockService.On("LoginWithPasskey", mock.Anything, mock.Anything).Return(web.AuthTokenResponse{}, assert.AnError)
+
+	app := fiber.New()
+	ctrl := controller.NewWebAuthnController(nil, mockService)
+	app.Post("/login", ctrl.Login)
+
+	bodyBytes, _ := json.Marshal(web.AuthPasskeyLoginRequest{})
+	req := httptest.NewRequest("POST", "/login", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	breachedPasswords   = map[string]struct{}{}
+	breachedPasswordsMu sync.RWMutex
+)
+
+// LoadBreachedPasswords replaces the breached password list with the one in
+// path. Each line is either a plain password or a SHA-1 hash in hex, with
+// an optional ":count" suffix as in the Have I Been Pwned downloads.
+func LoadBreachedPasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	loaded := map[string]struct{}{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1Hex(hash) {
+			loaded[strings.ToLower(hash)] = struct{}{}
+			continue
+		}
+
+		loaded[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	breachedPasswordsMu.Lock()
+	breachedPasswords = loaded
+	breachedPasswordsMu.Unlock()
+
+	return nil
+}
+
+func IsBreachedPassword(password string) bool {
+	breachedPasswordsMu.RLock()
+	defer breachedPasswordsMu.RUnlock()
+
+	_, found := breachedPasswords[sha1Hex(password)]
+	return found
+}
+
+func sha1Hex(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func isSHA1Hex(value string) bool {
+	if len(value) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
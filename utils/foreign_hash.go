@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// FirebaseScryptConfig holds the project-wide hash parameters shown in the
+// Firebase console next to a password export.
+type FirebaseScryptConfig struct {
+	SignerKey     string
+	SaltSeparator string
+	Rounds        int
+	MemCost       int
+}
+
+// EncodeFirebaseScryptHash packs an exported Firebase hash, its per-user
+// salt and the project parameters into one self-contained string:
+// $firebase-scrypt$r=8,ln=14$<salt separator>$<signer key>$<salt>$<hash>
+// Every value is taken as standard base64, the way Firebase exports it.
+func EncodeFirebaseScryptHash(config FirebaseScryptConfig, salt string, passwordHash string) (string, error) {
+	if !scryptParamsAllowed(config.MemCost, config.Rounds, 1) {
+		return "", fmt.Errorf("invalid firebase scrypt parameters")
+	}
+
+	parts := []string{config.SaltSeparator, config.SignerKey, salt, passwordHash}
+	for i, part := range parts {
+		decoded, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return "", fmt.Errorf("invalid firebase scrypt value: %w", err)
+		}
+		parts[i] = phcEncoding.EncodeToString(decoded)
+	}
+
+	return fmt.Sprintf("$firebase-scrypt$r=%d,ln=%d$%s", config.Rounds, config.MemCost, strings.Join(parts, "$")), nil
+}
+
+// FirebaseScryptVerifier checks hashes exported from Firebase Authentication,
+// which encrypt the project's signer key with an scrypt-derived key.
+type FirebaseScryptVerifier struct{}
+
+func (verifier FirebaseScryptVerifier) Name() string {
+	return "firebase-scrypt"
+}
+
+func (verifier FirebaseScryptVerifier) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$firebase-scrypt$")
+}
+
+func (verifier FirebaseScryptVerifier) Valid(hash string) bool {
+	_, ok := parseFirebaseScrypt(hash)
+	return ok
+}
+
+func (verifier FirebaseScryptVerifier) Verify(password string, hash string) bool {
+	params, ok := parseFirebaseScrypt(hash)
+	if !ok {
+		return false
+	}
+	rounds, memCost := params.rounds, params.memCost
+	saltSeparator, signerKey, salt, expected := params.saltSeparator, params.signerKey, params.salt, params.expected
+
+	derived, err := scrypt.Key([]byte(password), append(salt, saltSeparator...), 1<<memCost, rounds, 1, 32)
+	if err != nil {
+		return false
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return false
+	}
+
+	actual := make([]byte, len(signerKey))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(actual, signerKey)
+
+	return subtle.ConstantTimeCompare(actual, expected) == 1
+}
+
+type firebaseScryptHash struct {
+	rounds        int
+	memCost       int
+	saltSeparator []byte
+	signerKey     []byte
+	salt          []byte
+	expected      []byte
+}
+
+func parseFirebaseScrypt(hash string) (firebaseScryptHash, bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 7 || parts[1] != "firebase-scrypt" {
+		return firebaseScryptHash{}, false
+	}
+
+	params := firebaseScryptHash{}
+	if _, err := fmt.Sscanf(parts[2], "r=%d,ln=%d", &params.rounds, &params.memCost); err != nil || !scryptParamsAllowed(params.memCost, params.rounds, 1) {
+		return firebaseScryptHash{}, false
+	}
+
+	values := make([][]byte, 4)
+	for i, part := range parts[3:] {
+		decoded, err := phcEncoding.DecodeString(part)
+		if err != nil {
+			return firebaseScryptHash{}, false
+		}
+		values[i] = decoded
+	}
+	params.saltSeparator, params.signerKey, params.salt, params.expected = values[0], values[1], values[2], values[3]
+
+	if len(params.expected) == 0 || len(params.expected) > maxDerivedKeyLength || len(params.signerKey) > maxDerivedKeyLength {
+		return firebaseScryptHash{}, false
+	}
+
+	return params, true
+}
+
+// DjangoPbkdf2Verifier checks Django's default hashes:
+// pbkdf2_sha256$<iterations>$<salt>$<base64 key>
+type DjangoPbkdf2Verifier struct{}
+
+func (verifier DjangoPbkdf2Verifier) Name() string {
+	return "pbkdf2"
+}
+
+func (verifier DjangoPbkdf2Verifier) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "pbkdf2_sha256$") || strings.HasPrefix(hash, "pbkdf2_sha1$")
+}
+
+func (verifier DjangoPbkdf2Verifier) Valid(encoded string) bool {
+	_, ok := parseDjangoPbkdf2(encoded)
+	return ok
+}
+
+func (verifier DjangoPbkdf2Verifier) Verify(password string, encoded string) bool {
+	params, ok := parseDjangoPbkdf2(encoded)
+	if !ok {
+		return false
+	}
+
+	derived, err := pbkdf2.Key(params.digest, password, params.salt, params.iterations, len(params.expected))
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(derived, params.expected) == 1
+}
+
+type djangoPbkdf2Hash struct {
+	digest     func() hash.Hash
+	iterations int
+	salt       []byte
+	expected   []byte
+}
+
+func parseDjangoPbkdf2(encoded string) (djangoPbkdf2Hash, bool) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[2] == "" {
+		return djangoPbkdf2Hash{}, false
+	}
+
+	params := djangoPbkdf2Hash{salt: []byte(parts[2])}
+	switch parts[0] {
+	case "pbkdf2_sha256":
+		params.digest = sha256.New
+	case "pbkdf2_sha1":
+		params.digest = sha1.New
+	default:
+		return djangoPbkdf2Hash{}, false
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || iterations > maxPbkdf2Iterations {
+		return djangoPbkdf2Hash{}, false
+	}
+	params.iterations = iterations
+
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 || len(expected) > maxDerivedKeyLength {
+		return djangoPbkdf2Hash{}, false
+	}
+	params.expected = expected
+
+	return params, true
+}
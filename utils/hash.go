@@ -1,17 +1,73 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// dummyHashes caches one hash per hasher configuration for
+// CheckPasswordConstantTime.
+var dummyHashes sync.Map
+
+// passwordComputations counts the hashes computed by HashPassword and
+// CheckPassword. They dominate the cost of a login or signup, so code paths
+// that must not be told apart by timing compute the same number.
+var passwordComputations atomic.Int64
+
+// PasswordComputations returns how many password hashes have been computed
+// so far.
+func PasswordComputations() int64 {
+	return passwordComputations.Load()
+}
 
 func HashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+	passwordComputations.Add(1)
+	return PreferredPasswordHasher().Hash(password)
+}
+
+// CheckPassword verifies password against a hash in any supported format,
+// not only the preferred one.
+func CheckPassword(password, hashedPassword string) bool {
+	verifier, ok := passwordVerifierFor(hashedPassword)
+	if !ok {
+		return false
 	}
 
-	return string(hashed), nil
+	passwordComputations.Add(1)
+	return verifier.Verify(password, hashedPassword)
 }
 
-func CheckPassword(password, hashedPassword string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+// CheckPasswordConstantTime behaves like CheckPassword but still runs a full
+// comparison when there is no hash to check against, so a missing account
+// or a passkey-only account takes as long to reject as a wrong password.
+func CheckPasswordConstantTime(password, hashedPassword string) bool {
+	if hashedPassword == "" {
+		CheckPassword(password, dummyHash(PreferredPasswordHasher()))
+		return false
+	}
+
+	return CheckPassword(password, hashedPassword)
+}
+
+// PasswordNeedsRehash reports whether hashedPassword should be replaced by
+// a hash from the preferred hasher, either because it uses another
+// algorithm or because the cost parameters were raised.
+func PasswordNeedsRehash(hashedPassword string) bool {
+	preferred := PreferredPasswordHasher()
+	if !preferred.Identify(hashedPassword) {
+		return true
+	}
+
+	return preferred.NeedsRehash(hashedPassword)
+}
+
+func dummyHash(hasher PasswordHasher) string {
+	if hash, ok := dummyHashes.Load(hasher); ok {
+		return hash.(string)
+	}
+
+	hash, _ := hasher.Hash("dummy-password")
+	dummyHashes.Store(hasher, hash)
+
+	return hash
 }
@@ -1,23 +1,151 @@
 package utils
 
 import (
+	"errors"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-func GenerateJWT(userId string, role string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
+// RestrictionMfaEnrollment is the "restricted_to" claim of access tokens
+// that may only be used to set up MFA.
+const RestrictionMfaEnrollment = "mfa_enrollment"
+
+// RestrictionPasswordChange is the "restricted_to" claim of access tokens
+// that may only be used to change an expired password.
+const RestrictionPasswordChange = "password_change"
+
+// Values of the "amr" claim, as registered in RFC 8176.
+const (
+	AmrPassword    = "pwd"
+	AmrOtp         = "otp"
+	AmrHardwareKey = "hwk"
+	AmrMultiFactor = "mfa"
+)
+
+var (
+	keySetMu      sync.RWMutex
+	currentKeySet *KeySet
+)
+
+// UseKeySet switches token signing to the active asymmetric key of keySet.
+// Passing nil falls back to HS256 with JWT_SECRET.
+func UseKeySet(keySet *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+
+	currentKeySet = keySet
+}
+
+func CurrentKeySet() *KeySet {
+	keySetMu.RLock()
+	defer keySetMu.RUnlock()
 
+	return currentKeySet
+}
+
+func AccessTokenTTL() time.Duration {
+	return GetEnvDuration("JWT_ACCESS_TTL", 15*time.Minute)
+}
+
+func RefreshTokenTTL() time.Duration {
+	return GetEnvDuration("JWT_REFRESH_TTL", 7*24*time.Hour)
+}
+
+func GenerateJWT(userId string, role string, extraClaims ...jwt.MapClaims) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userId,
 		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"exp":     time.Now().Add(AccessTokenTTL()).Unix(),
 		"iat":     time.Now().Unix(),
+		"jti":     uuid.NewString(),
+	}
+
+	for _, extra := range extraClaims {
+		for name, value := range extra {
+			claims[name] = value
+		}
+	}
+
+	return SignClaims(claims)
+}
+
+// GenerateClientJWT issues a token to an OAuth client itself, for the
+// client_credentials grant. It has no user_id or role: sub and client_id
+// name the client, and scope says what it may do.
+func GenerateClientJWT(clientId string, scope string, ttl time.Duration) (string, error) {
+	return SignClaims(jwt.MapClaims{
+		"sub":       clientId,
+		"client_id": clientId,
+		"scope":     scope,
+		"exp":       time.Now().Add(ttl).Unix(),
+		"iat":       time.Now().Unix(),
+		"jti":       uuid.NewString(),
+	})
+}
+
+func SignClaims(claims jwt.MapClaims) (string, error) {
+	if keySet := CurrentKeySet(); keySet != nil {
+		key, ok := keySet.Active()
+		if !ok {
+			return "", errors.New("no active signing key")
+		}
+
+		return SignClaimsWithKey(key, claims)
 	}
 
+	secret := os.Getenv("JWT_SECRET")
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	return token.SignedString([]byte(secret))
 }
+
+// SignClaimsWithKey signs claims with the asymmetric key, never with
+// JWT_SECRET.
+func SignClaimsWithKey(key SigningKey, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.Kid
+
+	return token.SignedString(key.PrivateKey)
+}
+
+func ParseJWT(tokenString string) (jwt.MapClaims, error) {
+	keySet := CurrentKeySet()
+
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			// Once asymmetric keys are in use, HS256 tokens are only accepted
+			// during a migration window, since JWT_SECRET can mint them.
+			if keySet != nil && !GetEnvBool("JWT_ACCEPT_LEGACY_HS256", false) {
+				return nil, errors.New("invalid token signature")
+			}
+			return []byte(os.Getenv("JWT_SECRET")), nil
+		}
+
+		if keySet == nil {
+			return nil, errors.New("invalid token signature")
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keySet.Lookup(kid)
+		if !ok || key.Algorithm != t.Method.Alg() {
+			return nil, errors.New("unknown signing key")
+		}
+
+		return key.PublicKey(), nil
+	}, jwt.WithExpirationRequired())
+
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type SigningKey struct {
+	Kid         string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	RetiredAt   *time.Time
+}
+
+func (key SigningKey) PublicKey() crypto.PublicKey {
+	return key.PrivateKey.Public()
+}
+
+func (key SigningKey) Method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(key.Algorithm)
+}
+
+// KeySet holds every key whose signatures we still accept. The newest key
+// that has activated and has not been retired yet is used for signing, so
+// a key can be published before it signs anything.
+type KeySet struct {
+	mu     sync.RWMutex
+	sorted []SigningKey
+	keys   map[string]SigningKey
+}
+
+func NewKeySet(keys []SigningKey) *KeySet {
+	keySet := &KeySet{}
+	keySet.Replace(keys)
+	return keySet
+}
+
+func (keySet *KeySet) Replace(keys []SigningKey) {
+	byKid := map[string]SigningKey{}
+
+	sorted := append([]SigningKey(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	for _, key := range sorted {
+		byKid[key.Kid] = key
+	}
+
+	keySet.mu.Lock()
+	defer keySet.mu.Unlock()
+
+	keySet.keys = byKid
+	keySet.sorted = sorted
+}
+
+func (keySet *KeySet) Active() (SigningKey, bool) {
+	now := time.Now()
+
+	keySet.mu.RLock()
+	defer keySet.mu.RUnlock()
+
+	for i := len(keySet.sorted) - 1; i >= 0; i-- {
+		key := keySet.sorted[i]
+		if !key.ActivatesAt.After(now) && (key.RetiredAt == nil || key.RetiredAt.After(now)) {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+// Latest returns the most recently created key, which may not have
+// activated yet.
+func (keySet *KeySet) Latest() (SigningKey, bool) {
+	keySet.mu.RLock()
+	defer keySet.mu.RUnlock()
+
+	if len(keySet.sorted) == 0 {
+		return SigningKey{}, false
+	}
+	return keySet.sorted[len(keySet.sorted)-1], true
+}
+
+func (keySet *KeySet) Lookup(kid string) (SigningKey, bool) {
+	keySet.mu.RLock()
+	defer keySet.mu.RUnlock()
+
+	key, ok := keySet.keys[kid]
+	return key, ok
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (keySet *KeySet) JWKS() JWKS {
+	keySet.mu.RLock()
+	defer keySet.mu.RUnlock()
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range keySet.keys {
+		jwk, err := publicJWK(key)
+		if err != nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+
+	return jwks
+}
+
+func publicJWK(key SigningKey) (JWK, error) {
+	encode := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Use: "sig", Kid: key.Kid, Alg: key.Algorithm}
+
+	switch public := key.PublicKey().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encode(public.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = public.Curve.Params().Name
+		jwk.X = encode(public.X.FillBytes(make([]byte, size)))
+		jwk.Y = encode(public.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encode(public)
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	return jwk, nil
+}
+
+func GenerateSigningKey(algorithm string) (SigningKey, error) {
+	var privateKey crypto.Signer
+	var err error
+
+	switch algorithm {
+	case "RS256":
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return SigningKey{
+		Kid:        uuid.NewString(),
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+func EncodePrivateKey(privateKey crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+func DecodePrivateKey(encoded string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+
+	return signer, nil
+}
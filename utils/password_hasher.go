@@ -0,0 +1,372 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	passwordSaltLength = 16
+	passwordKeyLength  = 32
+)
+
+// Upper bounds for parameters read from stored hashes. A hash is data, so
+// without them one crafted hash could make a single login use all the
+// memory or CPU of the server.
+const (
+	maxArgon2Memory     = 1 << 20 // KiB, so 1 GiB
+	maxArgon2Iterations = 16
+	maxScryptMemory     = 1 << 30 // bytes
+	maxScryptLogN       = 20
+	maxScryptBlockSize  = 32
+	maxScryptParallel   = 16
+	maxDerivedKeyLength = 128
+	maxBcryptCost       = 16
+	maxPbkdf2Iterations = 2_000_000
+)
+
+var phcEncoding = base64.RawStdEncoding
+
+// PasswordVerifier checks passwords against hashes in one format. The
+// algorithm and its parameters are encoded in the hash itself, so a stored
+// hash can always be checked by the verifier that Identify picks for it.
+type PasswordVerifier interface {
+	// Name is the algorithm, as written in the hash_algorithm column of
+	// user imports.
+	Name() string
+	// Identify reports whether hash is in this verifier's format.
+	Identify(hash string) bool
+	// Valid reports whether hash parses completely and its parameters are
+	// within the bounds Verify is willing to compute.
+	Valid(hash string) bool
+	Verify(password string, hash string) bool
+}
+
+// PasswordHasher is a PasswordVerifier that can also produce new hashes.
+type PasswordHasher interface {
+	PasswordVerifier
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether hash was made with weaker parameters
+	// than the ones this hasher is configured with. It only upgrades, so
+	// lowering a setting never weakens hashes that are already stored.
+	NeedsRehash(hash string) bool
+}
+
+// PreferredPasswordHasher returns the hasher new passwords are hashed with,
+// chosen by PASSWORD_HASHER (bcrypt, argon2id or scrypt).
+func PreferredPasswordHasher() PasswordHasher {
+	hasher, _ := configuredPasswordHasher()
+	return hasher
+}
+
+// CheckPasswordHasherConfig reports settings that would make hashing panic
+// or produce hashes Valid rejects, so the server can refuse to start
+// instead of locking users out.
+func CheckPasswordHasherConfig() error {
+	_, err := configuredPasswordHasher()
+	return err
+}
+
+func configuredPasswordHasher() (PasswordHasher, error) {
+	switch name := strings.ToLower(os.Getenv("PASSWORD_HASHER")); name {
+	case "argon2id":
+		memory := GetEnvInt("ARGON2_MEMORY", 64*1024)
+		iterations := GetEnvInt("ARGON2_ITERATIONS", 3)
+		parallelism := GetEnvInt("ARGON2_PARALLELISM", 2)
+
+		hasher := Argon2idHasher{Memory: uint32(memory), Iterations: uint32(iterations), Parallelism: uint8(parallelism)}
+		if !argon2ParamsAllowed(memory, iterations, parallelism) {
+			return hasher, fmt.Errorf("ARGON2_ITERATIONS must be 1-%d, ARGON2_PARALLELISM 1-255 and ARGON2_MEMORY 8*ARGON2_PARALLELISM-%d KiB", maxArgon2Iterations, maxArgon2Memory)
+		}
+		return hasher, nil
+	case "scrypt":
+		hasher := ScryptHasher{
+			LogN:        GetEnvInt("SCRYPT_COST", 15),
+			BlockSize:   GetEnvInt("SCRYPT_BLOCK_SIZE", 8),
+			Parallelism: GetEnvInt("SCRYPT_PARALLELISM", 1),
+		}
+		if !scryptParamsAllowed(hasher.LogN, hasher.BlockSize, hasher.Parallelism) {
+			return hasher, fmt.Errorf("SCRYPT_COST must be 1-%d, SCRYPT_BLOCK_SIZE 1-%d and SCRYPT_PARALLELISM 1-%d, using at most %d bytes", maxScryptLogN, maxScryptBlockSize, maxScryptParallel, maxScryptMemory)
+		}
+		return hasher, nil
+	default:
+		hasher := BcryptHasher{Cost: GetEnvInt("BCRYPT_COST", bcrypt.DefaultCost)}
+		if name != "" && name != "bcrypt" {
+			return hasher, fmt.Errorf("unknown PASSWORD_HASHER %q", name)
+		}
+		if hasher.Cost < bcrypt.MinCost || hasher.Cost > maxBcryptCost {
+			return hasher, fmt.Errorf("BCRYPT_COST must be %d-%d", bcrypt.MinCost, maxBcryptCost)
+		}
+		return hasher, nil
+	}
+}
+
+// passwordVerifierFor finds the verifier that understands hash, whatever
+// the preferred hasher is. Imported hashes from other identity providers
+// are only ever verified, then replaced on the next login.
+func passwordVerifierFor(hash string) (PasswordVerifier, bool) {
+	verifiers := []PasswordVerifier{
+		BcryptHasher{},
+		Argon2idHasher{},
+		ScryptHasher{},
+		FirebaseScryptVerifier{},
+		DjangoPbkdf2Verifier{},
+	}
+
+	for _, verifier := range verifiers {
+		if verifier.Identify(hash) {
+			return verifier, true
+		}
+	}
+
+	return nil, false
+}
+
+// PasswordHashAlgorithm returns the algorithm of hash if CheckPassword can
+// verify it: the format is known, it parses and its parameters are within
+// bounds.
+func PasswordHashAlgorithm(hash string) (string, bool) {
+	verifier, ok := passwordVerifierFor(hash)
+	if !ok || !verifier.Valid(hash) {
+		return "", false
+	}
+
+	return verifier.Name(), true
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (hasher BcryptHasher) Name() string {
+	return "bcrypt"
+}
+
+func (hasher BcryptHasher) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (hasher BcryptHasher) Valid(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && len(hash) == 60 && cost <= maxBcryptCost
+}
+
+func (hasher BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashed), nil
+}
+
+func (hasher BcryptHasher) Verify(password string, hash string) bool {
+	return hasher.Valid(hash) && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NeedsRehash only upgrades: lowering BCRYPT_COST must not weaken hashes
+// that are already stored.
+func (hasher BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < hasher.Cost
+}
+
+// Argon2idHasher writes hashes in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+func (hasher Argon2idHasher) Name() string {
+	return "argon2id"
+}
+
+func (hasher Argon2idHasher) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (hasher Argon2idHasher) Valid(hash string) bool {
+	_, _, _, ok := parseArgon2id(hash)
+	return ok
+}
+
+func (hasher Argon2idHasher) Hash(password string) (string, error) {
+	salt, err := randomSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hasher.Iterations, hasher.Memory, hasher.Parallelism, passwordKeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, hasher.Memory, hasher.Iterations, hasher.Parallelism,
+		phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (hasher Argon2idHasher) Verify(password string, hash string) bool {
+	params, salt, key, ok := parseArgon2id(hash)
+	if !ok {
+		return false
+	}
+
+	derived := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(derived, key) == 1
+}
+
+func (hasher Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, ok := parseArgon2id(hash)
+	return !ok || params.Memory < hasher.Memory || params.Iterations < hasher.Iterations || params.Parallelism < hasher.Parallelism
+}
+
+func parseArgon2id(hash string) (Argon2idHasher, []byte, []byte, bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2idHasher{}, nil, nil, false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, false
+	}
+
+	// p is scanned into an int first: uint8 would silently wrap.
+	var memory, iterations, parallelism int
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return Argon2idHasher{}, nil, nil, false
+	}
+
+	if !argon2ParamsAllowed(memory, iterations, parallelism) {
+		return Argon2idHasher{}, nil, nil, false
+	}
+	params := Argon2idHasher{Memory: uint32(memory), Iterations: uint32(iterations), Parallelism: uint8(parallelism)}
+
+	salt, err := phcEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, false
+	}
+
+	key, err := phcEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || len(key) > maxDerivedKeyLength {
+		return Argon2idHasher{}, nil, nil, false
+	}
+
+	return params, salt, key, true
+}
+
+// argon2ParamsAllowed refuses parameters that argon2.IDKey panics on (t=0
+// or p=0) or that would make a single check exhaust memory or CPU.
+func argon2ParamsAllowed(memory int, iterations int, parallelism int) bool {
+	return iterations >= 1 && iterations <= maxArgon2Iterations && parallelism >= 1 && parallelism <= 255 &&
+		memory >= 8*parallelism && memory <= maxArgon2Memory
+}
+
+// ScryptHasher writes hashes in the PHC-style format used by passlib:
+// $scrypt$ln=15,r=8,p=1$<salt>$<key>
+type ScryptHasher struct {
+	LogN        int
+	BlockSize   int
+	Parallelism int
+}
+
+func (hasher ScryptHasher) Name() string {
+	return "scrypt"
+}
+
+func (hasher ScryptHasher) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$scrypt$")
+}
+
+func (hasher ScryptHasher) Valid(hash string) bool {
+	_, _, _, ok := parseScrypt(hash)
+	return ok
+}
+
+func (hasher ScryptHasher) Hash(password string) (string, error) {
+	salt, err := randomSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key([]byte(password), salt, 1<<hasher.LogN, hasher.BlockSize, hasher.Parallelism, passwordKeyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", hasher.LogN, hasher.BlockSize, hasher.Parallelism,
+		phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (hasher ScryptHasher) Verify(password string, hash string) bool {
+	params, salt, key, ok := parseScrypt(hash)
+	if !ok {
+		return false
+	}
+
+	derived, err := scrypt.Key([]byte(password), salt, 1<<params.LogN, params.BlockSize, params.Parallelism, len(key))
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(derived, key) == 1
+}
+
+func (hasher ScryptHasher) NeedsRehash(hash string) bool {
+	params, _, _, ok := parseScrypt(hash)
+	return !ok || params.LogN < hasher.LogN || params.BlockSize < hasher.BlockSize || params.Parallelism < hasher.Parallelism
+}
+
+func parseScrypt(hash string) (ScryptHasher, []byte, []byte, bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return ScryptHasher{}, nil, nil, false
+	}
+
+	params := ScryptHasher{}
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &params.LogN, &params.BlockSize, &params.Parallelism); err != nil {
+		return ScryptHasher{}, nil, nil, false
+	}
+
+	if !scryptParamsAllowed(params.LogN, params.BlockSize, params.Parallelism) {
+		return ScryptHasher{}, nil, nil, false
+	}
+
+	salt, err := phcEncoding.DecodeString(parts[3])
+	if err != nil {
+		return ScryptHasher{}, nil, nil, false
+	}
+
+	key, err := phcEncoding.DecodeString(parts[4])
+	if err != nil || len(key) == 0 || len(key) > maxDerivedKeyLength {
+		return ScryptHasher{}, nil, nil, false
+	}
+
+	return params, salt, key, true
+}
+
+// scryptParamsAllowed refuses parameters that would make a single check
+// exhaust memory or CPU. scrypt needs about 128*r*N bytes, times p in time.
+func scryptParamsAllowed(logN int, blockSize int, parallelism int) bool {
+	if logN < 1 || logN > maxScryptLogN || blockSize < 1 || blockSize > maxScryptBlockSize || parallelism < 1 || parallelism > maxScryptParallel {
+		return false
+	}
+
+	return 128*blockSize*(1<<logN) <= maxScryptMemory
+}
+
+func randomSalt() ([]byte, error) {
+	salt := make([]byte, passwordSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords is ranked by popularity. A password built from these is
+// guessed after about rank attempts, the same idea zxcvbn uses with its
+// much larger frequency lists.
+var commonPasswords = []string{
+	"password", "123456", "12345678", "qwerty", "123456789", "12345", "1234", "111111",
+	"1234567", "dragon", "123123", "baseball", "abc123", "football", "monkey", "letmein",
+	"696969", "shadow", "master", "666666", "qwertyuiop", "123321", "mustang", "1234567890",
+	"michael", "654321", "superman", "1qaz2wsx", "7777777", "121212", "000000", "qazwsx",
+	"123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter",
+	"buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou",
+	"charlie", "robert", "thomas", "hockey", "ranger", "daniel", "starwars", "klaster",
+	"112233", "george", "computer", "michelle", "jessica", "pepper", "zxcvbn", "555555",
+	"ginger", "joshua", "cheese", "amanda", "summer", "love", "ashley", "nicole", "chelsea",
+	"biteme", "matthew", "access", "yankees", "dallas", "austin", "thunder", "taylor",
+	"matrix", "welcome", "admin", "login", "passw0rd", "secret", "princess", "qwerty123",
+	"solo", "freedom", "whatever", "nothing", "hello", "flower", "test", "guest", "changeme",
+}
+
+const maxStrengthRunes = 100
+
+var keyboardRows = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"1qaz", "2wsx", "3edc", "4rfv", "5tgb", "6yhn", "7ujm", "8ik,", "9ol.", "0p;/",
+}
+
+var leetSubstitutions = strings.NewReplacer("4", "a", "@", "a", "8", "b", "3", "e", "6", "g", "1", "i", "!", "i", "0", "o", "5", "s", "$", "s", "7", "t", "2", "z")
+
+// PasswordStrength estimates how hard password is to guess, from 0 (too
+// guessable) to 4 (very unguessable), on the same scale as zxcvbn. It splits
+// the password into the cheapest combination of common passwords, the
+// user's own details in userInputs, keyboard walks, sequences, repeats,
+// years and brute-forced characters, then scores the total guesses.
+func PasswordStrength(password string, userInputs ...string) int {
+	log10Guesses := estimateLog10Guesses(password, userInputs)
+
+	switch {
+	case log10Guesses < 3:
+		return 0
+	case log10Guesses < 6:
+		return 1
+	case log10Guesses < 8:
+		return 2
+	case log10Guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func estimateLog10Guesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+
+	// Matching is quadratic, so only the start of a very long password is
+	// analysed and the rest counts as brute force.
+	tail := 0.0
+	if len(runes) > maxStrengthRunes {
+		tail = float64(len(runes) - maxStrengthRunes)
+		runes = runes[:maxStrengthRunes]
+	}
+
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+
+	dictionary := map[string]int{}
+	for rank, word := range commonPasswords {
+		dictionary[word] = rank + 1
+	}
+	for _, input := range userInputs {
+		for _, word := range strings.FieldsFunc(strings.ToLower(input), isWordSeparator) {
+			if len([]rune(word)) >= 3 {
+				dictionary[word] = 1
+			}
+		}
+	}
+
+	// best[i] is the fewest log10 guesses that cover the first i runes, plus
+	// a small cost per segment so fewer, longer matches are preferred.
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(1)
+		for start := 0; start < i; start++ {
+			cost := best[start] + segmentLog10Guesses(runes[start:i], dictionary) + math.Log10(2)
+			if cost < best[i] {
+				best[i] = cost
+			}
+		}
+	}
+
+	return best[n] + tail
+}
+
+// segmentLog10Guesses returns the log10 guesses needed for one segment,
+// using the cheapest pattern it matches.
+func segmentLog10Guesses(segment []rune, dictionary map[string]int) float64 {
+	length := len(segment)
+	guesses := float64(length) // brute force: 10 guesses per character
+
+	word := strings.ToLower(string(segment))
+	variations := 0.0
+	if word != string(segment) {
+		variations = math.Log10(2)
+	}
+
+	if rank, ok := dictionary[word]; ok {
+		guesses = math.Min(guesses, math.Log10(float64(rank))+variations)
+	}
+	if unleeted := leetSubstitutions.Replace(word); unleeted != word {
+		if rank, ok := dictionary[unleeted]; ok {
+			guesses = math.Min(guesses, math.Log10(float64(rank))+variations+math.Log10(2))
+		}
+	}
+
+	if length >= 3 {
+		if isRepeat(segment) {
+			guesses = math.Min(guesses, math.Log10(float64(12*length)))
+		}
+		if isSequence(segment) {
+			guesses = math.Min(guesses, math.Log10(float64(4*length)))
+		}
+	}
+	if length >= 4 && isKeyboardWalk(word) {
+		guesses = math.Min(guesses, math.Log10(float64(40*length)))
+	}
+	if length == 4 && (strings.HasPrefix(word, "19") || strings.HasPrefix(word, "20")) && isDigits(word) {
+		guesses = math.Min(guesses, math.Log10(119))
+	}
+
+	return guesses
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func isRepeat(segment []rune) bool {
+	for _, r := range segment[1:] {
+		if r != segment[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func isSequence(segment []rune) bool {
+	step := segment[1] - segment[0]
+	if step != 1 && step != -1 {
+		return false
+	}
+	for i := 2; i < len(segment); i++ {
+		if segment[i]-segment[i-1] != step {
+			return false
+		}
+	}
+	return true
+}
+
+func isKeyboardWalk(word string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, word) || strings.Contains(reverse(row), word) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigits(word string) bool {
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "strings"
+
+// userAgentBrowsers and userAgentPlatforms are checked in order, so tokens
+// that other user agents copy (every Chromium browser claims to be Chrome
+// and Safari) come after the more specific ones.
+var userAgentBrowsers = []struct{ token, name string }{
+	{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"},
+	{"Safari/", "Safari"}, {"curl/", "curl"}, {"okhttp/", "OkHttp"}, {"PostmanRuntime/", "Postman"},
+}
+
+var userAgentPlatforms = []struct{ token, name string }{
+	{"Android", "Android"}, {"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Windows", "Windows"},
+	{"Mac OS X", "macOS"}, {"CrOS", "ChromeOS"}, {"Linux", "Linux"},
+}
+
+// DeviceName turns a User-Agent header into a short label such as "Chrome
+// on Windows", for showing users where they are signed in.
+func DeviceName(userAgent string) string {
+	browser, platform := "", ""
+	for _, candidate := range userAgentBrowsers {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+	for _, candidate := range userAgentPlatforms {
+		if strings.Contains(userAgent, candidate.token) {
+			platform = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
@@ -0,0 +1,103 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers accepted for new credentials, in order of
+// preference.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// PublicKey is a credential public key decoded from its COSE_Key form.
+type PublicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+func ParsePublicKey(coseKey []byte) (PublicKey, error) {
+	decoded, rest, err := decodeCBOR(coseKey)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	if len(rest) != 0 {
+		return PublicKey{}, errors.New("trailing data after public key")
+	}
+	return publicKeyFromMap(decoded)
+}
+
+func publicKeyFromMap(decoded interface{}) (PublicKey, error) {
+	fields, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return PublicKey{}, errors.New("public key is not a map")
+	}
+
+	kty, _ := fields[int64(1)].(int64)
+	alg, _ := fields[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == AlgES256:
+		crv, _ := fields[int64(-1)].(int64)
+		x, _ := fields[int64(-2)].([]byte)
+		y, _ := fields[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return PublicKey{}, errors.New("invalid P-256 public key")
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return PublicKey{}, errors.New("invalid P-256 public key")
+		}
+		return PublicKey{Algorithm: alg, Key: key}, nil
+	case kty == 1 && alg == AlgEdDSA:
+		crv, _ := fields[int64(-1)].(int64)
+		x, _ := fields[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return PublicKey{}, errors.New("invalid Ed25519 public key")
+		}
+		return PublicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := fields[int64(-1)].([]byte)
+		e, _ := fields[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return PublicKey{}, errors.New("invalid RSA public key")
+		}
+		return PublicKey{Algorithm: alg, Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}}, nil
+	default:
+		return PublicKey{}, fmt.Errorf("unsupported public key type %d with algorithm %d", kty, alg)
+	}
+}
+
+func (publicKey PublicKey) Verify(data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch key := publicKey.Key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, data, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+
+	return errors.New("invalid signature")
+}
@@ -0,0 +1,210 @@
+// Package webauthn implements the relying party side of the WebAuthn
+// registration and authentication ceremonies. Attestation statements are
+// not verified: credentials are requested with attestation "none" and no
+// authenticator model is trusted more than another.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	FlagUserPresent            byte = 0x01
+	FlagUserVerified           byte = 0x04
+	FlagAttestedCredentialData byte = 0x40
+)
+
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+type ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func (authData AuthenticatorData) UserVerified() bool {
+	return authData.Flags&FlagUserVerified != 0
+}
+
+// DecodeBase64URL accepts both padded and unpadded base64url, since clients
+// differ in what they send.
+func DecodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func EncodeBase64URL(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+func ParseClientData(clientDataJSON []byte) (ClientData, error) {
+	var clientData ClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return ClientData{}, errors.New("invalid client data")
+	}
+
+	return clientData, nil
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create
+// and returns the new credential's authenticator data.
+func (rp RelyingParty) VerifyRegistration(clientDataJSON []byte, attestationObject []byte, challenge string) (AuthenticatorData, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return AuthenticatorData{}, errors.New("invalid attestation object")
+	}
+
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return AuthenticatorData{}, errors.New("invalid attestation object")
+	}
+
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return AuthenticatorData{}, errors.New("invalid attestation object")
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	if authData.Flags&FlagAttestedCredentialData == 0 || len(authData.CredentialID) == 0 {
+		return AuthenticatorData{}, errors.New("attested credential data missing")
+	}
+
+	if _, err := ParsePublicKey(authData.PublicKey); err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	return authData, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get against
+// the credential's stored COSE public key.
+func (rp RelyingParty) VerifyAssertion(clientDataJSON []byte, rawAuthData []byte, signature []byte, coseKey []byte, challenge string) (AuthenticatorData, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	publicKey, err := ParsePublicKey(coseKey)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+
+	if err := publicKey.Verify(signed, signature); err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	return authData, nil
+}
+
+func (rp RelyingParty) verifyClientData(clientDataJSON []byte, ceremony string, challenge string) error {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+
+	if clientData.Type != ceremony {
+		return errors.New("unexpected ceremony type")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return errors.New("challenge mismatch")
+	}
+
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+
+	return errors.New("origin not allowed")
+}
+
+func (rp RelyingParty) verifyAuthenticatorData(raw []byte) (AuthenticatorData, error) {
+	authData, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return AuthenticatorData{}, err
+	}
+
+	rpIdHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.RPIDHash, rpIdHash[:]) {
+		return AuthenticatorData{}, errors.New("relying party id mismatch")
+	}
+
+	if authData.Flags&FlagUserPresent == 0 {
+		return AuthenticatorData{}, errors.New("user not present")
+	}
+
+	return authData, nil
+}
+
+func parseAuthenticatorData(raw []byte) (AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return AuthenticatorData{}, errors.New("authenticator data too short")
+	}
+
+	authData := AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if authData.Flags&FlagAttestedCredentialData == 0 {
+		return authData, nil
+	}
+
+	// aaguid (16 bytes), credential id length (2 bytes), credential id,
+	// then the CBOR encoded public key.
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return AuthenticatorData{}, errors.New("attested credential data too short")
+	}
+
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLength {
+		return AuthenticatorData{}, errors.New("attested credential data too short")
+	}
+
+	authData.CredentialID = rest[:idLength]
+
+	_, after, err := decodeCBOR(rest[idLength:])
+	if err != nil {
+		return AuthenticatorData{}, errors.New("invalid credential public key")
+	}
+	authData.PublicKey = rest[idLength : len(rest)-len(after)]
+
+	return authData, nil
+}